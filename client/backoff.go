@@ -0,0 +1,51 @@
+package client
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff 重连的指数退避参数
+type Backoff struct {
+	// 第一次重连前的等待时间
+	InitialInterval time.Duration
+
+	// 等待时间上限
+	MaxInterval time.Duration
+
+	// 每次失败后等待时间的倍数
+	Multiplier float64
+
+	// 随机抖动比例，0.2 表示在等待时间的 ±20% 内随机
+	Jitter float64
+
+	// 连续失败的最大重连次数，0 表示不限制
+	MaxAttempts int
+
+	// 连续重连的最长时间，0 表示不限制
+	MaxElapsed time.Duration
+}
+
+func DefaultBackoff() Backoff {
+	return Backoff{
+		InitialInterval: time.Second,
+		MaxInterval:     time.Minute,
+		Multiplier:      2,
+		Jitter:          0.2,
+	}
+}
+
+// Interval 第 attempt 次（从 0 开始）重连前的等待时间
+func (b Backoff) Interval(attempt int) time.Duration {
+	interval := float64(b.InitialInterval) * math.Pow(b.Multiplier, float64(attempt))
+	if interval > float64(b.MaxInterval) {
+		interval = float64(b.MaxInterval)
+	}
+
+	if b.Jitter > 0 {
+		interval += interval * b.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(interval)
+}
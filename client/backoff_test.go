@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffInterval(t *testing.T) {
+	noJitter := Backoff{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 2}
+
+	tests := []struct {
+		name    string
+		backoff Backoff
+		attempt int
+		want    time.Duration
+	}{
+		{"first attempt", noJitter, 0, time.Second},
+		{"second attempt", noJitter, 1, 2 * time.Second},
+		{"exponential growth", noJitter, 5, 32 * time.Second},
+		{"capped", noJitter, 6, time.Minute},
+		{"capped after overflow", noJitter, 10000, time.Minute},
+		{"multiplier 1", Backoff{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 1}, 10, time.Second},
+		{"fractional multiplier", Backoff{InitialInterval: time.Second, MaxInterval: time.Minute, Multiplier: 1.5}, 2, 2250 * time.Millisecond},
+		{"initial above max", Backoff{InitialInterval: time.Hour, MaxInterval: time.Minute, Multiplier: 2}, 0, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.backoff.Interval(tt.attempt); got != tt.want {
+				t.Errorf("Interval(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+// 抖动后的等待时间在 ±Jitter 范围内，且不是固定值
+func TestBackoffIntervalJitter(t *testing.T) {
+	backoff := DefaultBackoff()
+
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{0, time.Second},
+		{3, 8 * time.Second},
+		{10, time.Minute},
+	}
+
+	for _, tt := range tests {
+		low := time.Duration(float64(tt.base) * (1 - backoff.Jitter))
+		high := time.Duration(float64(tt.base) * (1 + backoff.Jitter))
+
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 1000; i++ {
+			got := backoff.Interval(tt.attempt)
+			if got < low || got > high {
+				t.Fatalf("Interval(%d) = %s, want within [%s, %s]", tt.attempt, got, low, high)
+			}
+			seen[got] = true
+		}
+
+		if len(seen) < 2 {
+			t.Errorf("Interval(%d) returned a constant value with jitter %v", tt.attempt, backoff.Jitter)
+		}
+	}
+}
+
+// 达到重连次数或时长上限、客户端关闭时停止重连
+func TestTunnelReconnectLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		backoff  Backoff
+		shutdown bool
+
+		// 连接次数的范围
+		minAttempts int
+		maxAttempts int
+
+		// reconnect 返回前的最长时间
+		maxDuration time.Duration
+	}{
+		{
+			name:        "max attempts",
+			backoff:     Backoff{InitialInterval: 10 * time.Millisecond, MaxInterval: 20 * time.Millisecond, Multiplier: 2, MaxAttempts: 3},
+			minAttempts: 3,
+			maxAttempts: 3,
+			maxDuration: 2 * time.Second,
+		},
+		{
+			name:        "max elapsed",
+			backoff:     Backoff{InitialInterval: 10 * time.Millisecond, MaxInterval: 20 * time.Millisecond, Multiplier: 2, MaxElapsed: 100 * time.Millisecond},
+			minAttempts: 2,
+			maxAttempts: 10,
+			maxDuration: time.Second,
+		},
+		{
+			name:        "interval beyond max elapsed",
+			backoff:     Backoff{InitialInterval: time.Minute, MaxInterval: time.Minute, Multiplier: 2, MaxElapsed: time.Second},
+			maxDuration: 100 * time.Millisecond,
+		},
+		{
+			name:        "shutdown",
+			backoff:     Backoff{InitialInterval: time.Minute, MaxInterval: time.Minute, Multiplier: 2},
+			shutdown:    true,
+			maxDuration: 100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 接受连接后立即关闭，登录失败
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+
+			var attempts atomic.Int32
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					attempts.Add(1)
+					conn.Close()
+				}
+			}()
+
+			addr := listener.Addr().String()
+			c := New(addr)
+			c.RpcTimeout = 200 * time.Millisecond
+			c.Backoff = tt.backoff
+			defer c.Shutdown(context.Background())
+
+			if tt.shutdown {
+				c.Shutdown(context.Background())
+			}
+
+			tunnel := newTunnel(0, c, &Upstream{Addr: addr})
+
+			start := time.Now()
+			if tunnel.reconnect() {
+				t.Fatal("reconnect() = true without a server")
+			}
+
+			elapsed := time.Since(start)
+			if elapsed > tt.maxDuration {
+				t.Errorf("reconnect() took %s, want at most %s", elapsed, tt.maxDuration)
+			}
+			// 最后一次连接可能在上限前开始，最多超出一次连接的时间
+			if tt.backoff.MaxElapsed > 0 && elapsed > tt.backoff.MaxElapsed+c.RpcTimeout {
+				t.Errorf("reconnect() took %s, exceeds MaxElapsed %s", elapsed, tt.backoff.MaxElapsed)
+			}
+
+			if got := int(attempts.Load()); got < tt.minAttempts || got > tt.maxAttempts {
+				t.Errorf("reconnect() attempts = %d, want [%d, %d]", got, tt.minAttempts, tt.maxAttempts)
+			}
+		})
+	}
+}
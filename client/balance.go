@@ -0,0 +1,82 @@
+package client
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// BalanceStrategy 新通道在隧道间的分配策略
+type BalanceStrategy string
+
+const (
+	// 依次轮询可用隧道
+	RoundRobin BalanceStrategy = "round-robin"
+
+	// 选择当前通道数最少的隧道
+	LeastChannels BalanceStrategy = "least-channels"
+
+	// 选择心跳往返时间最短的隧道
+	LowestRtt BalanceStrategy = "lowest-rtt"
+)
+
+func ParseBalanceStrategy(strategy string) (BalanceStrategy, error) {
+	switch BalanceStrategy(strategy) {
+	case "", RoundRobin:
+		return RoundRobin, nil
+	case LeastChannels:
+		return LeastChannels, nil
+	case LowestRtt:
+		return LowestRtt, nil
+	}
+
+	return RoundRobin, errors.New("invalid balance strategy: " + strategy)
+}
+
+// pick 先按服务端策略选择服务端，再按分配策略选择该服务端的一条可用隧道，没有可用隧道时返回 nil
+func (c *Client) pick() *Tunnel {
+	upstream := c.pickUpstream()
+	if upstream == nil {
+		return nil
+	}
+
+	return c.pickTunnel(upstream.tunnels)
+}
+
+// pickTunnel 按分配策略选择一条可用隧道
+func (c *Client) pickTunnel(tunnels []*Tunnel) *Tunnel {
+	size := len(tunnels)
+	if size == 0 {
+		return nil
+	}
+
+	// 从轮询位置开始遍历，相同条件下依次选择不同的隧道
+	start := int(c.next.Add(1) - 1)
+
+	var best *Tunnel
+	bestScore := int64(math.MaxInt64)
+
+	for i := 0; i < size; i++ {
+		tunnel := tunnels[(start+i)%size]
+		if !tunnel.Available() {
+			continue
+		}
+
+		var score int64
+		switch c.Balance {
+		case LeastChannels:
+			score = int64(tunnel.Conn().ChannelCount())
+		case LowestRtt:
+			score = int64(tunnel.Conn().RTT() / time.Microsecond)
+		default:
+			return tunnel
+		}
+
+		if score < bestScore {
+			best = tunnel
+			bestScore = score
+		}
+	}
+
+	return best
+}
@@ -2,13 +2,14 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
-	"log"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/ssp/msg"
 	"github.com/ssp/network"
 	"github.com/ssp/util"
@@ -22,161 +23,418 @@ const (
 	UnConnected ClientFlag = 2
 	Ready       ClientFlag = 3
 	UnReady     ClientFlag = 4
+
+	// 重连次数或时长达到上限，不再重连
+	Stopped ClientFlag = 5
 )
 
 type Client struct {
-	Flag       ClientFlag
-	ServerAddr string
-	RemoteConn *network.Connection
-	Proxy      *Socks5Proxy
+	// 服务端地址列表，按顺序作为同等健康状况下的优先级
+	Servers []string
+
+	// 服务端选择策略
+	ServerPolicy ServerPolicy
+
+	// 与每个服务端之间并行的隧道数，默认 1
+	Tunnels int
+
+	// 新通道在隧道间的分配策略
+	Balance BalanceStrategy
+
+	// SOCKS5 代理监听地址
+	Socks5Addr string
+
+	// 登录服务端的用户名/密码
+	LoginName     string
+	LoginPassword string
+
+	// RPC 请求超时时间
+	RpcTimeout time.Duration
+
+	// 是否以 OPEN 打开通道：不等待服务端连接目标即应答并发送数据，需服务端支持。
+	// SOCKS5 与 HTTP 代理总是先应答成功，访问控制拒绝、解析失败、连接被拒绝等原因
+	// 无法再以 0x02～0x06 或 403/502/504 返回，客户端只会看到连接被关闭，原因仅记录在日志中
+	FastOpen bool
+
+	// 断线重连的退避策略
+	Backoff Backoff
+
+	// 与服务端连接的参数
+	ConnConfig network.ConnectionConfig
+
+	// 不为空时使用 TLS 连接服务端
+	TLS *tls.Config
+
+	// 不为空时使用预共享密钥加密与服务端之间的数据
+	Cipher *network.Cipher
+
+	Proxy *Socks5Proxy
+	Http  *HttpProxy
+
+	// HTTP 代理单独监听的地址，为空时只与 SOCKS5 共用端口
+	HttpAddr string
+
+	// SOCKS5 用户名/密码校验
+	Credentials CredentialStore
 
-	ticker time.Ticker
+	// 是否拒绝无需认证的 SOCKS5 客户端
+	RequireAuth bool
+
+	// 远程端口转发，由每个服务端的第一条隧道在登录后请求监听
+	Reverse []ReverseForward
+
+	// 服务端列表，Connect 时整体替换
+	upstreams atomic.Pointer[[]*Upstream]
+
+	// 本地端口转发
+	forwards []*LocalForward
+
+	// 保护 forwards
+	forwardMutex sync.Mutex
+
+	// 路由规则，为空时所有目标经隧道
+	router atomic.Pointer[Router]
+
+	// sticky 策略下当前使用的服务端
+	current atomic.Pointer[Upstream]
+
+	// 轮询计数
+	next atomic.Uint32
+
+	// 状态变化订阅者
+	listeners listeners
+
+	// Shutdown 时关闭，通知重连协程退出
+	done chan struct{}
+
+	shutdownOnce sync.Once
 
 	traceId string
 }
 
-func New(ServerAddr string) *Client {
+func New(servers ...string) *Client {
 	gid := fmt.Sprintf("gid:%d", util.GetGID())
 
-	return &Client{Flag: Init, ServerAddr: ServerAddr, traceId: gid}
+	return &Client{
+		Servers:      servers,
+		ServerPolicy: StickyServer,
+		Tunnels:      1,
+		Balance:      RoundRobin,
+		Socks5Addr:   ":1080",
+		RpcTimeout:   5 * time.Second,
+		Backoff:      DefaultBackoff(),
+		ConnConfig:   network.DefaultConnectionConfig(),
+		done:         make(chan struct{}),
+		traceId:      gid,
+	}
 }
 
+// Connect 并发建立所有服务端的隧道，至少一条隧道可用时返回 true
 func (c *Client) Connect() bool {
 
 	defer util.Trace(c.traceId, "Client Connect")()
 
-	conn, err := net.Dial("tcp", c.ServerAddr)
-	if err != nil {
-		log.Printf("Connect remote server:%s fail...\n", c.ServerAddr)
-		c.Flag = UnConnected
-		return false
+	size := c.Tunnels
+	if size <= 0 {
+		size = 1
 	}
 
-	log.Printf("Connect remote server:%s success...\n", c.ServerAddr)
+	upstreams := make([]*Upstream, len(c.Servers))
+	for i, addr := range c.Servers {
+		upstreams[i] = newUpstream(addr, size, c)
+	}
+	c.upstreams.Store(&upstreams)
+
+	var wg sync.WaitGroup
+	for _, tunnel := range tunnelsOf(upstreams) {
+		wg.Add(1)
+		go func(tunnel *Tunnel) {
+			defer wg.Done()
+			tunnel.Connect()
+		}(tunnel)
+	}
+	wg.Wait()
 
-	connection := network.NewConnection(conn)
-	c.RemoteConn = connection
+	return c.Available()
 
-	go connection.Read()
-	go connection.Write()
-	go connection.PingPongAndTimeout()
+}
+
+func (c *Client) dial(addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.RpcTimeout}
+
+	if c.TLS == nil {
+		conn, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
 
-	c.login()
+		if c.Cipher != nil {
+			conn = c.Cipher.Wrap(conn)
+		}
+
+		return conn, nil
+	}
 
-	return c.Flag == Ready
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, c.TLS)
+	if err != nil {
+		util.Errorf("Tls handshake with %s fail:%s\n", addr, err.Error())
+		return nil, err
+	}
 
+	return conn, nil
 }
 
+// Reconnect 每条隧道在连接关闭后独立重连，阻塞直到所有隧道停止重连
 func (c *Client) Reconnect() {
-	c.ticker = *time.NewTicker(5 * time.Second)
-
-	for {
-		select {
-		case <-c.ticker.C:
-			if c.Flag != Ready || c.RemoteConn.Closed() {
-				log.Printf("Connection was close,reconnect ......\n")
-				c.Connect()
-			}
-		}
+	var wg sync.WaitGroup
+
+	for _, tunnel := range c.TunnelList() {
+		wg.Add(1)
+		go func(tunnel *Tunnel) {
+			defer wg.Done()
+			tunnel.Reconnect()
+		}(tunnel)
 	}
+
+	wg.Wait()
 }
 
-func (c *Client) login() {
+// BuildNewChannel 选择一条可用隧道建立 TCP 通道
+func (c *Client) BuildNewChannel(ctx context.Context, addr string) (*network.Channel, error) {
+
+	tunnel := c.pick()
+	if tunnel == nil {
+		util.Infoln("Client is not available!")
 
-	defer util.Trace(c.traceId, "Client Login")()
+		return nil, errors.New("Client is not available!")
+	}
 
-	message := network.BuildLoginReq(c.RemoteConn)
-	log.Printf("send rpc message: %v \n", message)
+	return tunnel.BuildNewChannel(ctx, addr)
+}
 
-	promise := network.RpcInvoker(context.TODO(), c.RemoteConn, message, 5*time.Second, nil)
+// OpenChannel 选择一条可用隧道以 OPEN 打开通道
+func (c *Client) OpenChannel(ctx context.Context, addr string) (*network.Channel, error) {
 
-	res, ok := promise.Get()
+	tunnel := c.pick()
+	if tunnel == nil {
+		util.Infoln("Client is not available!")
 
-	if !ok {
-		log.Println("login request fail")
-		c.Flag = UnReady
-		return
+		return nil, errors.New("Client is not available!")
 	}
 
-	data := res.Data
-	commonRes := &msg.CommonRes{}
+	return tunnel.OpenChannel(ctx, addr)
+}
 
-	err := proto.Unmarshal(data, commonRes)
-	if err != nil {
-		log.Println("Invlid login res!!!")
-		c.Flag = UnReady
-		return
-	}
+// BuildNewAssociate 选择一条可用隧道建立 UDP 关联
+func (c *Client) BuildNewAssociate(ctx context.Context) (*network.Datagram, error) {
+
+	tunnel := c.pick()
+	if tunnel == nil {
+		util.Infoln("Client is not available!")
 
-	// 成功
-	if commonRes.Code == 1 {
-		c.Flag = Ready
+		return nil, errors.New("Client is not available!")
 	}
 
+	return tunnel.BuildNewAssociate(ctx)
 }
 
-func (c *Client) BuildNewChannel(ctx context.Context, addr string) (*network.Channel, error) {
+// BuildBindChannel 请求服务端监听随机端口，返回的通道 BindAddr 为监听地址
+func (c *Client) BuildBindChannel(ctx context.Context, addr string) (*network.Channel, error) {
 
-	if !c.Available() {
-		log.Println("Client is not available!")
+	tunnel := c.pick()
+	if tunnel == nil {
+		util.Infoln("Client is not available!")
 
 		return nil, errors.New("Client is not available!")
 	}
 
+	return tunnel.BuildBindChannel(ctx, addr)
+}
+
+// AcceptBindChannel 等待服务端接收 BIND 连接，返回对端地址
+func (c *Client) AcceptBindChannel(ctx context.Context, channel *network.Channel) (string, error) {
+
 	traceId, _ := ctx.Value("traceId").(string)
 
-	defer util.Trace(traceId, "Client BuildNewChannel")()
+	defer util.Trace(traceId, "Client AcceptBindChannel")()
+
+	acceptReq := &msg.BindAcceptReq{ChannelId: channel.Id, TraceId: traceId}
+	util.Debugf("%s,send bind accept request: %v \n", traceId, acceptReq)
+
+	ctx, cancel := context.WithTimeout(ctx, c.ConnConfig.BindAcceptTimeout+c.RpcTimeout)
+	defer cancel()
 
-	channelMessage := network.BuildNewChannelReq(c.RemoteConn, addr, traceId)
-	log.Printf("%s,send new channel message: %v \n", traceId, channelMessage)
+	res, err := channel.UnderlyingConn.Invoke(ctx, network.BindAcceptCmd, acceptReq)
+	if err != nil {
+		util.Errorf("%s,bind accept request fail:%s\n", traceId, err.Error())
+
+		return "", fmt.Errorf("bind accept: %w", err)
+	}
+
+	return res.(*msg.BindAcceptRes).PeerAddr, nil
 
-	channelPromise := network.RpcInvoker(ctx, c.RemoteConn, channelMessage, 5*time.Second, nil)
-	res, ok := channelPromise.Get()
+}
+
+func (c *Client) Start() {
 
-	if !ok {
-		log.Printf("%s,new channel request fail.\n", traceId)
+	addr := c.Socks5Addr
 
-		return nil, errors.New("New channel fail")
+	httpProxy := NewHttpProxy(c.HttpAddr, c)
+	httpProxy.Credentials = c.Credentials
+	httpProxy.RequireAuth = c.RequireAuth
+	c.Http = httpProxy
 
+	proxy := NewSocks5Proxy(addr, c)
+	proxy.Credentials = c.Credentials
+	proxy.RequireAuth = c.RequireAuth
+	proxy.Http = httpProxy
+	c.Proxy = proxy
+
+	proxy.Start()
+
+	if c.HttpAddr != "" {
+		httpProxy.Start()
 	}
+}
 
-	data := res.Data
-	channelRes := &msg.NewChannelRes{}
+// SetAuth 运行中修改 SOCKS5/HTTP 代理的认证配置
+func (c *Client) SetAuth(credentials CredentialStore, requireAuth bool) {
+	c.Credentials = credentials
+	c.RequireAuth = requireAuth
 
-	err := proto.Unmarshal(data, channelRes)
-	if err != nil {
-		log.Printf("%s,Invlid channel res!!!\n", traceId)
+	if c.Proxy != nil {
+		c.Proxy.SetAuth(credentials, requireAuth)
+	}
 
-		return nil, errors.New("Invlid channel res")
+	if c.Http != nil {
+		c.Http.SetAuth(credentials, requireAuth)
 	}
+}
 
-	// 成功
-	if channelRes.Code == 1 {
+// AddForward 添加本地端口转发，添加后需调用 Enable 开始监听；Local 已存在时返回已有的转发
+func (c *Client) AddForward(local string, remote string) *LocalForward {
+	c.forwardMutex.Lock()
+	defer c.forwardMutex.Unlock()
 
-		channelId := channelRes.ChannelId
-		channel := network.NewChannel(channelId, c.RemoteConn)
-		channel.TraceId = traceId
+	for _, forward := range c.forwards {
+		if forward.Local == local {
+			return forward
+		}
+	}
 
-		c.RemoteConn.RegChannel(channelId, channel)
+	forward := NewLocalForward(local, remote, c)
+	c.forwards = append(c.forwards, forward)
 
-		return channel, nil
+	return forward
+}
 
+// Forward 按监听地址查找本地端口转发，不存在时返回 nil
+func (c *Client) Forward(local string) *LocalForward {
+	c.forwardMutex.Lock()
+	defer c.forwardMutex.Unlock()
+
+	for _, forward := range c.forwards {
+		if forward.Local == local {
+			return forward
+		}
 	}
 
-	return nil, errors.New("Build channel fail")
+	return nil
+}
+
+// Forwards 返回所有本地端口转发
+func (c *Client) Forwards() []*LocalForward {
+	c.forwardMutex.Lock()
+	defer c.forwardMutex.Unlock()
 
+	return append([]*LocalForward(nil), c.forwards...)
 }
 
-func (c *Client) Start() {
+// Shutdown 停止接收代理、本地端口转发连接及重连，向服务端发送 GOAWAY，
+// 等待通道结束或 ctx 结束后关闭所有隧道
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.shutdownOnce.Do(func() {
+		close(c.done)
+	})
 
-	addr := ":1080"
+	if c.Proxy != nil {
+		c.Proxy.Stop()
+	}
 
-	proxy := NewSocks5Proxy(addr, c)
-	c.Proxy = proxy
+	if c.Http != nil {
+		c.Http.Stop()
+	}
 
-	proxy.Start()
+	for _, forward := range c.Forwards() {
+		forward.Disable()
+	}
+
+	var connections []*network.Connection
+	for _, tunnel := range c.TunnelList() {
+		if conn := tunnel.Conn(); conn != nil {
+			connections = append(connections, conn)
+		}
+	}
+
+	util.Infof("Client shutting down,tunnels:%d\n", len(connections))
+
+	errs := make(chan error, len(connections))
+	for _, conn := range connections {
+		go func(conn *network.Connection) {
+			errs <- conn.Drain(ctx)
+		}(conn)
+	}
+
+	var err error
+	for range connections {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
 }
 
+// Done Shutdown 开始时关闭
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Available 至少一个服务端可用
 func (c *Client) Available() bool {
-	return c.Flag == Ready
+	for _, upstream := range c.upstreamList() {
+		if upstream.Healthy() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Upstreams 返回所有服务端的副本
+func (c *Client) Upstreams() []*Upstream {
+	return append([]*Upstream(nil), c.upstreamList()...)
+}
+
+// upstreamList 当前的服务端列表，只读，Connect 之前为空
+func (c *Client) upstreamList() []*Upstream {
+	if upstreams := c.upstreams.Load(); upstreams != nil {
+		return *upstreams
+	}
+
+	return nil
+}
+
+// TunnelList 返回所有服务端的隧道
+func (c *Client) TunnelList() []*Tunnel {
+	return tunnelsOf(c.upstreamList())
+}
+
+func tunnelsOf(upstreams []*Upstream) []*Tunnel {
+	var tunnels []*Tunnel
+	for _, upstream := range upstreams {
+		tunnels = append(tunnels, upstream.tunnels...)
+	}
+
+	return tunnels
 }
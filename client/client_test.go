@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Connect 替换服务端列表时，代理协程可以并发读取（需 -race）
+func TestClientConnectConcurrentReaders(t *testing.T) {
+	// 没有服务监听的地址，连接立即失败
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	c := New(addr, addr)
+	c.Tunnels = 2
+	c.RpcTimeout = 100 * time.Millisecond
+	c.Backoff.MaxAttempts = 1
+	defer c.Shutdown(context.Background())
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				c.Available()
+				c.pick()
+				c.TunnelList()
+
+				upstreams := c.Upstreams()
+				if len(upstreams) > 0 {
+					// 返回的是副本，修改不影响客户端
+					upstreams[0] = nil
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		if c.Connect() {
+			t.Fatal("Connect() succeeded without a server")
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	for _, upstream := range c.Upstreams() {
+		if upstream == nil {
+			t.Fatal("Upstreams() exposed the internal slice")
+		}
+	}
+
+	if got := len(c.TunnelList()); got != 4 {
+		t.Errorf("TunnelList() = %d tunnels, want 4", got)
+	}
+}
@@ -0,0 +1,205 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ssp/network"
+	"github.com/ssp/util"
+)
+
+// ForwardStats 本地端口转发的统计
+type ForwardStats struct {
+	// 接入的连接数
+	Accepted int64
+
+	// 正在转发的连接数
+	Active int64
+
+	// 建立通道失败的连接数
+	Failed int64
+
+	// 从本地连接读取、发往远端的字节数
+	BytesOut int64
+
+	// 从远端收到、写入本地连接的字节数
+	BytesIn int64
+}
+
+// LocalForward 本地端口转发：监听 Local，每个连接经隧道转发到固定的 Remote
+type LocalForward struct {
+	Local  string
+	Remote string
+
+	client *Client
+
+	// 启用时的监听，停用时为空
+	listener net.Listener
+
+	// 保护 listener
+	mutex sync.Mutex
+
+	accepted atomic.Int64
+	active   atomic.Int64
+	failed   atomic.Int64
+	bytesOut atomic.Int64
+	bytesIn  atomic.Int64
+
+	// Trace ID 生成器
+	traceIdGenerator *util.Id
+}
+
+func NewLocalForward(local string, remote string, client *Client) *LocalForward {
+	return &LocalForward{
+		Local:            local,
+		Remote:           remote,
+		client:           client,
+		traceIdGenerator: util.NewId(0),
+	}
+}
+
+// Enable 开始监听，已启用时忽略
+func (f *LocalForward) Enable() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.listener != nil {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", f.Local)
+	if err != nil {
+		util.Errorf("Forward %s -> %s listen failed: %v\n", f.Local, f.Remote, err)
+		return err
+	}
+
+	f.listener = listener
+
+	go f.accept(listener)
+
+	util.Infof("Forward %s -> %s enabled\n", f.Local, f.Remote)
+
+	return nil
+}
+
+// Disable 关闭监听，不再接收新连接，已建立的连接不受影响
+func (f *LocalForward) Disable() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.listener == nil {
+		return
+	}
+
+	f.listener.Close()
+	f.listener = nil
+
+	util.Infof("Forward %s -> %s disabled,stats:%+v\n", f.Local, f.Remote, f.Stats())
+}
+
+// Enabled 是否正在监听
+func (f *LocalForward) Enabled() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.listener != nil
+}
+
+func (f *LocalForward) Stats() ForwardStats {
+	return ForwardStats{
+		Accepted: f.accepted.Load(),
+		Active:   f.active.Load(),
+		Failed:   f.failed.Load(),
+		BytesOut: f.bytesOut.Load(),
+		BytesIn:  f.bytesIn.Load(),
+	}
+}
+
+func (f *LocalForward) accept(listener net.Listener) {
+
+	ctx := context.Background()
+
+	for {
+		src, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			util.Errorf("Forward %s accept failed: %+v \n", f.Local, err)
+			continue
+		}
+
+		traceId := fmt.Sprintf("fwd:%s,id:%d", f.Local, f.traceIdGenerator.IncrementAndGet())
+		newCtx := context.WithValue(ctx, "traceId", traceId)
+
+		go f.process(newCtx, src)
+	}
+}
+
+func (f *LocalForward) process(ctx context.Context, src net.Conn) {
+	traceId := ctx.Value("traceId").(string)
+
+	defer util.Trace(traceId, "Forward Process")()
+
+	f.accepted.Add(1)
+
+	util.Infof("%s,New forward conn:%s -> %s \n", traceId, src.RemoteAddr(), f.Remote)
+
+	channel, err := f.client.BuildNewChannel(ctx, f.Remote)
+	if err != nil {
+		util.Errorf("%s,forward error:%s\n", traceId, err.Error())
+		f.failed.Add(1)
+		src.Close()
+		return
+	}
+
+	f.active.Add(1)
+
+	target := network.NewRemoteConn(&forwardConn{Conn: src, forward: f})
+	target.TraceId = traceId
+	network.FlowForward(ctx, channel, target)
+}
+
+// forwardConn 统计本地连接的收发字节数，关闭时减少正在转发的连接数
+type forwardConn struct {
+	net.Conn
+
+	forward *LocalForward
+
+	closeOnce sync.Once
+}
+
+func (c *forwardConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.forward.bytesOut.Add(int64(n))
+
+	return n, err
+}
+
+func (c *forwardConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.forward.bytesIn.Add(int64(n))
+
+	return n, err
+}
+
+// CloseWrite 保留本地连接的半关闭能力
+func (c *forwardConn) CloseWrite() error {
+	if conn, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return conn.CloseWrite()
+	}
+
+	return c.Close()
+}
+
+func (c *forwardConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.forward.active.Add(-1)
+	})
+
+	return c.Conn.Close()
+}
@@ -0,0 +1,362 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+)
+
+// mmdb 元数据起始标记
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// GeoIP MaxMind DB（mmdb）格式的国家数据库，如 GeoLite2-Country.mmdb
+type GeoIP struct {
+	// 搜索树
+	tree []byte
+
+	// 数据段
+	data mmdbDecoder
+
+	nodeCount  uint32
+	recordSize int
+	ipVersion  int
+
+	// IPv6 数据库中 IPv4 地址（::/96）的起始节点
+	ipv4Start uint32
+}
+
+func OpenGeoIP(path string) (*GeoIP, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := parseGeoIP(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return g, nil
+}
+
+func parseGeoIP(file []byte) (*GeoIP, error) {
+	pos := bytes.LastIndex(file, mmdbMetadataMarker)
+	if pos < 0 {
+		return nil, errors.New("invalid mmdb file: metadata not found")
+	}
+
+	metadata := mmdbDecoder{buf: file[pos+len(mmdbMetadataMarker):]}
+	value, _, err := metadata.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mmdb metadata: %w", err)
+	}
+
+	meta, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid mmdb metadata")
+	}
+
+	nodeCount, _ := meta["node_count"].(uint64)
+	recordSize, _ := meta["record_size"].(uint64)
+	ipVersion, _ := meta["ip_version"].(uint64)
+
+	if recordSize != 24 && recordSize != 28 && recordSize != 32 {
+		return nil, fmt.Errorf("unsupported mmdb record size: %d", recordSize)
+	}
+
+	if ipVersion != 4 && ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported mmdb ip version: %d", ipVersion)
+	}
+
+	// 搜索树之后是 16 字节的分隔符；先按文件长度限制节点数，避免转换为 int 时溢出
+	if nodeCount == 0 || nodeCount > uint64(len(file)) {
+		return nil, errors.New("invalid mmdb search tree")
+	}
+
+	treeSize := int(nodeCount) * int(recordSize) / 4
+	if treeSize+16 > pos {
+		return nil, errors.New("invalid mmdb search tree")
+	}
+
+	g := &GeoIP{
+		tree:       file[:treeSize],
+		data:       mmdbDecoder{buf: file[treeSize+16 : pos]},
+		nodeCount:  uint32(nodeCount),
+		recordSize: int(recordSize),
+		ipVersion:  int(ipVersion),
+	}
+
+	if g.ipVersion == 6 {
+		node := uint32(0)
+		for i := 0; i < 96 && node < g.nodeCount; i++ {
+			node = g.record(node, 0)
+		}
+		g.ipv4Start = node
+
+		// IPv4 子树必须是搜索树中的节点
+		if g.ipv4Start >= g.nodeCount {
+			return nil, errors.New("invalid mmdb search tree: ipv4 subtree not found")
+		}
+	}
+
+	return g, nil
+}
+
+// Country 返回 IP 所属国家的 ISO 代码（大写），未知时返回空
+func (g *GeoIP) Country(ip net.IP) string {
+	offset, ok := g.lookup(ip)
+	if !ok {
+		return ""
+	}
+
+	value, _, err := g.data.decode(offset)
+	if err != nil {
+		return ""
+	}
+
+	record, _ := value.(map[string]any)
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := record[key].(map[string]any); ok {
+			if code, ok := country["iso_code"].(string); ok && code != "" {
+				return strings.ToUpper(code)
+			}
+		}
+	}
+
+	return ""
+}
+
+// lookup 在搜索树中查找 IP，返回数据段偏移
+func (g *GeoIP) lookup(ip net.IP) (int, bool) {
+	var addr []byte
+	node := uint32(0)
+
+	if ip4 := ip.To4(); ip4 != nil {
+		addr = ip4
+		if g.ipVersion == 6 {
+			node = g.ipv4Start
+		}
+	} else if g.ipVersion == 6 && len(ip) == net.IPv6len {
+		addr = ip
+	} else {
+		return 0, false
+	}
+
+	for i := 0; i < len(addr)*8 && node < g.nodeCount; i++ {
+		bit := (addr[i/8] >> (7 - uint(i%8))) & 1
+		node = g.record(node, int(bit))
+	}
+
+	if node <= g.nodeCount {
+		return 0, false
+	}
+
+	return int(node-g.nodeCount) - 16, true
+}
+
+// record 读取节点的左（bit 为 0）或右记录
+func (g *GeoIP) record(node uint32, bit int) uint32 {
+	b := g.tree
+
+	switch g.recordSize {
+	case 24:
+		off := int(node)*6 + bit*3
+		return uint32(b[off])<<16 | uint32(b[off+1])<<8 | uint32(b[off+2])
+
+	case 28:
+		off := int(node) * 7
+		if bit == 0 {
+			return uint32(b[off+3]&0xf0)<<20 | uint32(b[off])<<16 | uint32(b[off+1])<<8 | uint32(b[off+2])
+		}
+		return uint32(b[off+3]&0x0f)<<24 | uint32(b[off+4])<<16 | uint32(b[off+5])<<8 | uint32(b[off+6])
+
+	default:
+		off := int(node)*8 + bit*4
+		return binary.BigEndian.Uint32(b[off : off+4])
+	}
+}
+
+// mmdbDecoder 解析 mmdb 数据段，指针为相对 buf 起始的偏移
+type mmdbDecoder struct {
+	buf []byte
+}
+
+var errMmdbData = errors.New("invalid mmdb data")
+
+// 数据嵌套的最大深度，避免损坏的文件中循环的指针
+const mmdbMaxDepth = 64
+
+// decode 解析 offset 处的值，返回值及下一个值的偏移；
+// 整数统一返回 uint64（int32 返回 int64），超过 64 位的整数只保留低 64 位
+func (d *mmdbDecoder) decode(offset int) (any, int, error) {
+	return d.value(offset, 0)
+}
+
+func (d *mmdbDecoder) value(offset int, depth int) (any, int, error) {
+	if offset < 0 || offset >= len(d.buf) || depth > mmdbMaxDepth {
+		return nil, 0, errMmdbData
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+
+	typ := int(ctrl >> 5)
+
+	// 指针：解析指向的值，继续从指针之后读取
+	if typ == 1 {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		value, _, err := d.value(pointer, depth+1)
+		return value, next, err
+	}
+
+	// 扩展类型
+	if typ == 0 {
+		if offset >= len(d.buf) {
+			return nil, 0, errMmdbData
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.size(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typ {
+	case 7:
+		m := make(map[string]any, size)
+		for i := 0; i < size; i++ {
+			key, next, err := d.value(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errMmdbData
+			}
+
+			if m[name], offset, err = d.value(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+
+	case 11:
+		array := make([]any, size)
+		for i := range array {
+			if array[i], offset, err = d.value(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return array, offset, nil
+
+	case 14:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > len(d.buf) {
+		return nil, 0, errMmdbData
+	}
+	raw := d.buf[offset : offset+size]
+	offset += size
+
+	switch typ {
+	case 2:
+		return string(raw), offset, nil
+
+	case 3:
+		if size != 8 {
+			return nil, 0, errMmdbData
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), offset, nil
+
+	case 4:
+		return raw, offset, nil
+
+	case 5, 6, 9, 10:
+		var v uint64
+		for _, b := range raw {
+			v = v<<8 | uint64(b)
+		}
+		return v, offset, nil
+
+	case 8:
+		var v uint32
+		for _, b := range raw {
+			v = v<<8 | uint32(b)
+		}
+		return int64(int32(v)), offset, nil
+
+	case 15:
+		if size != 4 {
+			return nil, 0, errMmdbData
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(raw)), offset, nil
+	}
+
+	return nil, 0, fmt.Errorf("unsupported mmdb data type: %d", typ)
+}
+
+// size 解析控制字节中的长度，29～31 表示长度存放在之后的 1～3 个字节中
+func (d *mmdbDecoder) size(ctrl byte, offset int) (int, int, error) {
+	size := int(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > len(d.buf) {
+		return 0, 0, errMmdbData
+	}
+
+	extra := 0
+	for _, b := range d.buf[offset : offset+n] {
+		extra = extra<<8 | int(b)
+	}
+
+	switch size {
+	case 29:
+		size = 29 + extra
+	case 30:
+		size = 285 + extra
+	default:
+		size = 65821 + extra
+	}
+
+	return size, offset + n, nil
+}
+
+// pointer 解析指针，返回指向的偏移及指针之后的偏移
+func (d *mmdbDecoder) pointer(ctrl byte, offset int) (int, int, error) {
+	n := int(ctrl>>3&0x3) + 1
+	if offset+n > len(d.buf) {
+		return 0, 0, errMmdbData
+	}
+
+	value := 0
+	for _, b := range d.buf[offset : offset+n] {
+		value = value<<8 | int(b)
+	}
+
+	switch n {
+	case 1:
+		value |= int(ctrl&0x7) << 8
+	case 2:
+		value = (value | int(ctrl&0x7)<<16) + 2048
+	case 3:
+		value = (value | int(ctrl&0x7)<<24) + 526336
+	}
+
+	return value, offset + n, nil
+}
@@ -0,0 +1,310 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// mmdbEncode 按 mmdb 数据段格式编码测试数据，长度不超过 28
+func mmdbEncode(v any) []byte {
+	ctrl := func(typ int, size int) []byte {
+		if typ <= 7 {
+			return []byte{byte(typ<<5 | size)}
+		}
+		return []byte{byte(size), byte(typ - 7)}
+	}
+
+	switch v := v.(type) {
+	case string:
+		return append(ctrl(2, len(v)), v...)
+	case float64:
+		return binary.BigEndian.AppendUint64(ctrl(3, 8), math.Float64bits(v))
+	case []byte:
+		return append(ctrl(4, len(v)), v...)
+	case uint16:
+		return binary.BigEndian.AppendUint16(ctrl(5, 2), v)
+	case uint32:
+		return binary.BigEndian.AppendUint32(ctrl(6, 4), v)
+	case map[string]any:
+		buf := ctrl(7, len(v))
+		for key, value := range v {
+			buf = append(buf, mmdbEncode(key)...)
+			buf = append(buf, mmdbEncode(value)...)
+		}
+		return buf
+	case int32:
+		return binary.BigEndian.AppendUint32(ctrl(8, 4), uint32(v))
+	case uint64:
+		return binary.BigEndian.AppendUint64(ctrl(9, 8), v)
+	case []any:
+		buf := ctrl(11, len(v))
+		for _, value := range v {
+			buf = append(buf, mmdbEncode(value)...)
+		}
+		return buf
+	case bool:
+		if v {
+			return ctrl(14, 1)
+		}
+		return ctrl(14, 0)
+	}
+
+	panic("unsupported mmdb test value")
+}
+
+// mmdbFile 由搜索树、数据段及元数据组成 mmdb 文件
+func mmdbFile(tree []byte, data []byte, meta map[string]any) []byte {
+	file := append([]byte{}, tree...)
+	file = append(file, make([]byte, 16)...)
+	file = append(file, data...)
+	file = append(file, mmdbMetadataMarker...)
+	return append(file, mmdbEncode(meta)...)
+}
+
+// buildMmdb 构造国家数据库，networks 为 CIDR 到数据记录的映射；
+// IPv6 数据库中的 IPv4 网络位于 ::/96 之下
+func buildMmdb(t *testing.T, ipVersion int, recordSize int, networks map[string]map[string]any) []byte {
+	t.Helper()
+
+	// 节点的左右记录：非负为子节点，-1 为空，-2-k 为第 k 条数据
+	nodes := [][2]int{{-1, -1}}
+
+	var data []byte
+	var offsets []int
+
+	for cidr, record := range networks {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("ParseCIDR(%s) error = %v", cidr, err)
+		}
+
+		ones, _ := ipNet.Mask.Size()
+		addr := []byte(ipNet.IP)
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			addr = ip4
+			if ipVersion == 6 {
+				addr = append(make([]byte, 12), ip4...)
+				ones += 96
+			}
+		}
+
+		offsets = append(offsets, len(data))
+		data = append(data, mmdbEncode(record)...)
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(addr[i/8]>>(7-uint(i%8))) & 1
+			if i == ones-1 {
+				nodes[node][bit] = -2 - (len(offsets) - 1)
+				break
+			}
+			if nodes[node][bit] < 0 {
+				nodes = append(nodes, [2]int{-1, -1})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	value := func(record int) uint32 {
+		switch {
+		case record >= 0:
+			return uint32(record)
+		case record == -1:
+			return uint32(nodeCount)
+		}
+		return uint32(nodeCount + 16 + offsets[-2-record])
+	}
+
+	var tree []byte
+	for _, node := range nodes {
+		left, right := value(node[0]), value(node[1])
+
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left))
+			tree = append(tree, byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left))
+			tree = append(tree, byte(left>>24&0x0f)<<4|byte(right>>24&0x0f))
+			tree = append(tree, byte(right>>16), byte(right>>8), byte(right))
+		default:
+			tree = binary.BigEndian.AppendUint32(tree, left)
+			tree = binary.BigEndian.AppendUint32(tree, right)
+		}
+	}
+
+	return mmdbFile(tree, data, map[string]any{
+		"node_count":  uint32(nodeCount),
+		"record_size": uint16(recordSize),
+		"ip_version":  uint16(ipVersion),
+	})
+}
+
+func country(code string) map[string]any {
+	return map[string]any{"country": map[string]any{"iso_code": code}}
+}
+
+func TestGeoIPCountry(t *testing.T) {
+	networks := map[string]map[string]any{
+		"1.0.0.0/8":       country("CN"),
+		"8.8.8.0/24":      country("US"),
+		"5.0.0.0/8":       {"registered_country": map[string]any{"iso_code": "de"}},
+		"6.0.0.0/8":       {"continent": map[string]any{"code": "EU"}},
+		"2001:db8::/32":   country("JP"),
+		"2001:db9::1/128": country("KR"),
+	}
+
+	tests := []struct {
+		ip    string
+		want4 string
+		want6 string
+	}{
+		{"1.2.3.4", "CN", "CN"},
+		{"::ffff:1.2.3.4", "CN", "CN"},
+		{"8.8.8.8", "US", "US"},
+		{"8.8.9.8", "", ""},
+		{"5.6.7.8", "DE", "DE"},
+		{"6.7.8.9", "", ""},
+		{"9.9.9.9", "", ""},
+		{"2001:db8::1", "", "JP"},
+		{"2001:db9::1", "", "KR"},
+		{"2001:db9::2", "", ""},
+	}
+
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			db := networks
+			if ipVersion == 4 {
+				db = map[string]map[string]any{}
+				for cidr, record := range networks {
+					if ip, _, _ := net.ParseCIDR(cidr); ip.To4() != nil {
+						db[cidr] = record
+					}
+				}
+			}
+
+			g, err := parseGeoIP(buildMmdb(t, ipVersion, recordSize, db))
+			if err != nil {
+				t.Fatalf("ipv%d/%d: parseGeoIP() error = %v", ipVersion, recordSize, err)
+			}
+
+			for _, tt := range tests {
+				want := tt.want4
+				if ipVersion == 6 {
+					want = tt.want6
+				}
+
+				if got := g.Country(net.ParseIP(tt.ip)); got != want {
+					t.Errorf("ipv%d/%d: Country(%s) = %q, want %q", ipVersion, recordSize, tt.ip, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestParseGeoIPInvalid(t *testing.T) {
+	meta := func(nodeCount uint64, recordSize uint16, ipVersion uint16) map[string]any {
+		return map[string]any{"node_count": nodeCount, "record_size": recordSize, "ip_version": ipVersion}
+	}
+
+	// 只有 IPv6 网络的 IPv6 数据库，::/96 不在搜索树中
+	ipv6Only := buildMmdb(t, 6, 24, map[string]map[string]any{"2001:db8::/32": country("JP")})
+
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"empty", nil},
+		{"metadata not found", bytes.Repeat([]byte{0}, 64)},
+		{"metadata not a map", append(append([]byte{}, mmdbMetadataMarker...), mmdbEncode("meta")...)},
+		{"truncated metadata", append(append([]byte{}, mmdbMetadataMarker...), 0xe3)},
+		{"record size", mmdbFile(make([]byte, 6), nil, meta(1, 16, 4))},
+		{"ip version", mmdbFile(make([]byte, 6), nil, meta(1, 24, 5))},
+		{"no nodes", mmdbFile(nil, nil, meta(0, 24, 4))},
+		{"tree beyond file", mmdbFile(make([]byte, 6), nil, meta(2, 24, 4))},
+		{"node count overflow", mmdbFile(make([]byte, 6), nil, meta(math.MaxUint64/3, 24, 4))},
+		{"node count beyond file", mmdbFile(make([]byte, 6), nil, meta(1<<40, 32, 4))},
+		{"ipv4 subtree not found", ipv6Only},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseGeoIP(tt.file); err == nil {
+				t.Error("parseGeoIP() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestMmdbDecoder(t *testing.T) {
+	long := string(bytes.Repeat([]byte("a"), 40))
+	longer := string(bytes.Repeat([]byte("b"), 300))
+
+	tests := []struct {
+		name     string
+		buf      []byte
+		offset   int
+		want     any
+		wantNext int
+		wantErr  bool
+	}{
+		{"string", mmdbEncode("cn"), 0, "cn", 3, false},
+		{"empty string", []byte{0x40}, 0, "", 1, false},
+		{"string size 29+", append([]byte{0x5d, 11}, long...), 0, long, 42, false},
+		{"string size 285+", append([]byte{0x5e, 0, 15}, longer...), 0, longer, 303, false},
+		{"double", mmdbEncode(1.5), 0, 1.5, 9, false},
+		{"bytes", mmdbEncode([]byte{1, 2}), 0, []byte{1, 2}, 3, false},
+		{"uint16", mmdbEncode(uint16(443)), 0, uint64(443), 3, false},
+		{"uint32", mmdbEncode(uint32(1 << 31)), 0, uint64(1 << 31), 5, false},
+		{"short uint32", []byte{0xc1, 0x7f}, 0, uint64(0x7f), 2, false},
+		{"int32", mmdbEncode(int32(-2)), 0, int64(-2), 6, false},
+		{"uint64", mmdbEncode(uint64(math.MaxUint64)), 0, uint64(math.MaxUint64), 10, false},
+		{"uint128 keeps low bits", append([]byte{0x10, 0x03}, append(bytes.Repeat([]byte{0xff}, 8), 0, 0, 0, 0, 0, 0, 0, 7)...), 0, uint64(7), 18, false},
+		{"bool", mmdbEncode(true), 0, true, 2, false},
+		{"float", []byte{0x04, 0x08, 0x3f, 0xc0, 0, 0}, 0, float32(1.5), 6, false},
+		{"array", mmdbEncode([]any{"a", uint16(1)}), 0, []any{"a", uint64(1)}, 7, false},
+		{"map", mmdbEncode(map[string]any{"iso_code": "CN"}), 0, map[string]any{"iso_code": "CN"}, 13, false},
+		// 偏移 3 处的 map 中值为指向偏移 0 的指针
+		{"pointer", []byte{0x42, 'c', 'n', 0xe1, 0x43, 'i', 's', 'o', 0x20, 0x00}, 3, map[string]any{"iso": "cn"}, 10, false},
+		{"pointer size 2", append(append([]byte{0x28, 0x00, 0x00}, make([]byte, 2045)...), mmdbEncode("x")...), 0, "x", 3, false},
+
+		{"offset out of range", mmdbEncode("cn"), 3, nil, 0, true},
+		{"negative offset", mmdbEncode("cn"), -1, nil, 0, true},
+		{"truncated string", []byte{0x45, 'a'}, 0, nil, 0, true},
+		{"truncated size", []byte{0x5e, 0}, 0, nil, 0, true},
+		{"truncated extended type", []byte{0x00}, 0, nil, 0, true},
+		{"truncated pointer", []byte{0x28, 0x00}, 0, nil, 0, true},
+		{"pointer loop", []byte{0x20, 0x00}, 0, nil, 0, true},
+		{"map key not string", []byte{0xe1, 0xa1, 0x01, 0x40}, 0, nil, 0, true},
+		{"truncated map", []byte{0xe2, 0x41, 'a', 0x40}, 0, nil, 0, true},
+		{"double size", []byte{0x64, 0, 0, 0, 0}, 0, nil, 0, true},
+		{"float size", []byte{0x08, 0x08, 0, 0}, 0, nil, 0, true},
+		{"unsupported type", []byte{0x00, 0x05}, 0, nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := mmdbDecoder{buf: tt.buf}
+
+			got, next, err := d.decode(tt.offset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decode() = %#v, want %#v", got, tt.want)
+			}
+			if next != tt.wantNext {
+				t.Errorf("decode() next = %d, want %d", next, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ssp/network"
+	"github.com/ssp/util"
+)
+
+// 逐跳首部，不能转发给下一跳
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type HttpProxy struct {
+	Addr           string
+	Proxy          net.Listener
+	RemoteEndpoint *Client
+
+	// 用户名/密码校验，为空时无需认证
+	Credentials CredentialStore
+
+	// 为 true 时拒绝未认证的请求
+	RequireAuth bool
+
+	// Trace ID 生成器
+	traceIdGenerator *util.Id
+
+	// 保护运行中修改的认证配置
+	authMutex sync.RWMutex
+
+	// 已停止接收新连接
+	stopped atomic.Bool
+}
+
+func NewHttpProxy(addr string, client *Client) *HttpProxy {
+	return &HttpProxy{Addr: addr, RemoteEndpoint: client, traceIdGenerator: util.NewId(0)}
+}
+
+func (h *HttpProxy) Start() {
+	server, err := net.Listen("tcp", h.Addr)
+	if err != nil {
+		util.Errorf("Listen failed: %v\n", err)
+		return
+	}
+
+	h.Proxy = server
+
+	go h.Accept()
+
+	util.Infoln("Http proxy client startup successfully:")
+}
+
+// Stop 关闭单独的监听，不再接收新连接，已建立的连接不受影响
+func (h *HttpProxy) Stop() {
+	h.stopped.Store(true)
+
+	if h.Proxy != nil {
+		h.Proxy.Close()
+	}
+}
+
+// SetAuth 运行中修改认证配置，对之后的请求生效
+func (h *HttpProxy) SetAuth(credentials CredentialStore, requireAuth bool) {
+	h.authMutex.Lock()
+	defer h.authMutex.Unlock()
+
+	h.Credentials = credentials
+	h.RequireAuth = requireAuth
+}
+
+func (h *HttpProxy) authConfig() (CredentialStore, bool) {
+	h.authMutex.RLock()
+	defer h.authMutex.RUnlock()
+
+	return h.Credentials, h.RequireAuth
+}
+
+func (h *HttpProxy) Accept() {
+
+	ctx := context.Background()
+
+	for {
+		src, err := h.Proxy.Accept()
+		if err != nil {
+			if h.stopped.Load() {
+				return
+			}
+			util.Errorf("Http proxy client accept failed: %+v \n", err)
+			continue
+		}
+
+		traceId := fmt.Sprintf("http-id:%d", h.traceIdGenerator.IncrementAndGet())
+		newCtx := context.WithValue(ctx, "traceId", traceId)
+
+		go h.Process(newCtx, newBufferedConn(src))
+	}
+}
+
+func (h *HttpProxy) Process(ctx context.Context, src *bufferedConn) {
+	traceId := ctx.Value("traceId").(string)
+
+	defer util.Trace(traceId, "Client Http Process")()
+
+	util.Infof("%s,New http conn:%s \n", traceId, src.RemoteAddr())
+
+	for {
+		req, err := http.ReadRequest(src.reader)
+		if err != nil {
+			if err != io.EOF {
+				util.Errorf("%s,read request error:%s\n", traceId, err.Error())
+			}
+			src.Close()
+			return
+		}
+
+		reqCtx, err := h.auth(ctx, req)
+		if err != nil {
+			util.Errorf("%s,auth error:%s\n", traceId, err.Error())
+			writeHttpError(src, http.StatusProxyAuthRequired, req)
+			src.Close()
+			return
+		}
+
+		if req.Method == http.MethodConnect {
+			h.HttpConnect(reqCtx, src, req)
+			return
+		}
+
+		if !h.HttpForward(reqCtx, src, req) {
+			src.Close()
+			return
+		}
+	}
+}
+
+// HttpConnect 处理 CONNECT 请求，建立出站连接后转发原始数据
+func (h *HttpProxy) HttpConnect(ctx context.Context, src *bufferedConn, req *http.Request) {
+	traceId := ctx.Value("traceId").(string)
+
+	destAddrPort := hostPort(req.Host, "443")
+	util.Infof("%s,Connect %s\n", traceId, destAddrPort)
+
+	outbound, err := h.RemoteEndpoint.Dial(ctx, destAddrPort)
+	if err != nil {
+		util.Errorf("%s,Connect %s failed:%s\n", traceId, destAddrPort, err.Error())
+		writeHttpError(src, connectErrorStatus(err), req)
+		src.Close()
+		return
+	}
+
+	_, err = io.WriteString(src, "HTTP/1.1 200 Connection established\r\n\r\n")
+	if err != nil {
+		util.Errorf("%s,write rsp error:%s\n", traceId, err.Error())
+		outbound.Close()
+		src.Close()
+		return
+	}
+
+	outbound.Forward(ctx, src)
+}
+
+// HttpForward 转发使用绝对 URI 的普通请求，返回客户端连接是否可以继续使用
+func (h *HttpProxy) HttpForward(ctx context.Context, src *bufferedConn, req *http.Request) bool {
+	traceId := ctx.Value("traceId").(string)
+
+	if req.URL.Host == "" || req.URL.Scheme != "http" {
+		util.Errorf("%s,Invalid proxy request uri:%s\n", traceId, req.RequestURI)
+		writeHttpError(src, http.StatusBadRequest, req)
+		return false
+	}
+
+	destAddrPort := hostPort(req.URL.Host, "80")
+	util.Infof("%s,Forward %s %s\n", traceId, req.Method, req.URL)
+
+	outbound, err := h.RemoteEndpoint.Dial(ctx, destAddrPort)
+	if err != nil {
+		util.Errorf("%s,Connect %s failed:%s\n", traceId, destAddrPort, err.Error())
+		writeHttpError(src, connectErrorStatus(err), req)
+		return false
+	}
+	defer outbound.Close()
+
+	keepAlive := !req.Close
+
+	// 每个请求使用单独的出站连接，上游连接在响应后关闭
+	removeHopByHopHeaders(req.Header)
+	req.RequestURI = ""
+	req.Close = true
+
+	if err := req.Write(outbound); err != nil {
+		util.Errorf("%s,write request error:%s\n", traceId, err.Error())
+		writeHttpError(src, http.StatusBadGateway, req)
+		return false
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(outbound), req)
+	if err != nil {
+		util.Errorf("%s,read response error:%s\n", traceId, err.Error())
+		writeHttpError(src, http.StatusBadGateway, req)
+		return false
+	}
+	defer res.Body.Close()
+
+	removeHopByHopHeaders(res.Header)
+	res.Close = !keepAlive
+
+	if err := res.Write(src); err != nil {
+		util.Errorf("%s,write response error:%s\n", traceId, err.Error())
+		return false
+	}
+
+	return keepAlive && !res.Close
+}
+
+// auth 校验 Proxy-Authorization，认证通过的用户名放入上下文；
+// 要求认证但没有配置用户时拒绝所有请求，与 SOCKS5 代理一致
+func (h *HttpProxy) auth(ctx context.Context, req *http.Request) (context.Context, error) {
+	credentials, requireAuth := h.authConfig()
+	if credentials == nil {
+		if requireAuth {
+			return ctx, errors.New("proxy authorization required but no credentials configured")
+		}
+		return ctx, nil
+	}
+
+	username, password, ok := parseProxyAuth(req.Header.Get("Proxy-Authorization"))
+	if !ok {
+		if requireAuth {
+			return ctx, errors.New("proxy authorization required")
+		}
+		return ctx, nil
+	}
+
+	if !credentials.Validate(username, password) {
+		return ctx, errors.New("invalid username or password: " + username)
+	}
+
+	return context.WithValue(ctx, "username", username), nil
+}
+
+func parseProxyAuth(auth string) (string, string, bool) {
+	const prefix = "Basic "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+
+	username, password, ok := strings.Cut(string(decoded), ":")
+	return username, password, ok
+}
+
+func removeHopByHopHeaders(header http.Header) {
+	// Connection 首部中列出的字段同样是逐跳首部
+	for _, value := range header["Connection"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+// connectErrorStatus 建立出站连接失败时的响应状态码
+func connectErrorStatus(err error) int {
+	switch connectErrorCode(err) {
+	case network.ForbiddenCode:
+		return http.StatusForbidden
+	case network.TimeoutCode:
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusBadGateway
+}
+
+func writeHttpError(w io.Writer, code int, req *http.Request) {
+	res := &http.Response{
+		StatusCode: code,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Request:    req,
+		Close:      true,
+	}
+
+	if code == http.StatusProxyAuthRequired {
+		res.Header.Set("Proxy-Authenticate", `Basic realm="ssp"`)
+	}
+
+	res.Write(w)
+}
+
+func hostPort(host string, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
+}
+
+// bufferedConn 带读缓存的连接，用于预读首字节判断协议
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func newBufferedConn(conn net.Conn) *bufferedConn {
+	return &bufferedConn{Conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+func (b *bufferedConn) CloseWrite() error {
+	if conn, ok := b.Conn.(interface{ CloseWrite() error }); ok {
+		return conn.CloseWrite()
+	}
+	return b.Conn.Close()
+}
@@ -0,0 +1,318 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// directClient 所有目标直连的客户端，不需要连接服务端
+func directClient() *Client {
+	c := &Client{}
+	c.SetRouter(&Router{rules: []routeRule{{kind: ruleMatch, action: RouteDirect}}})
+
+	return c
+}
+
+// startHttpProxy 在 net.Pipe 上处理代理请求，返回客户端一端的连接及读缓存
+func startHttpProxy(t *testing.T, h *HttpProxy) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	server, peer := net.Pipe()
+	t.Cleanup(func() { peer.Close() })
+
+	ctx := context.WithValue(context.Background(), "traceId", "http-test")
+	go h.Process(ctx, newBufferedConn(server))
+
+	return peer, bufio.NewReader(peer)
+}
+
+func basicAuth(username string, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func TestRemoveHopByHopHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   http.Header
+	}{
+		{
+			name: "hop-by-hop headers",
+			header: http.Header{
+				"Connection":          {"keep-alive"},
+				"Proxy-Connection":    {"keep-alive"},
+				"Keep-Alive":          {"timeout=5"},
+				"Proxy-Authorization": {"Basic eA=="},
+				"Te":                  {"trailers"},
+				"Transfer-Encoding":   {"chunked"},
+				"Upgrade":             {"websocket"},
+				"Accept":              {"*/*"},
+			},
+			want: http.Header{"Accept": {"*/*"}},
+		},
+		{
+			name: "headers listed in connection",
+			header: http.Header{
+				"Connection": {"X-Foo, x-bar"},
+				"X-Foo":      {"1"},
+				"X-Bar":      {"2"},
+				"X-Baz":      {"3"},
+			},
+			want: http.Header{"X-Baz": {"3"}},
+		},
+		{
+			name: "multiple connection headers",
+			header: http.Header{
+				"Connection": {"X-Foo", " , X-Bar ,"},
+				"X-Foo":      {"1"},
+				"X-Bar":      {"2"},
+			},
+			want: http.Header{},
+		},
+		{
+			name:   "end-to-end headers kept",
+			header: http.Header{"Host": {"example.com"}, "Cookie": {"a=b"}},
+			want:   http.Header{"Host": {"example.com"}, "Cookie": {"a=b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removeHopByHopHeaders(tt.header)
+			if !reflect.DeepEqual(tt.header, tt.want) {
+				t.Errorf("removeHopByHopHeaders() = %v, want %v", tt.header, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostPort(t *testing.T) {
+	tests := []struct {
+		host        string
+		defaultPort string
+		want        string
+	}{
+		{"example.com", "80", "example.com:80"},
+		{"example.com:8080", "80", "example.com:8080"},
+		{"example.com", "443", "example.com:443"},
+		{"192.0.2.1", "80", "192.0.2.1:80"},
+		{"[2001:db8::1]", "443", "[2001:db8::1]:443"},
+		{"[2001:db8::1]:8443", "443", "[2001:db8::1]:8443"},
+		{"2001:db8::1", "80", "[2001:db8::1]:80"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := hostPort(tt.host, tt.defaultPort); got != tt.want {
+				t.Errorf("hostPort(%q, %q) = %q, want %q", tt.host, tt.defaultPort, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHttpProxyAuth(t *testing.T) {
+	credentials := StaticCredentials{"allen": "secret"}
+
+	tests := []struct {
+		name        string
+		credentials CredentialStore
+		requireAuth bool
+		header      string
+		wantUser    string
+		wantErr     bool
+	}{
+		{"no auth configured", nil, false, "", "", false},
+		{"required without credentials", nil, true, "", "", true},
+		{"required without credentials ignores header", nil, true, basicAuth("allen", "secret"), "", true},
+		{"optional without header", credentials, false, "", "", false},
+		{"optional with valid header", credentials, false, basicAuth("allen", "secret"), "allen", false},
+		{"optional with wrong password", credentials, false, basicAuth("allen", "wrong"), "", true},
+		{"required without header", credentials, true, "", "", true},
+		{"required with valid header", credentials, true, basicAuth("allen", "secret"), "allen", false},
+		{"scheme case insensitive", credentials, true, "basic " + base64.StdEncoding.EncodeToString([]byte("allen:secret")), "allen", false},
+		{"unknown user", credentials, true, basicAuth("bob", "secret"), "", true},
+		{"other scheme", credentials, true, "Bearer token", "", true},
+		{"invalid base64", credentials, true, "Basic !!!", "", true},
+		{"missing colon", credentials, true, "Basic " + base64.StdEncoding.EncodeToString([]byte("allen")), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHttpProxy("", nil)
+			h.SetAuth(tt.credentials, tt.requireAuth)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			if tt.header != "" {
+				req.Header.Set("Proxy-Authorization", tt.header)
+			}
+
+			ctx, err := h.auth(context.Background(), req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("auth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			username, _ := ctx.Value("username").(string)
+			if username != tt.wantUser {
+				t.Errorf("auth() username = %q, want %q", username, tt.wantUser)
+			}
+		})
+	}
+}
+
+// 未通过认证时返回 407 并关闭连接，通过认证后正常转发
+func TestHttpProxyAuthRequired(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+
+	host := strings.TrimPrefix(upstream.URL, "http://")
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		wantStatus int
+	}{
+		{"get without credentials", http.MethodGet, "", http.StatusProxyAuthRequired},
+		{"get with wrong password", http.MethodGet, basicAuth("allen", "wrong"), http.StatusProxyAuthRequired},
+		{"connect without credentials", http.MethodConnect, "", http.StatusProxyAuthRequired},
+		{"get with credentials", http.MethodGet, basicAuth("allen", "secret"), http.StatusOK},
+		{"connect with credentials", http.MethodConnect, basicAuth("allen", "secret"), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHttpProxy("", directClient())
+			h.SetAuth(StaticCredentials{"allen": "secret"}, true)
+
+			conn, reader := startHttpProxy(t, h)
+
+			target := "http://" + host + "/"
+			if tt.method == http.MethodConnect {
+				target = host
+			}
+
+			req := fmt.Sprintf("%s %s HTTP/1.1\r\nHost: %s\r\n", tt.method, target, host)
+			if tt.header != "" {
+				req += "Proxy-Authorization: " + tt.header + "\r\n"
+			}
+			go io.WriteString(conn, req+"\r\n")
+
+			res, err := http.ReadResponse(reader, nil)
+			if err != nil {
+				t.Fatalf("ReadResponse() error = %v", err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusProxyAuthRequired {
+				return
+			}
+
+			if got := res.Header.Get("Proxy-Authenticate"); got != `Basic realm="ssp"` {
+				t.Errorf("Proxy-Authenticate = %q", got)
+			}
+			if _, err := io.ReadAll(reader); err != nil {
+				t.Errorf("connection not closed after 407: %v", err)
+			}
+		})
+	}
+}
+
+// 客户端连接在多个请求间保持，每个请求使用单独的上游连接，逐跳首部不转发
+func TestHttpForwardKeepAlive(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// 上游每个连接处理一个请求，收到的逐跳首部放入响应，响应中带有逐跳首部
+	var upstreamConns atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			upstreamConns.Add(1)
+
+			go func() {
+				defer conn.Close()
+
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+
+				received := req.Header.Get("X-Hop") + req.Header.Get("Proxy-Authorization")
+				fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nConnection: X-Upstream-Hop\r\nX-Upstream-Hop: 1\r\nKeep-Alive: timeout=5\r\n"+
+					"X-Received-Hop: %s\r\nContent-Length: %d\r\n\r\n%s", received, len(req.URL.Path), req.URL.Path)
+			}()
+		}
+	}()
+
+	upstream := "http://" + listener.Addr().String()
+
+	h := NewHttpProxy("", directClient())
+	conn, reader := startHttpProxy(t, h)
+
+	request := func(path string, extra string) *http.Response {
+		t.Helper()
+
+		req := fmt.Sprintf("GET %s%s HTTP/1.1\r\nHost: example.com\r\nConnection: X-Hop\r\nX-Hop: 1\r\nProxy-Authorization: Basic eA==\r\n%s\r\n", upstream, path, extra)
+		go io.WriteString(conn, req)
+
+		res, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatalf("ReadResponse(%s) error = %v", path, err)
+		}
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil || string(body) != path {
+			t.Fatalf("response body = %q, %v, want %q", body, err, path)
+		}
+
+		if got := res.Header.Get("X-Received-Hop"); got != "" {
+			t.Errorf("upstream received hop-by-hop headers: %q", got)
+		}
+		if got := res.Header.Get("X-Upstream-Hop") + res.Header.Get("Keep-Alive"); got != "" {
+			t.Errorf("client received hop-by-hop headers: %q", got)
+		}
+
+		return res
+	}
+
+	for _, path := range []string{"/a", "/b"} {
+		if res := request(path, ""); res.Close {
+			t.Fatalf("response to %s closes the client connection", path)
+		}
+	}
+
+	if res := request("/c", "Connection: close\r\n"); !res.Close {
+		t.Error("response to Connection: close keeps the client connection")
+	}
+
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Errorf("read after Connection: close error = %v, want EOF", err)
+	}
+
+	if got := upstreamConns.Load(); got != 3 {
+		t.Errorf("upstream connections = %d, want 3", got)
+	}
+}
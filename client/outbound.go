@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/ssp/network"
+	"github.com/ssp/util"
+)
+
+// errRejected 目标被本地路由规则拒绝
+var errRejected = errors.New("rejected by route rules")
+
+// Outbound 到目标的出站连接，经隧道时为通道，直连时为本地建立的连接
+type Outbound struct {
+	io.ReadWriteCloser
+
+	// 通道为服务端连接目标使用的本地地址，直连时为本地连接的地址，FastOpen 的通道为空
+	BindAddr string
+
+	channel *network.Channel
+	conn    net.Conn
+}
+
+// Forward 双向转发 src 与出站连接的数据，转发结束后关闭两端
+func (o *Outbound) Forward(ctx context.Context, src net.Conn) {
+	traceId, _ := ctx.Value("traceId").(string)
+
+	target := network.NewRemoteConn(src)
+	target.TraceId = traceId
+
+	if o.channel != nil {
+		network.FlowForward(ctx, o.channel, target)
+		return
+	}
+
+	direct := network.NewRemoteConn(o.conn)
+	direct.TraceId = traceId
+	network.ConnForward(ctx, direct, target)
+}
+
+// Dial 按路由规则直连、经隧道连接或拒绝目标 addr，拒绝时返回 errRejected；
+// FastOpen 时通道立即返回，服务端连接失败的原因由之后的读写返回
+func (c *Client) Dial(ctx context.Context, addr string) (*Outbound, error) {
+	action := RouteProxy
+	if router := c.router.Load(); router != nil {
+		action = router.Route(ctx, addr)
+	}
+
+	traceId, _ := ctx.Value("traceId").(string)
+	util.Debugf("%s,Route %s: %s\n", traceId, addr, action)
+
+	switch action {
+	case RouteReject:
+		return nil, errRejected
+
+	case RouteDirect:
+		dialer := net.Dialer{Timeout: c.RpcTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Outbound{ReadWriteCloser: conn, BindAddr: conn.LocalAddr().String(), conn: conn}, nil
+	}
+
+	open := c.BuildNewChannel
+	if c.FastOpen {
+		open = c.OpenChannel
+	}
+
+	channel, err := open(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Outbound{ReadWriteCloser: channel, BindAddr: channel.BindAddr, channel: channel}, nil
+}
+
+// SetRouter 设置路由规则，对之后的请求生效，为空时所有目标经隧道
+func (c *Client) SetRouter(router *Router) {
+	c.router.Store(router)
+}
+
+func (c *Client) Router() *Router {
+	return c.router.Load()
+}
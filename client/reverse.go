@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/ssp/msg"
+	"github.com/ssp/network"
+	"github.com/ssp/util"
+)
+
+// ReverseForward 远程端口转发：服务端监听 Remote，接入的连接由客户端转发到 Local
+type ReverseForward struct {
+	Remote string
+	Local  string
+}
+
+// service 处理服务端发起的请求
+func (t *Tunnel) service() *network.Service {
+	service := network.NewService()
+
+	network.Register(service, network.ReverseChannelCmd, t.acceptReverse)
+
+	return service
+}
+
+// listenReverse 请求服务端监听所有远程端口转发地址，失败时只记录日志
+func (t *Tunnel) listenReverse(conn *network.Connection) {
+
+	defer util.Trace(t.traceId, "Tunnel listenReverse")()
+
+	for _, forward := range t.client.Reverse {
+		local := forward.Local
+
+		// 在连接读协程中记录监听，服务端随后发起的通道请求不会先于记录到达
+		callback := func(res proto.Message) {
+			listenRes := res.(*msg.ReverseListenRes)
+			if listenRes.Code != network.SuccessCode {
+				return
+			}
+
+			t.mutex.Lock()
+			t.reverses[listenRes.ListenerId] = local
+			t.mutex.Unlock()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), t.client.RpcTimeout)
+		res, err := conn.InvokeWithCallback(ctx, network.ReverseListenCmd, &msg.ReverseListenReq{Addr: forward.Remote, TraceId: t.traceId}, callback)
+		cancel()
+
+		if err != nil {
+			util.Errorf("Tunnel %d reverse listen %s fail:%s\n", t.Id, forward.Remote, err.Error())
+			continue
+		}
+
+		util.Infof("Tunnel %d reverse forward %s -> %s\n", t.Id, res.(*msg.ReverseListenRes).BindAddr, local)
+	}
+}
+
+// acceptReverse 服务端的远程端口转发监听接入连接，连接本地服务并注册通道
+func (t *Tunnel) acceptReverse(ctx context.Context, rpcContext *network.Context, channelReq *msg.ReverseChannelReq) (*msg.CommonRes, error) {
+
+	traceId := channelReq.TraceId
+	newCtx := context.WithValue(ctx, "traceId", traceId)
+	defer util.Trace(traceId, "Tunnel acceptReverse")()
+
+	t.mutex.RLock()
+	local, ok := t.reverses[channelReq.ListenerId]
+	t.mutex.RUnlock()
+
+	if !ok {
+		util.Errorf("%s,Unknown reverse listener:%d \n", traceId, channelReq.ListenerId)
+
+		return nil, errors.New("unknown reverse listener")
+	}
+
+	util.Infof("%s,Reverse conn from %s to %s \n", traceId, channelReq.PeerAddr, local)
+
+	dest, err := net.DialTimeout("tcp", local, t.client.RpcTimeout)
+	if err != nil {
+		util.Errorf("%s,Net Dial error:%s \n", traceId, err.Error())
+
+		return nil, err
+	}
+
+	conn := rpcContext.Conn()
+
+	channel := network.NewChannel(channelReq.ChannelId, conn)
+	channel.TraceId = traceId
+	conn.RegChannel(channel.Id, channel)
+
+	// 响应发送后开始转发
+	rpcContext.AfterReply(func() {
+		target := network.NewRemoteConn(dest)
+		target.TraceId = traceId
+		network.FlowForward(newCtx, channel, target)
+	})
+
+	return &msg.CommonRes{}, nil
+}
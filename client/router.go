@@ -0,0 +1,297 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ssp/util"
+)
+
+// RouteAction 目标的出站方式
+type RouteAction int
+
+const (
+	// 经隧道由服务端连接
+	RouteProxy RouteAction = 0
+
+	// 本地直接连接
+	RouteDirect RouteAction = 1
+
+	// 拒绝
+	RouteReject RouteAction = 2
+)
+
+func (a RouteAction) String() string {
+	switch a {
+	case RouteProxy:
+		return "PROXY"
+	case RouteDirect:
+		return "DIRECT"
+	case RouteReject:
+		return "REJECT"
+	}
+
+	return fmt.Sprintf("RouteAction(%d)", int(a))
+}
+
+func ParseRouteAction(s string) (RouteAction, error) {
+	switch strings.ToUpper(s) {
+	case "PROXY":
+		return RouteProxy, nil
+	case "DIRECT":
+		return RouteDirect, nil
+	case "REJECT":
+		return RouteReject, nil
+	}
+
+	return RouteProxy, fmt.Errorf("unknown route action %q", s)
+}
+
+// 路由规则类型
+const (
+	ruleDomain        = "DOMAIN"
+	ruleDomainSuffix  = "DOMAIN-SUFFIX"
+	ruleDomainKeyword = "DOMAIN-KEYWORD"
+	ruleIpCidr        = "IP-CIDR"
+	ruleGeoIp         = "GEOIP"
+	ruleDstPort       = "DST-PORT"
+	ruleMatch         = "MATCH"
+)
+
+type routeRule struct {
+	kind   string
+	value  string
+	cidr   *net.IPNet
+	from   int
+	to     int
+	action RouteAction
+}
+
+// Router 按规则文件决定每个目标直连、经隧道或拒绝，第一条匹配的规则生效，
+// 没有规则匹配时经隧道。规则文件每行一条规则，# 开头的行为注释：
+//
+//	DOMAIN,example.com,PROXY
+//	DOMAIN-SUFFIX,lan,DIRECT
+//	DOMAIN-KEYWORD,ads,REJECT
+//	IP-CIDR,192.168.0.0/16,DIRECT
+//	GEOIP,CN,DIRECT
+//	DST-PORT,25,REJECT
+//	MATCH,PROXY
+//
+// IP-CIDR、GEOIP 规则对域名目标在本地解析后匹配
+type Router struct {
+	// 规则文件
+	Path string
+
+	// GeoIP 数据库文件，GEOIP 规则需要
+	GeoIPPath string
+
+	rules []routeRule
+
+	geoIp *GeoIP
+
+	// 读写锁，控制对 rules、geoIp 字段的并发读写
+	mutex sync.RWMutex
+}
+
+func NewRouter(path string, geoIpPath string) (*Router, error) {
+	router := &Router{Path: path, GeoIPPath: geoIpPath}
+
+	if err := router.Reload(); err != nil {
+		return nil, err
+	}
+
+	return router, nil
+}
+
+// Reload 重新读取规则文件及 GeoIP 数据库，读取失败时保留原有规则
+func (r *Router) Reload() error {
+	rules, err := loadRouteRules(r.Path)
+	if err != nil {
+		return err
+	}
+
+	var geoIp *GeoIP
+	if r.GeoIPPath != "" {
+		if geoIp, err = OpenGeoIP(r.GeoIPPath); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.kind == ruleGeoIp && geoIp == nil {
+			return errors.New(r.Path + ": GEOIP rule requires a geoip database")
+		}
+	}
+
+	r.mutex.Lock()
+	r.rules = rules
+	r.geoIp = geoIp
+	r.mutex.Unlock()
+
+	util.Infof("Route rules loaded:%d\n", len(rules))
+
+	return nil
+}
+
+func loadRouteRules(path string) ([]routeRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []routeRule
+
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseRouteRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func parseRouteRule(line string) (routeRule, error) {
+	fields := strings.Split(line, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	rule := routeRule{kind: strings.ToUpper(fields[0])}
+
+	if rule.kind == ruleMatch {
+		if len(fields) != 2 {
+			return rule, errors.New("expected MATCH,action")
+		}
+
+		var err error
+		rule.action, err = ParseRouteAction(fields[1])
+		return rule, err
+	}
+
+	if len(fields) != 3 {
+		return rule, errors.New("expected type,value,action")
+	}
+
+	var err error
+	if rule.action, err = ParseRouteAction(fields[2]); err != nil {
+		return rule, err
+	}
+
+	rule.value = fields[1]
+
+	switch rule.kind {
+	case ruleDomain, ruleDomainSuffix, ruleDomainKeyword:
+		rule.value = strings.TrimSuffix(strings.ToLower(rule.value), ".")
+
+	case ruleIpCidr:
+		if _, rule.cidr, err = net.ParseCIDR(rule.value); err != nil {
+			return rule, err
+		}
+
+	case ruleGeoIp:
+		rule.value = strings.ToUpper(rule.value)
+
+	case ruleDstPort:
+		from, to, found := strings.Cut(rule.value, "-")
+		if !found {
+			to = from
+		}
+
+		if rule.from, err = strconv.Atoi(from); err == nil {
+			rule.to, err = strconv.Atoi(to)
+		}
+
+		if err != nil || rule.from < 0 || rule.to < rule.from || rule.to > 65535 {
+			return rule, fmt.Errorf("invalid port %q", rule.value)
+		}
+
+	default:
+		return rule, fmt.Errorf("unknown rule type %q", fields[0])
+	}
+
+	return rule, nil
+}
+
+// Route 返回目标 addr（host:port）的出站方式
+func (r *Router) Route(ctx context.Context, addr string) RouteAction {
+	host, service, err := net.SplitHostPort(addr)
+	if err != nil {
+		return RouteProxy
+	}
+
+	port, _ := strconv.Atoi(service)
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	r.mutex.RLock()
+	rules, geoIp := r.rules, r.geoIp
+	r.mutex.RUnlock()
+
+	// 域名目标在遇到第一条 IP 规则时解析，解析失败时 IP 规则不匹配
+	ip := net.ParseIP(host)
+	isDomain := ip == nil
+	resolved := !isDomain
+
+	resolve := func() net.IP {
+		if !resolved {
+			resolved = true
+			if ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host); err == nil && len(ips) > 0 {
+				ip = ips[0]
+			}
+		}
+		return ip
+	}
+
+	for _, rule := range rules {
+		matched := false
+
+		switch rule.kind {
+		case ruleDomain:
+			matched = isDomain && host == rule.value
+		case ruleDomainSuffix:
+			matched = isDomain && (host == rule.value || strings.HasSuffix(host, "."+rule.value))
+		case ruleDomainKeyword:
+			matched = isDomain && strings.Contains(host, rule.value)
+		case ruleIpCidr:
+			ip := resolve()
+			matched = ip != nil && rule.cidr.Contains(ip)
+		case ruleGeoIp:
+			ip := resolve()
+			matched = ip != nil && geoIp.Country(ip) == rule.value
+		case ruleDstPort:
+			matched = port >= rule.from && port <= rule.to
+		case ruleMatch:
+			matched = true
+		}
+
+		if matched {
+			return rule.action
+		}
+	}
+
+	return RouteProxy
+}
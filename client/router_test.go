@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRouteRule(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    routeRule
+		wantErr bool
+	}{
+		{line: "DOMAIN,Example.COM.,PROXY", want: routeRule{kind: ruleDomain, value: "example.com", action: RouteProxy}},
+		{line: "domain-suffix, lan , direct", want: routeRule{kind: ruleDomainSuffix, value: "lan", action: RouteDirect}},
+		{line: "DOMAIN-KEYWORD,Ads,REJECT", want: routeRule{kind: ruleDomainKeyword, value: "ads", action: RouteReject}},
+		{line: "IP-CIDR,192.168.0.0/16,DIRECT", want: routeRule{kind: ruleIpCidr, value: "192.168.0.0/16", action: RouteDirect}},
+		{line: "IP-CIDR,2001:db8::/32,REJECT", want: routeRule{kind: ruleIpCidr, value: "2001:db8::/32", action: RouteReject}},
+		{line: "GEOIP,cn,DIRECT", want: routeRule{kind: ruleGeoIp, value: "CN", action: RouteDirect}},
+		{line: "DST-PORT,25,REJECT", want: routeRule{kind: ruleDstPort, value: "25", from: 25, to: 25, action: RouteReject}},
+		{line: "DST-PORT,8000-8100,DIRECT", want: routeRule{kind: ruleDstPort, value: "8000-8100", from: 8000, to: 8100, action: RouteDirect}},
+		{line: "MATCH,PROXY", want: routeRule{kind: ruleMatch, action: RouteProxy}},
+
+		{line: "MATCH", wantErr: true},
+		{line: "MATCH,PROXY,DIRECT", wantErr: true},
+		{line: "DOMAIN,example.com", wantErr: true},
+		{line: "DOMAIN,example.com,ALLOW", wantErr: true},
+		{line: "URL-REGEX,ads,REJECT", wantErr: true},
+		{line: "IP-CIDR,192.168.0.1,DIRECT", wantErr: true},
+		{line: "DST-PORT,http,REJECT", wantErr: true},
+		{line: "DST-PORT,8100-8000,REJECT", wantErr: true},
+		{line: "DST-PORT,65536,REJECT", wantErr: true},
+		{line: "DST-PORT,-1,REJECT", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseRouteRule(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRouteRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			cidr := got.cidr
+			got.cidr = nil
+			if got != tt.want {
+				t.Errorf("parseRouteRule() = %+v, want %+v", got, tt.want)
+			}
+			if (cidr != nil) != (tt.want.kind == ruleIpCidr) {
+				t.Errorf("parseRouteRule() cidr = %v", cidr)
+			}
+		})
+	}
+}
+
+func TestRouterRoute(t *testing.T) {
+	lines := []string{
+		"DOMAIN,exact.example.com,DIRECT",
+		"DOMAIN-SUFFIX,lan,DIRECT",
+		"DOMAIN-KEYWORD,ads,REJECT",
+		"IP-CIDR,192.168.0.0/16,DIRECT",
+		"IP-CIDR,2001:db8::/32,REJECT",
+		"GEOIP,CN,DIRECT",
+		"DST-PORT,25,REJECT",
+	}
+
+	var rules []routeRule
+	for _, line := range lines {
+		rule, err := parseRouteRule(line)
+		if err != nil {
+			t.Fatalf("parseRouteRule(%s) error = %v", line, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	geoIp, err := parseGeoIP(buildMmdb(t, 4, 24, map[string]map[string]any{"1.0.0.0/8": country("CN")}))
+	if err != nil {
+		t.Fatalf("parseGeoIP() error = %v", err)
+	}
+
+	router := &Router{rules: rules, geoIp: geoIp}
+
+	// 取消的 context 使域名解析失败，域名目标不匹配 IP 规则
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		addr string
+		want RouteAction
+	}{
+		{"exact.example.com:443", RouteDirect},
+		{"EXACT.example.com.:443", RouteDirect},
+		{"sub.exact.example.com:443", RouteProxy},
+		{"nas.lan:80", RouteDirect},
+		{"lan:80", RouteDirect},
+		{"plan:80", RouteProxy},
+		{"ads.example.com:443", RouteReject},
+		{"192.168.1.1:80", RouteDirect},
+		{"[2001:db8::1]:443", RouteReject},
+		{"1.2.3.4:443", RouteDirect},
+		{"203.0.113.7:443", RouteProxy},
+		{"203.0.113.7:25", RouteReject},
+		{"missing-port", RouteProxy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := router.Route(ctx, tt.addr); got != tt.want {
+				t.Errorf("Route(%s) = %s, want %s", tt.addr, got, tt.want)
+			}
+		})
+	}
+
+	// 没有规则时经隧道，MATCH 之后的规则不生效
+	for _, tt := range []struct {
+		lines []string
+		want  RouteAction
+	}{
+		{nil, RouteProxy},
+		{[]string{"MATCH,REJECT", "DOMAIN,example.com,DIRECT"}, RouteReject},
+	} {
+		router := &Router{}
+		for _, line := range tt.lines {
+			rule, _ := parseRouteRule(line)
+			router.rules = append(router.rules, rule)
+		}
+
+		if got := router.Route(ctx, "example.com:443"); got != tt.want {
+			t.Errorf("Route() with rules %v = %s, want %s", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestRouterReload(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	geoIpPath := filepath.Join(dir, "country.mmdb")
+	if err := os.WriteFile(geoIpPath, buildMmdb(t, 6, 28, map[string]map[string]any{"1.0.0.0/8": country("CN")}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rulesPath := writeFile("rules.txt", "# comment\n\nDOMAIN-SUFFIX,lan,DIRECT\nGEOIP,CN,REJECT\n")
+
+	router, err := NewRouter(rulesPath, geoIpPath)
+	if err != nil {
+		t.Fatalf("NewRouter() error = %v", err)
+	}
+	if got := router.Route(context.Background(), "1.2.3.4:443"); got != RouteReject {
+		t.Errorf("Route() = %s, want %s", got, RouteReject)
+	}
+
+	invalidRules := writeFile("invalid.txt", "DOMAIN-SUFFIX,lan,DIRECT\nDST-PORT,x,REJECT\n")
+	geoIpRules := writeFile("geoip.txt", "GEOIP,CN,DIRECT\n")
+	matchRules := writeFile("match.txt", "MATCH,DIRECT\n")
+
+	tests := []struct {
+		name    string
+		rules   string
+		geoIp   string
+		wantErr string
+	}{
+		{"invalid rule", invalidRules, geoIpPath, "invalid.txt:2:"},
+		{"geoip rule without database", geoIpRules, "", "requires a geoip database"},
+		{"invalid database", matchRules, writeFile("invalid.mmdb", "not a database"), "metadata not found"},
+		{"missing rules file", filepath.Join(dir, "missing.txt"), geoIpPath, "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router.Path, router.GeoIPPath = tt.rules, tt.geoIp
+
+			err := router.Reload()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Reload() error = %v, want %q", err, tt.wantErr)
+			}
+
+			// 读取失败时保留原有规则
+			if got := router.Route(context.Background(), "1.2.3.4:443"); got != RouteReject {
+				t.Errorf("Route() after failed reload = %s, want %s", got, RouteReject)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+)
+
+// SOCKS5 认证方式
+const (
+	socks5NoAuth       byte = 0x00
+	socks5UserPassAuth byte = 0x02
+	socks5NoAcceptable byte = 0xFF
+)
+
+// RFC 1929 子协商
+const (
+	userPassVersion byte = 0x01
+	userPassSuccess byte = 0x00
+	userPassFailure byte = 0x01
+)
+
+// CredentialStore 校验 SOCKS5 用户名/密码
+type CredentialStore interface {
+	Validate(username string, password string) bool
+}
+
+// StaticCredentials 基于内存的用户名/密码集合
+type StaticCredentials map[string]string
+
+func (s StaticCredentials) Validate(username string, password string) bool {
+	pwd, ok := s[username]
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(pwd), []byte(password)) == 1
+}
+
+// selectMethod 根据客户端支持的认证方式及配置选择一种认证方式
+func selectMethod(methods []byte, credentials CredentialStore, requireAuth bool) byte {
+	offered := func(method byte) bool {
+		for _, m := range methods {
+			if m == method {
+				return true
+			}
+		}
+		return false
+	}
+
+	if credentials != nil && offered(socks5UserPassAuth) {
+		return socks5UserPassAuth
+	}
+
+	if !requireAuth && offered(socks5NoAuth) {
+		return socks5NoAuth
+	}
+
+	return socks5NoAcceptable
+}
+
+// userPassAuth 用户名/密码认证，返回认证通过的用户名
+func userPassAuth(src net.Conn, credentials CredentialStore) (string, error) {
+	buf := make([]byte, 256)
+
+	// 读取 VER 和 ULEN
+	n, err := io.ReadFull(src, buf[:2])
+	if n != 2 {
+		return "", errors.New("reading user/pass header: " + err.Error())
+	}
+
+	ver, uLen := buf[0], int(buf[1])
+	if ver != userPassVersion {
+		return "", errors.New("invalid user/pass version")
+	}
+
+	n, err = io.ReadFull(src, buf[:uLen])
+	if n != uLen {
+		return "", errors.New("reading username: " + err.Error())
+	}
+	username := string(buf[:uLen])
+
+	n, err = io.ReadFull(src, buf[:1])
+	if n != 1 {
+		return "", errors.New("reading password length: " + err.Error())
+	}
+	pLen := int(buf[0])
+
+	n, err = io.ReadFull(src, buf[:pLen])
+	if n != pLen {
+		return "", errors.New("reading password: " + err.Error())
+	}
+	password := string(buf[:pLen])
+
+	if !credentials.Validate(username, password) {
+		src.Write([]byte{userPassVersion, userPassFailure})
+		return "", errors.New("invalid username or password: " + username)
+	}
+
+	n, err = src.Write([]byte{userPassVersion, userPassSuccess})
+	if n != 2 || err != nil {
+		return "", errors.New("write user/pass rsp: " + err.Error())
+	}
+
+	return username, nil
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+)
+
+func TestSelectMethod(t *testing.T) {
+	credentials := StaticCredentials{"allen": "secret"}
+
+	tests := []struct {
+		name        string
+		methods     []byte
+		credentials CredentialStore
+		requireAuth bool
+		want        byte
+	}{
+		{"no auth offered", []byte{socks5NoAuth}, nil, false, socks5NoAuth},
+		{"user/pass preferred", []byte{socks5NoAuth, socks5UserPassAuth}, credentials, false, socks5UserPassAuth},
+		{"user/pass without credentials", []byte{socks5UserPassAuth}, nil, false, socks5NoAcceptable},
+		{"no auth when user/pass not offered", []byte{socks5NoAuth}, credentials, false, socks5NoAuth},
+		{"no auth refused", []byte{socks5NoAuth}, credentials, true, socks5NoAcceptable},
+		{"require auth with user/pass", []byte{socks5NoAuth, socks5UserPassAuth}, credentials, true, socks5UserPassAuth},
+		{"unknown methods", []byte{0x01, 0x03}, credentials, false, socks5NoAcceptable},
+		{"empty methods", nil, nil, false, socks5NoAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectMethod(tt.methods, tt.credentials, tt.requireAuth); got != tt.want {
+				t.Errorf("selectMethod() = %#x, want %#x", got, tt.want)
+			}
+		})
+	}
+}
+
+func userPassRequest(ver byte, username string, password string) []byte {
+	req := []byte{ver, byte(len(username))}
+	req = append(req, username...)
+	req = append(req, byte(len(password)))
+	return append(req, password...)
+}
+
+func TestUserPassAuth(t *testing.T) {
+	credentials := StaticCredentials{"allen": "secret", "empty": ""}
+
+	tests := []struct {
+		name     string
+		req      []byte
+		wantUser string
+		wantErr  bool
+		wantRsp  []byte
+	}{
+		{"valid", userPassRequest(userPassVersion, "allen", "secret"), "allen", false, []byte{userPassVersion, userPassSuccess}},
+		{"empty password", userPassRequest(userPassVersion, "empty", ""), "empty", false, []byte{userPassVersion, userPassSuccess}},
+		{"wrong password", userPassRequest(userPassVersion, "allen", "wrong"), "", true, []byte{userPassVersion, userPassFailure}},
+		{"unknown user", userPassRequest(userPassVersion, "bob", "secret"), "", true, []byte{userPassVersion, userPassFailure}},
+		{"invalid version", userPassRequest(0x05, "allen", "secret"), "", true, nil},
+		{"truncated password", userPassRequest(userPassVersion, "allen", "secret")[:9], "", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, peer := net.Pipe()
+			defer server.Close()
+
+			go func() {
+				peer.Write(tt.req)
+				peer.Close()
+			}()
+
+			rsp := &bytes.Buffer{}
+			conn := &recordConn{Conn: server, written: rsp}
+
+			username, err := userPassAuth(conn, credentials)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("userPassAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if username != tt.wantUser {
+				t.Errorf("userPassAuth() username = %q, want %q", username, tt.wantUser)
+			}
+			if !bytes.Equal(rsp.Bytes(), tt.wantRsp) {
+				t.Errorf("userPassAuth() rsp = %v, want %v", rsp.Bytes(), tt.wantRsp)
+			}
+		})
+	}
+}
+
+func TestSocks5Auth(t *testing.T) {
+	proxy := &Socks5Proxy{Credentials: StaticCredentials{"allen": "secret"}, RequireAuth: true}
+
+	tests := []struct {
+		name     string
+		req      []byte
+		wantRsp  []byte
+		wantUser string
+		wantErr  bool
+	}{
+		{
+			name:     "user/pass",
+			req:      append([]byte{0x05, 0x02, socks5NoAuth, socks5UserPassAuth}, userPassRequest(userPassVersion, "allen", "secret")...),
+			wantRsp:  []byte{0x05, socks5UserPassAuth, userPassVersion, userPassSuccess},
+			wantUser: "allen",
+		},
+		{
+			name:    "no auth refused",
+			req:     []byte{0x05, 0x01, socks5NoAuth},
+			wantRsp: []byte{0x05, socks5NoAcceptable},
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			req:     []byte{0x04, 0x01, socks5NoAuth},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, peer := net.Pipe()
+			defer server.Close()
+
+			go func() {
+				peer.Write(tt.req)
+				peer.Close()
+			}()
+
+			rsp := &bytes.Buffer{}
+			ctx, err := proxy.Socks5Auth(context.Background(), &recordConn{Conn: server, written: rsp})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Socks5Auth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if username, _ := ctx.Value("username").(string); username != tt.wantUser {
+				t.Errorf("Socks5Auth() username = %q, want %q", username, tt.wantUser)
+			}
+			if !bytes.Equal(rsp.Bytes(), tt.wantRsp) {
+				t.Errorf("Socks5Auth() rsp = %v, want %v", rsp.Bytes(), tt.wantRsp)
+			}
+		})
+	}
+}
+
+// recordConn 记录写入的数据，读取来自 net.Pipe 的对端
+type recordConn struct {
+	net.Conn
+	written *bytes.Buffer
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	return c.written.Write(p)
+}
@@ -6,20 +6,44 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ssp/network"
 	"github.com/ssp/util"
 )
 
+// SOCKS5 命令
+const (
+	socks5Connect      byte = 0x01
+	socks5Bind         byte = 0x02
+	socks5UdpAssociate byte = 0x03
+)
+
 type Socks5Proxy struct {
 	Addr           string
 	Proxy          net.Listener
 	RemoteEndpoint *Client
 
+	// 用户名/密码校验，为空时只支持无需认证
+	Credentials CredentialStore
+
+	// 为 true 时拒绝无需认证的客户端
+	RequireAuth bool
+
+	// 不为空时同一端口同时处理 HTTP 代理请求
+	Http *HttpProxy
+
 	// Trace ID 生成器
 	traceIdGenerator *util.Id
+
+	// 保护运行中修改的认证配置
+	authMutex sync.RWMutex
+
+	// 已停止接收新连接
+	stopped atomic.Bool
 }
 
 func NewSocks5Proxy(addr string, client *Client) *Socks5Proxy {
@@ -29,7 +53,7 @@ func NewSocks5Proxy(addr string, client *Client) *Socks5Proxy {
 func (p *Socks5Proxy) Start() {
 	server, err := net.Listen("tcp", p.Addr)
 	if err != nil {
-		log.Printf("Listen failed: %v\n", err)
+		util.Errorf("Listen failed: %v\n", err)
 		return
 	}
 
@@ -38,7 +62,32 @@ func (p *Socks5Proxy) Start() {
 
 	go p.Accept()
 
-	log.Println("Socks5 proxy client startup successfully:")
+	util.Infoln("Socks5 proxy client startup successfully:")
+}
+
+// Stop 关闭监听，不再接收新连接，已建立的连接不受影响
+func (p *Socks5Proxy) Stop() {
+	p.stopped.Store(true)
+
+	if p.Proxy != nil {
+		p.Proxy.Close()
+	}
+}
+
+// SetAuth 运行中修改认证配置，对之后的新连接生效
+func (p *Socks5Proxy) SetAuth(credentials CredentialStore, requireAuth bool) {
+	p.authMutex.Lock()
+	defer p.authMutex.Unlock()
+
+	p.Credentials = credentials
+	p.RequireAuth = requireAuth
+}
+
+func (p *Socks5Proxy) authConfig() (CredentialStore, bool) {
+	p.authMutex.RLock()
+	defer p.authMutex.RUnlock()
+
+	return p.Credentials, p.RequireAuth
 }
 
 func (p *Socks5Proxy) Accept() {
@@ -48,7 +97,10 @@ func (p *Socks5Proxy) Accept() {
 	for {
 		src, err := p.Proxy.Accept()
 		if err != nil {
-			log.Printf("Socks5 proxy client accept failed: %+v \n", err)
+			if p.stopped.Load() {
+				return
+			}
+			util.Errorf("Socks5 proxy client accept failed: %+v \n", err)
 			continue
 		}
 
@@ -59,71 +111,139 @@ func (p *Socks5Proxy) Accept() {
 	}
 }
 
-func (p *Socks5Proxy) Process(ctx context.Context, src net.Conn) {
+func (p *Socks5Proxy) Process(ctx context.Context, conn net.Conn) {
 	traceId := ctx.Value("traceId").(string)
 
 	defer util.Trace(traceId, "Client Process")()
 
-	log.Printf("%s,New conn:%s \n", traceId, src.RemoteAddr())
+	util.Infof("%s,New conn:%s \n", traceId, conn.RemoteAddr())
+
+	// 根据首字节判断协议，SOCKS5 首字节为版本号 0x05
+	src := newBufferedConn(conn)
+	first, err := src.reader.Peek(1)
+	if err != nil {
+		util.Errorf("%s,read first byte error:%s\n", traceId, err.Error())
+		src.Close()
+		return
+	}
+
+	if first[0] != 0x05 && p.Http != nil {
+		p.Http.Process(ctx, src)
+		return
+	}
 
-	if err := p.Socks5Auth(src); err != nil {
-		log.Printf("%s,auth error:%s\n", traceId, err.Error())
+	ctx, err = p.Socks5Auth(ctx, src)
+	if err != nil {
+		util.Errorf("%s,auth error:%s\n", traceId, err.Error())
 		src.Close()
 		return
 	}
 
-	channel, err := p.Socks5Connect(ctx, src)
+	if username, _ := ctx.Value("username").(string); username != "" {
+		util.Infof("%s,Authenticated user:%s \n", traceId, username)
+	}
+
+	cmd, destAddrPort, err := p.Socks5Request(src)
 	if err != nil {
-		log.Printf("%s,connect error:%s\n", traceId, err.Error())
+		util.Errorf("%s,request error:%s\n", traceId, err.Error())
 		src.Close()
 		return
 	}
 
-	target := network.NewRemoteConn(src)
-	target.TraceId = traceId
-	network.FlowForward(ctx, channel, target)
+	switch cmd {
+	case socks5Connect:
+		outbound, err := p.Socks5Connect(ctx, src, destAddrPort)
+		if err != nil {
+			util.Errorf("%s,connect error:%s\n", traceId, err.Error())
+			src.Close()
+			return
+		}
+
+		outbound.Forward(ctx, src)
+
+	case socks5Bind:
+		channel, err := p.Socks5Bind(ctx, src, destAddrPort)
+		if err != nil {
+			util.Errorf("%s,bind error:%s\n", traceId, err.Error())
+			src.Close()
+			return
+		}
+
+		target := network.NewRemoteConn(src)
+		target.TraceId = traceId
+		network.FlowForward(ctx, channel, target)
+
+	case socks5UdpAssociate:
+		if err := p.Socks5UdpAssociate(ctx, src); err != nil {
+			util.Errorf("%s,udp associate error:%s\n", traceId, err.Error())
+		}
+		src.Close()
+
+	default:
+		util.Infof("%s,unsupported cmd:%d\n", traceId, cmd)
+		src.Write(buildSocks5Reply(0x07, ""))
+		src.Close()
+	}
 }
 
-func (p *Socks5Proxy) Socks5Auth(src net.Conn) (err error) {
+func (p *Socks5Proxy) Socks5Auth(ctx context.Context, src net.Conn) (context.Context, error) {
 	buf := make([]byte, 256)
 
 	// 读取 VER 和 NMETHODS
 	n, err := io.ReadFull(src, buf[:2])
 	if n != 2 {
-		return errors.New("reading header: " + err.Error())
+		return ctx, errors.New("reading header: " + err.Error())
 	}
 
 	ver, nMethods := int(buf[0]), int(buf[1])
 	if ver != 5 {
-		return errors.New("invalid version")
+		return ctx, errors.New("invalid version")
 	}
 
 	// 读取 METHODS 列表
 	n, err = io.ReadFull(src, buf[:nMethods])
 	if n != nMethods {
-		return errors.New("reading methods: " + err.Error())
+		return ctx, errors.New("reading methods: " + err.Error())
 	}
 
-	//无需认证
-	n, err = src.Write([]byte{0x05, 0x00})
+	credentials, requireAuth := p.authConfig()
+	method := selectMethod(buf[:nMethods], credentials, requireAuth)
+
+	n, err = src.Write([]byte{0x05, method})
 	if n != 2 || err != nil {
-		return errors.New("write rsp: " + err.Error())
+		return ctx, errors.New("write rsp: " + err.Error())
 	}
 
-	return nil
+	switch method {
+	case socks5NoAuth:
+		//无需认证
+		return ctx, nil
+
+	case socks5UserPassAuth:
+		username, err := userPassAuth(src, credentials)
+		if err != nil {
+			return ctx, err
+		}
+
+		return context.WithValue(ctx, "username", username), nil
+
+	default:
+		return ctx, errors.New("no acceptable methods")
+	}
 }
 
-func (p *Socks5Proxy) Socks5Connect(ctx context.Context, src net.Conn) (*network.Channel, error) {
+// Socks5Request 读取 SOCKS5 请求，返回命令及目标地址
+func (p *Socks5Proxy) Socks5Request(src net.Conn) (byte, string, error) {
 	buf := make([]byte, 256)
 
 	n, err := io.ReadFull(src, buf[:4])
 	if n != 4 {
-		return nil, errors.New("read header: " + err.Error())
+		return 0, "", errors.New("read header: " + err.Error())
 	}
 
 	ver, cmd, _, atyp := buf[0], buf[1], buf[2], buf[3]
-	if ver != 5 || cmd != 1 {
-		return nil, errors.New("invalid ver/cmd")
+	if ver != 5 {
+		return 0, "", errors.New("invalid ver")
 	}
 
 	addr := ""
@@ -131,51 +251,59 @@ func (p *Socks5Proxy) Socks5Connect(ctx context.Context, src net.Conn) (*network
 	case 1:
 		n, err = io.ReadFull(src, buf[:4])
 		if n != 4 {
-			return nil, errors.New("invalid IPv4: " + err.Error())
+			return 0, "", errors.New("invalid IPv4: " + err.Error())
 		}
 		addr = fmt.Sprintf("%d.%d.%d.%d", buf[0], buf[1], buf[2], buf[3])
 
 	case 3:
 		n, err = io.ReadFull(src, buf[:1])
 		if n != 1 {
-			return nil, errors.New("invalid hostname: " + err.Error())
+			return 0, "", errors.New("invalid hostname: " + err.Error())
 		}
 		addrLen := int(buf[0])
 
 		n, err = io.ReadFull(src, buf[:addrLen])
 		if n != addrLen {
-			return nil, errors.New("invalid hostname: " + err.Error())
+			return 0, "", errors.New("invalid hostname: " + err.Error())
 		}
 		addr = string(buf[:addrLen])
 
 	case 4:
-		return nil, errors.New("IPv6: no supported yet")
+		n, err = io.ReadFull(src, buf[:16])
+		if n != 16 {
+			return 0, "", errors.New("invalid IPv6: " + err.Error())
+		}
+		addr = net.IP(buf[:16]).String()
 
 	default:
-		return nil, errors.New("invalid atyp")
+		src.Write(buildSocks5Reply(0x08, ""))
+		return 0, "", errors.New("invalid atyp")
 	}
 
 	n, err = io.ReadFull(src, buf[:2])
 	if n != 2 {
-		return nil, errors.New("read port: " + err.Error())
+		return 0, "", errors.New("read port: " + err.Error())
 	}
 	port := binary.BigEndian.Uint16(buf[:2])
 
-	destAddrPort := fmt.Sprintf("%s:%d", addr, port)
+	return cmd, net.JoinHostPort(addr, strconv.Itoa(int(port))), nil
+}
+
+func (p *Socks5Proxy) Socks5Connect(ctx context.Context, src net.Conn, destAddrPort string) (*Outbound, error) {
 
-	// dest, err := net.Dial("tcp", destAddrPort)
-	// 建立远程通道
+	// 按路由规则直连或建立远程通道
 	traceId := ctx.Value("traceId").(string)
-	log.Printf("%s,Connect %s\n", traceId, destAddrPort)
-	dest, err := p.RemoteEndpoint.BuildNewChannel(ctx, destAddrPort)
+	util.Infof("%s,Connect %s\n", traceId, destAddrPort)
+	dest, err := p.RemoteEndpoint.Dial(ctx, destAddrPort)
 
 	if err != nil {
-		log.Printf("%s,Connect %s failed\n", traceId, destAddrPort)
-		src.Write([]byte{0x05, 0x04, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		util.Errorf("%s,Connect %s failed\n", traceId, destAddrPort)
+
+		src.Write(buildSocks5Reply(socks5Rep(connectErrorCode(err)), ""))
 		return nil, errors.New("dial dst: " + err.Error())
 	}
 
-	n, err = src.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	_, err = src.Write(buildSocks5Reply(0x00, dest.BindAddr))
 	if err != nil {
 		dest.Close()
 		return nil, errors.New("write rsp: " + err.Error())
@@ -183,3 +311,92 @@ func (p *Socks5Proxy) Socks5Connect(ctx context.Context, src net.Conn) (*network
 
 	return dest, nil
 }
+
+// Socks5Bind 处理 BIND 请求，依次发送监听地址及对端地址两个应答
+func (p *Socks5Proxy) Socks5Bind(ctx context.Context, src net.Conn, destAddrPort string) (*network.Channel, error) {
+
+	traceId := ctx.Value("traceId").(string)
+	util.Infof("%s,Bind %s\n", traceId, destAddrPort)
+
+	channel, err := p.RemoteEndpoint.BuildBindChannel(ctx, destAddrPort)
+	if err != nil {
+		util.Errorf("%s,Bind %s failed\n", traceId, destAddrPort)
+		src.Write(buildSocks5Reply(0x01, ""))
+		return nil, errors.New("bind: " + err.Error())
+	}
+
+	// 第一个应答：服务端监听地址
+	_, err = src.Write(buildSocks5Reply(0x00, channel.BindAddr))
+	if err != nil {
+		channel.Close()
+		return nil, errors.New("write rsp: " + err.Error())
+	}
+
+	peerAddr, err := p.RemoteEndpoint.AcceptBindChannel(ctx, channel)
+	if err != nil {
+		channel.Close()
+		src.Write(buildSocks5Reply(0x04, ""))
+		return nil, errors.New("bind accept: " + err.Error())
+	}
+
+	// 第二个应答：对端地址
+	_, err = src.Write(buildSocks5Reply(0x00, peerAddr))
+	if err != nil {
+		channel.Close()
+		return nil, errors.New("write rsp: " + err.Error())
+	}
+
+	return channel, nil
+}
+
+// connectErrorCode 建立出站连接失败的原因，本地路由规则拒绝时为 ForbiddenCode
+func connectErrorCode(err error) int32 {
+	if errors.Is(err, errRejected) {
+		return network.ForbiddenCode
+	}
+
+	return network.DialErrorCode(err)
+}
+
+// socks5Rep 连接失败原因对应的 SOCKS5 应答（RFC 1928）
+func socks5Rep(code int32) byte {
+	switch code {
+	case network.ForbiddenCode:
+		return 0x02
+	case network.NetUnreachableCode:
+		return 0x03
+	case network.HostUnreachableCode, network.ResolveFailCode:
+		return 0x04
+	case network.ConnRefusedCode:
+		return 0x05
+	case network.TimeoutCode:
+		return 0x06
+	}
+
+	return 0x01
+}
+
+// buildSocks5Reply 构造 SOCKS5 应答，bindAddr 为空或无法解析时使用 0.0.0.0:0
+func buildSocks5Reply(rep byte, bindAddr string) []byte {
+	reply := []byte{0x05, rep, 0x00}
+
+	ip := net.IPv4zero
+	port := 0
+
+	if host, portStr, err := net.SplitHostPort(bindAddr); err == nil {
+		if bindIp := net.ParseIP(host); bindIp != nil {
+			ip = bindIp
+			port, _ = strconv.Atoi(portStr)
+		}
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		reply = append(reply, 0x01)
+		reply = append(reply, ip4...)
+	} else {
+		reply = append(reply, 0x04)
+		reply = append(reply, ip.To16()...)
+	}
+
+	return binary.BigEndian.AppendUint16(reply, uint16(port))
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ssp/network"
+)
+
+func TestSocks5Rep(t *testing.T) {
+	tests := []struct {
+		code int32
+		want byte
+	}{
+		{network.ForbiddenCode, 0x02},
+		{network.NetUnreachableCode, 0x03},
+		{network.HostUnreachableCode, 0x04},
+		{network.ResolveFailCode, 0x04},
+		{network.ConnRefusedCode, 0x05},
+		{network.TimeoutCode, 0x06},
+		{network.FailCode, 0x01},
+		{network.UnsupportedCode, 0x01},
+		{network.AuthFailCode, 0x01},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.code), func(t *testing.T) {
+			if got := socks5Rep(tt.code); got != tt.want {
+				t.Errorf("socks5Rep(%d) = %#x, want %#x", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantRep    byte
+		wantStatus int
+	}{
+		{"route rejected", fmt.Errorf("dial: %w", errRejected), 0x02, http.StatusForbidden},
+		{"acl rejected", &network.RemoteError{Code: network.ForbiddenCode}, 0x02, http.StatusForbidden},
+		{"resolve fail", &network.RemoteError{Code: network.ResolveFailCode}, 0x04, http.StatusBadGateway},
+		{"refused", &network.RemoteError{Code: network.ConnRefusedCode}, 0x05, http.StatusBadGateway},
+		{"remote timeout", &network.RemoteError{Code: network.TimeoutCode}, 0x06, http.StatusGatewayTimeout},
+		{"rpc timeout", network.ErrRpcTimeout, 0x06, http.StatusGatewayTimeout},
+		{"other", errors.New("boom"), 0x01, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := socks5Rep(connectErrorCode(tt.err)); got != tt.wantRep {
+				t.Errorf("socks5 rep = %#x, want %#x", got, tt.wantRep)
+			}
+			if got := connectErrorStatus(tt.err); got != tt.wantStatus {
+				t.Errorf("connectErrorStatus() = %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBuildSocks5Reply(t *testing.T) {
+	tests := []struct {
+		name     string
+		rep      byte
+		bindAddr string
+		want     []byte
+	}{
+		{"ipv4", 0x00, "192.0.2.1:1080", []byte{5, 0, 0, 1, 192, 0, 2, 1, 0x04, 0x38}},
+		{"ipv6", 0x00, "[2001:db8::1]:80", []byte{5, 0, 0, 4, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 80}},
+		{"empty", 0x05, "", []byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}},
+		{"domain", 0x01, "example.com:80", []byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildSocks5Reply(tt.rep, tt.bindAddr); !bytes.Equal(got, tt.want) {
+				t.Errorf("buildSocks5Reply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
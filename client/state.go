@@ -0,0 +1,79 @@
+package client
+
+import (
+	"sync"
+)
+
+func (f ClientFlag) String() string {
+	switch f {
+	case Init:
+		return "Init"
+	case Connected:
+		return "Connected"
+	case UnConnected:
+		return "UnConnected"
+	case Ready:
+		return "Ready"
+	case UnReady:
+		return "UnReady"
+	case Stopped:
+		return "Stopped"
+	}
+
+	return "Unknown"
+}
+
+// StateEvent 隧道状态变化
+type StateEvent struct {
+	// 服务端地址
+	Server string
+
+	// 隧道编号
+	Tunnel int
+
+	From ClientFlag
+	To   ClientFlag
+}
+
+// StateListener 状态变化回调，在状态变化的协程中同步调用，不能阻塞
+type StateListener func(event StateEvent)
+
+type listeners struct {
+	sync.RWMutex
+
+	nextId int
+	items  map[int]StateListener
+}
+
+// Subscribe 订阅所有隧道的状态变化，返回取消订阅的函数
+func (c *Client) Subscribe(listener StateListener) func() {
+	c.listeners.Lock()
+	defer c.listeners.Unlock()
+
+	if c.listeners.items == nil {
+		c.listeners.items = map[int]StateListener{}
+	}
+
+	id := c.listeners.nextId
+	c.listeners.nextId++
+	c.listeners.items[id] = listener
+
+	return func() {
+		c.listeners.Lock()
+		delete(c.listeners.items, id)
+		c.listeners.Unlock()
+	}
+}
+
+func (c *Client) notify(event StateEvent) {
+	c.listeners.RLock()
+	items := make([]StateListener, 0, len(c.listeners.items))
+	for _, listener := range c.listeners.items {
+		items = append(items, listener)
+	}
+	c.listeners.RUnlock()
+
+	for _, listener := range items {
+		listener(event)
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+)
+
+// TLSOptions 客户端与服务端之间的 TLS 参数
+type TLSOptions struct {
+	// CA 证书文件，为空时使用系统根证书
+	CA string
+
+	// 校验服务端证书使用的名称，为空时使用服务端地址中的主机名
+	ServerName string
+
+	// 服务端证书公钥（SubjectPublicKeyInfo）的 SHA-256 指纹，十六进制；
+	// 不为空且未配置 CA 时只校验指纹，不校验证书链
+	Pins []string
+
+	// 客户端证书及私钥，用于双向认证
+	Cert string
+	Key  string
+}
+
+// NewTLSConfig 根据参数构造客户端 TLS 配置
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: opts.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if opts.CA != "" {
+		pool, err := loadCertPool(opts.CA)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.Cert != "" || opts.Key != "" {
+		cert, err := tls.LoadX509KeyPair(opts.Cert, opts.Key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(opts.Pins) > 0 {
+		pins := map[string]bool{}
+		for _, pin := range opts.Pins {
+			pins[strings.ToLower(strings.ReplaceAll(pin, ":", ""))] = true
+		}
+
+		// 只配置指纹时由 VerifyConnection 完成校验
+		if opts.CA == "" {
+			config.InsecureSkipVerify = true
+		}
+
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("tls: server sent no certificate")
+			}
+
+			if !pins[SpkiFingerprint(state.PeerCertificates[0])] {
+				return errors.New("tls: server certificate does not match any pin")
+			}
+
+			return nil
+		}
+	}
+
+	return config, nil
+}
+
+// SpkiFingerprint 证书公钥的 SHA-256 指纹，十六进制
+func SpkiFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New(path + ": no certificates found")
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ssp/internal/testcert"
+)
+
+func TestNewTLSConfigServerVerify(t *testing.T) {
+	serverCert := testcert.New(t, "server")
+	other := testcert.New(t, "other")
+
+	pin := SpkiFingerprint(serverCert.Cert)
+
+	// 带冒号的大写指纹
+	var colonPin []string
+	for i := 0; i < len(pin); i += 2 {
+		colonPin = append(colonPin, strings.ToUpper(pin[i:i+2]))
+	}
+
+	tests := []struct {
+		name    string
+		opts    TLSOptions
+		wantErr bool
+	}{
+		{"ca", TLSOptions{CA: serverCert.CertPath, ServerName: "localhost"}, false},
+		{"ca with wrong server name", TLSOptions{CA: serverCert.CertPath, ServerName: "example.com"}, true},
+		{"other ca", TLSOptions{CA: other.CertPath, ServerName: "localhost"}, true},
+		{"system roots", TLSOptions{ServerName: "localhost"}, true},
+		{"pinned", TLSOptions{Pins: []string{pin}}, false},
+		{"pinned with colons", TLSOptions{Pins: []string{strings.Join(colonPin, ":")}}, false},
+		{"one of pins", TLSOptions{Pins: []string{SpkiFingerprint(other.Cert), pin}}, false},
+		{"unpinned", TLSOptions{Pins: []string{SpkiFingerprint(other.Cert)}}, true},
+		{"ca and pin", TLSOptions{CA: serverCert.CertPath, ServerName: "localhost", Pins: []string{pin}}, false},
+		{"ca and wrong pin", TLSOptions{CA: serverCert.CertPath, ServerName: "localhost", Pins: []string{SpkiFingerprint(other.Cert)}}, true},
+		{"pin but wrong ca", TLSOptions{CA: other.CertPath, ServerName: "localhost", Pins: []string{pin}}, true},
+	}
+
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{serverCert.TLSCert}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConfig, err := NewTLSConfig(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := testcert.Handshake(t, serverConfig, clientConfig); (err != nil) != tt.wantErr {
+				t.Errorf("handshake error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfigClientCert(t *testing.T) {
+	serverCert := testcert.New(t, "server")
+	clientCert := testcert.New(t, "client")
+	other := testcert.New(t, "other")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert.Cert)
+
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert.TLSCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+
+	tests := []struct {
+		name    string
+		cert    *testcert.Cert
+		wantErr bool
+	}{
+		{"allowed client cert", clientCert, false},
+		{"disallowed client cert", other, true},
+		{"no client cert", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := TLSOptions{Pins: []string{SpkiFingerprint(serverCert.Cert)}}
+			if tt.cert != nil {
+				opts.Cert, opts.Key = tt.cert.CertPath, tt.cert.KeyPath
+			}
+
+			clientConfig, err := NewTLSConfig(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := testcert.Handshake(t, serverConfig, clientConfig); (err != nil) != tt.wantErr {
+				t.Errorf("handshake error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfigInvalid(t *testing.T) {
+	cert := testcert.New(t, "client")
+
+	tests := []struct {
+		name string
+		opts TLSOptions
+	}{
+		{"missing ca", TLSOptions{CA: filepath.Join(t.TempDir(), "missing.pem")}},
+		{"ca without certificates", TLSOptions{CA: cert.KeyPath}},
+		{"cert without key", TLSOptions{Cert: cert.CertPath}},
+		{"mismatched key", TLSOptions{Cert: cert.CertPath, Key: testcert.New(t, "other").KeyPath}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTLSConfig(tt.opts); err == nil {
+				t.Error("NewTLSConfig() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/ssp/msg"
+	"github.com/ssp/network"
+	"github.com/ssp/util"
+)
+
+// Tunnel 与服务端之间的一条已认证连接，每条隧道独立重连
+type Tunnel struct {
+	// 隧道编号，从 0 开始
+	Id int
+
+	client *Client
+
+	// 所属的服务端
+	upstream *Upstream
+
+	mutex sync.RWMutex
+
+	conn *network.Connection
+
+	flag ClientFlag
+
+	// 当前连接上远程端口转发监听 id 对应的本地地址
+	reverses map[uint32]string
+
+	traceId string
+}
+
+func newTunnel(id int, client *Client, upstream *Upstream) *Tunnel {
+	return &Tunnel{
+		Id:       id,
+		client:   client,
+		upstream: upstream,
+		flag:     Init,
+		traceId:  fmt.Sprintf("%s,%s,tunnel:%d", client.traceId, upstream.Addr, id),
+	}
+}
+
+// Connect 建立连接并登录，成功时返回 true
+func (t *Tunnel) Connect() bool {
+
+	defer util.Trace(t.traceId, "Tunnel Connect")()
+
+	// 关闭未登录成功的旧连接，避免读写协程泄漏
+	if old := t.Conn(); old != nil {
+		old.Close()
+	}
+
+	conn, err := t.client.dial(t.upstream.Addr)
+	if err != nil {
+		util.Errorf("Tunnel %d connect remote server:%s fail...\n", t.Id, t.upstream.Addr)
+		t.setFlag(UnConnected)
+		return false
+	}
+
+	util.Infof("Tunnel %d connect remote server:%s success...\n", t.Id, t.upstream.Addr)
+
+	connection := network.NewConnectionWithConfig(conn, t.client.ConnConfig)
+
+	connection.SetService(t.service())
+
+	t.mutex.Lock()
+	t.conn = connection
+	t.reverses = map[uint32]string{}
+	t.mutex.Unlock()
+
+	t.setFlag(Connected)
+
+	go connection.Read()
+	go connection.Write()
+	go connection.PingPongAndTimeout()
+
+	t.login(connection)
+
+	if !t.Available() {
+		connection.Close()
+		return false
+	}
+
+	if t.Id == 0 {
+		t.listenReverse(connection)
+	}
+
+	return true
+}
+
+// Reconnect 等待连接关闭后按退避策略重新连接，达到重连次数或时长上限或客户端关闭时返回
+func (t *Tunnel) Reconnect() {
+	for {
+		// 服务端发送 GOAWAY 后连接仍在排空，等待其关闭后再重连
+		if conn := t.Conn(); conn != nil && !conn.Closed() && t.Flag() == Ready {
+			select {
+			case <-conn.Done():
+			case <-t.client.done:
+				return
+			}
+
+			util.Infof("Tunnel %d of %s was close,reconnect ......\n", t.Id, t.upstream.Addr)
+			t.setFlag(UnConnected)
+		}
+
+		select {
+		case <-t.client.done:
+			return
+		default:
+		}
+
+		if !t.reconnect() {
+			select {
+			case <-t.client.done:
+				return
+			default:
+			}
+
+			util.Errorf("Tunnel %d of %s stop reconnecting.\n", t.Id, t.upstream.Addr)
+			t.setFlag(Stopped)
+			return
+		}
+	}
+}
+
+// reconnect 按退避策略重连直到成功，超过上限时返回 false
+func (t *Tunnel) reconnect() bool {
+	backoff := t.client.Backoff
+	start := time.Now()
+
+	for attempt := 0; backoff.MaxAttempts <= 0 || attempt < backoff.MaxAttempts; attempt++ {
+		interval := backoff.Interval(attempt)
+		if backoff.MaxElapsed > 0 && time.Since(start)+interval > backoff.MaxElapsed {
+			return false
+		}
+
+		util.Infof("Tunnel %d of %s reconnect after %s,attempt:%d\n", t.Id, t.upstream.Addr, interval, attempt+1)
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-t.client.done:
+			timer.Stop()
+			return false
+		}
+
+		if t.Connect() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Available 已登录、连接未关闭且服务端未发送 GOAWAY
+func (t *Tunnel) Available() bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.flag == Ready && t.conn != nil && !t.conn.Closed() && !t.conn.RemoteGoAway()
+}
+
+// Conn 当前使用的连接，重连后会变化
+func (t *Tunnel) Conn() *network.Connection {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.conn
+}
+
+func (t *Tunnel) Flag() ClientFlag {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.flag
+}
+
+// setFlag 修改状态并通知订阅者
+func (t *Tunnel) setFlag(flag ClientFlag) {
+	t.mutex.Lock()
+	from := t.flag
+	t.flag = flag
+	t.mutex.Unlock()
+
+	if from != flag {
+		t.client.notify(StateEvent{Server: t.upstream.Addr, Tunnel: t.Id, From: from, To: flag})
+	}
+}
+
+func (t *Tunnel) login(conn *network.Connection) {
+
+	defer util.Trace(t.traceId, "Tunnel Login")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.client.RpcTimeout)
+	defer cancel()
+
+	// 登录请求包含密码，不打印消息内容
+	loginReq := &msg.LoginReq{Name: t.client.LoginName, Pwd: t.client.LoginPassword}
+
+	_, err := conn.Invoke(ctx, network.LoginCmd, loginReq)
+	if err == nil {
+		t.setFlag(Ready)
+		return
+	}
+
+	// 登录失败，关闭连接，由重连逻辑重试
+	util.Errorf("login fail:%s\n", err.Error())
+	t.setFlag(UnReady)
+	conn.Close()
+
+}
+
+func (t *Tunnel) BuildNewChannel(ctx context.Context, addr string) (*network.Channel, error) {
+
+	if !t.Available() {
+		util.Infof("Tunnel %d is not available!\n", t.Id)
+
+		return nil, errors.New("Tunnel is not available!")
+	}
+
+	traceId, _ := ctx.Value("traceId").(string)
+	conn := t.Conn()
+
+	defer util.Trace(traceId, "Tunnel BuildNewChannel")()
+
+	channelReq := &msg.NewChannelReq{Addr: addr, TraceId: traceId}
+	util.Debugf("%s,send new channel request: %v \n", traceId, channelReq)
+
+	// 在连接读协程中注册通道，服务端随后发送的数据不会先于注册到达
+	var channel *network.Channel
+	callback := func(res proto.Message) {
+		channelRes := res.(*msg.NewChannelRes)
+		if channelRes.Code != network.SuccessCode {
+			return
+		}
+
+		channel = network.NewChannel(channelRes.ChannelId, conn)
+		channel.TraceId = traceId
+		channel.BindAddr = channelRes.BindAddr
+
+		conn.RegChannel(channelRes.ChannelId, channel)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.client.RpcTimeout)
+	defer cancel()
+
+	if _, err := conn.InvokeWithCallback(ctx, network.BuildChannelCmd, channelReq, callback); err != nil {
+		util.Errorf("%s,new channel request fail:%s\n", traceId, err.Error())
+
+		return nil, fmt.Errorf("new channel: %w", err)
+	}
+
+	return channel, nil
+
+}
+
+// OpenChannel 以 OPEN 打开通道，不等待服务端连接目标，失败原因由之后的读写返回
+func (t *Tunnel) OpenChannel(ctx context.Context, addr string) (*network.Channel, error) {
+
+	if !t.Available() {
+		util.Infof("Tunnel %d is not available!\n", t.Id)
+
+		return nil, errors.New("Tunnel is not available!")
+	}
+
+	traceId, _ := ctx.Value("traceId").(string)
+	util.Debugf("%s,open channel to %s \n", traceId, addr)
+
+	channel, err := t.Conn().OpenChannel(ctx, addr)
+	if err != nil {
+		util.Errorf("%s,open channel fail:%s\n", traceId, err.Error())
+
+		return nil, fmt.Errorf("open channel: %w", err)
+	}
+
+	return channel, nil
+}
+
+func (t *Tunnel) BuildNewAssociate(ctx context.Context) (*network.Datagram, error) {
+
+	if !t.Available() {
+		util.Infof("Tunnel %d is not available!\n", t.Id)
+
+		return nil, errors.New("Tunnel is not available!")
+	}
+
+	traceId, _ := ctx.Value("traceId").(string)
+	conn := t.Conn()
+
+	defer util.Trace(traceId, "Tunnel BuildNewAssociate")()
+
+	associateReq := &msg.NewAssociateReq{TraceId: traceId}
+	util.Debugf("%s,send new associate request: %v \n", traceId, associateReq)
+
+	var datagram *network.Datagram
+	callback := func(res proto.Message) {
+		associateRes := res.(*msg.NewAssociateRes)
+		if associateRes.Code != network.SuccessCode {
+			return
+		}
+
+		datagram = network.NewDatagram(associateRes.AssociateId, conn)
+		datagram.TraceId = traceId
+
+		conn.RegDatagram(associateRes.AssociateId, datagram)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.client.RpcTimeout)
+	defer cancel()
+
+	if _, err := conn.InvokeWithCallback(ctx, network.BuildAssociateCmd, associateReq, callback); err != nil {
+		util.Errorf("%s,new associate request fail:%s\n", traceId, err.Error())
+
+		return nil, fmt.Errorf("new associate: %w", err)
+	}
+
+	return datagram, nil
+
+}
+
+// BuildBindChannel 请求服务端监听随机端口，返回的通道 BindAddr 为监听地址
+func (t *Tunnel) BuildBindChannel(ctx context.Context, addr string) (*network.Channel, error) {
+
+	if !t.Available() {
+		util.Infof("Tunnel %d is not available!\n", t.Id)
+
+		return nil, errors.New("Tunnel is not available!")
+	}
+
+	traceId, _ := ctx.Value("traceId").(string)
+	conn := t.Conn()
+
+	defer util.Trace(traceId, "Tunnel BuildBindChannel")()
+
+	bindReq := &msg.NewChannelReq{Addr: addr, TraceId: traceId}
+	util.Debugf("%s,send bind request: %v \n", traceId, bindReq)
+
+	var channel *network.Channel
+	callback := func(res proto.Message) {
+		bindRes := res.(*msg.NewChannelRes)
+		if bindRes.Code != network.SuccessCode {
+			return
+		}
+
+		channel = network.NewChannel(bindRes.ChannelId, conn)
+		channel.TraceId = traceId
+		channel.BindAddr = bindRes.BindAddr
+
+		conn.RegChannel(bindRes.ChannelId, channel)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.client.RpcTimeout)
+	defer cancel()
+
+	if _, err := conn.InvokeWithCallback(ctx, network.BindCmd, bindReq, callback); err != nil {
+		util.Errorf("%s,bind request fail:%s\n", traceId, err.Error())
+
+		return nil, fmt.Errorf("bind: %w", err)
+	}
+
+	return channel, nil
+
+}
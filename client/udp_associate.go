@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ssp/util"
+)
+
+// Socks5UdpAssociate 处理 UDP ASSOCIATE 请求，阻塞直到控制连接关闭或关联空闲超时
+func (p *Socks5Proxy) Socks5UdpAssociate(ctx context.Context, src net.Conn) error {
+	traceId := ctx.Value("traceId").(string)
+
+	defer util.Trace(traceId, "Client Socks5UdpAssociate")()
+
+	// 本地 UDP 中继，与控制连接绑定在同一个地址上
+	localIp := src.LocalAddr().(*net.TCPAddr).IP
+	relay, err := net.ListenUDP("udp", &net.UDPAddr{IP: localIp})
+	if err != nil {
+		src.Write(buildSocks5Reply(0x01, ""))
+		return errors.New("listen udp: " + err.Error())
+	}
+	defer relay.Close()
+
+	datagram, err := p.RemoteEndpoint.BuildNewAssociate(ctx)
+	if err != nil {
+		src.Write(buildSocks5Reply(0x01, ""))
+		return errors.New("associate: " + err.Error())
+	}
+	defer datagram.Close()
+
+	_, err = src.Write(buildSocks5Reply(0x00, relay.LocalAddr().String()))
+	if err != nil {
+		return errors.New("write rsp: " + err.Error())
+	}
+
+	util.Infof("%s,Udp associate %d relay on %s\n", traceId, datagram.Id, relay.LocalAddr())
+
+	idleTimeout := datagram.UnderlyingConn.Config().UdpIdleTimeout
+
+	// 只接受来自控制连接对端 IP 的数据包
+	clientIp := src.RemoteAddr().(*net.TCPAddr).IP
+
+	var clientAddr *net.UDPAddr
+	var lastActive time.Time
+	var addrMutex sync.Mutex
+
+	touch := func() {
+		addrMutex.Lock()
+		lastActive = time.Now()
+		addrMutex.Unlock()
+	}
+
+	touch()
+
+	// 本地 -> 远端
+	go func() {
+		defer src.Close()
+
+		buf := make([]byte, 64*1024)
+		for {
+			relay.SetReadDeadline(time.Now().Add(idleTimeout))
+
+			n, addr, err := relay.ReadFromUDP(buf)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					addrMutex.Lock()
+					idle := time.Since(lastActive) >= idleTimeout
+					addrMutex.Unlock()
+
+					if !idle {
+						continue
+					}
+				}
+
+				util.Errorf("%s,Udp relay read:%s\n", traceId, err.Error())
+				return
+			}
+
+			if !addr.IP.Equal(clientIp) {
+				continue
+			}
+
+			addrMutex.Lock()
+			clientAddr = addr
+			lastActive = time.Now()
+			addrMutex.Unlock()
+
+			if _, err := datagram.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	// 远端 -> 本地
+	go func() {
+		defer src.Close()
+
+		for packet := range datagram.ReadBuff {
+			addrMutex.Lock()
+			addr := clientAddr
+			addrMutex.Unlock()
+
+			if addr == nil {
+				continue
+			}
+
+			touch()
+
+			if _, err := relay.WriteToUDP(packet, addr); err != nil {
+				util.Errorf("%s,Udp relay write:%s\n", traceId, err.Error())
+			}
+		}
+	}()
+
+	// 控制连接关闭时结束关联
+	io.Copy(io.Discard, src)
+
+	return nil
+}
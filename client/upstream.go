@@ -0,0 +1,131 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ssp/util"
+)
+
+// ServerPolicy 多个服务端时的选择策略
+type ServerPolicy string
+
+const (
+	// 持续使用同一个服务端，不可用时切换到最健康的服务端
+	StickyServer ServerPolicy = "sticky"
+
+	// 每个请求都选择当前最健康的服务端
+	PerRequestServer ServerPolicy = "per-request"
+)
+
+func ParseServerPolicy(policy string) (ServerPolicy, error) {
+	switch ServerPolicy(policy) {
+	case "", StickyServer:
+		return StickyServer, nil
+	case PerRequestServer:
+		return PerRequestServer, nil
+	}
+
+	return StickyServer, errors.New("invalid server policy: " + policy)
+}
+
+// Upstream 一个服务端及与其建立的隧道
+type Upstream struct {
+	// 服务端地址
+	Addr string
+
+	tunnels []*Tunnel
+}
+
+func newUpstream(addr string, size int, client *Client) *Upstream {
+	upstream := &Upstream{Addr: addr}
+
+	upstream.tunnels = make([]*Tunnel, size)
+	for i := range upstream.tunnels {
+		upstream.tunnels[i] = newTunnel(i, client, upstream)
+	}
+
+	return upstream
+}
+
+// Healthy 至少一条隧道已登录且心跳未超时
+func (u *Upstream) Healthy() bool {
+	for _, tunnel := range u.tunnels {
+		if tunnel.Available() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RTT 可用隧道中最短的心跳往返时间，尚未测得时为 0
+func (u *Upstream) RTT() time.Duration {
+	var rtt time.Duration
+
+	for _, tunnel := range u.tunnels {
+		if !tunnel.Available() {
+			continue
+		}
+
+		if r := tunnel.Conn().RTT(); r > 0 && (rtt == 0 || r < rtt) {
+			rtt = r
+		}
+	}
+
+	return rtt
+}
+
+func (u *Upstream) Tunnels() []*Tunnel {
+	return u.tunnels
+}
+
+// pickUpstream 按服务端策略选择服务端，没有健康的服务端时返回 nil
+func (c *Client) pickUpstream() *Upstream {
+	if c.ServerPolicy == PerRequestServer {
+		return c.healthiestUpstream()
+	}
+
+	current := c.current.Load()
+	if current != nil && current.Healthy() {
+		return current
+	}
+
+	best := c.healthiestUpstream()
+	if best == nil {
+		return nil
+	}
+
+	if c.current.CompareAndSwap(current, best) && current != nil && current != best {
+		util.Warnf("Server %s is unhealthy,failover to %s\n", current.Addr, best.Addr)
+	}
+
+	return c.current.Load()
+}
+
+// healthiestUpstream 选择心跳往返时间最短的健康服务端，
+// 尚未测得往返时间的排在后面，相同情况下按配置顺序
+func (c *Client) healthiestUpstream() *Upstream {
+	var best *Upstream
+	var bestRtt time.Duration
+
+	for _, upstream := range c.upstreamList() {
+		if !upstream.Healthy() {
+			continue
+		}
+
+		rtt := upstream.RTT()
+
+		switch {
+		case best == nil:
+		case rtt > 0 && (bestRtt == 0 || rtt < bestRtt):
+		default:
+			continue
+		}
+
+		best = upstream
+		bestRtt = rtt
+	}
+
+	return best
+}
@@ -1,24 +1,136 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/ssp/client"
+	"github.com/ssp/config"
 	"github.com/ssp/util"
 )
 
 func main() {
-	serverAddr := "localhost:9090"
+	configPath := flag.String("c", "", "config file (json)")
+	serverAddr := flag.String("server", "", "server address, comma separated for multiple servers, overrides config")
+	loginName := flag.String("user", "", "login name, overrides config")
+	socks5Addr := flag.String("socks5", "", "socks5/http proxy listen address, overrides config")
+	httpAddr := flag.String("http", "", "standalone http proxy listen address, overrides config")
+	tunnels := flag.Int("tunnels", 0, "number of parallel tunnels to the server, overrides config")
+	logLevel := flag.String("log-level", "", "log level: debug, info, warn, error, overrides config")
+	flag.Parse()
 
-	proxy := client.New(serverAddr)
+	// 读取配置文件，命令行参数优先于配置文件，SIGHUP 时重新读取
+	loadConfig := func() (*config.ClientConfig, error) {
+		cfg, err := config.LoadClientConfig(*configPath)
+		if err != nil {
+			return nil, err
+		}
+
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "server":
+				cfg.Server = ""
+				cfg.Servers = strings.Split(*serverAddr, ",")
+			case "user":
+				cfg.Login.Name = *loginName
+			case "socks5":
+				cfg.Socks5Addr = *socks5Addr
+			case "http":
+				cfg.HttpAddr = *httpAddr
+			case "tunnels":
+				cfg.Tunnels = *tunnels
+			case "log-level":
+				cfg.LogLevel = *logLevel
+			}
+		})
+
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid config:\n%w", err)
+		}
+
+		return cfg, nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	level, _ := util.ParseLogLevel(cfg.LogLevel)
+	util.SetLogLevel(level)
+
+	proxy := client.New(cfg.ServerList()...)
+	proxy.ServerPolicy, _ = client.ParseServerPolicy(cfg.ServerPolicy)
+	proxy.Socks5Addr = cfg.Socks5Addr
+	proxy.LoginName = cfg.Login.Name
+	proxy.LoginPassword = cfg.Login.Password
+	proxy.Tunnels = cfg.Tunnels
+	proxy.Balance, _ = client.ParseBalanceStrategy(cfg.Balance)
+	proxy.HttpAddr = cfg.HttpAddr
+	proxy.RequireAuth = cfg.Auth.RequireAuth
+	proxy.RpcTimeout = cfg.RpcTimeout.Std()
+	proxy.FastOpen = cfg.FastOpen
+	proxy.Backoff = cfg.Reconnect.Backoff()
+	proxy.Reverse = cfg.ReverseForwards()
+	proxy.ConnConfig = cfg.Connection.Network()
+
+	if cfg.TLS.Enable {
+		tlsConfig, err := client.NewTLSConfig(client.TLSOptions{
+			CA:         cfg.TLS.CA,
+			ServerName: cfg.TLS.ServerName,
+			Pins:       cfg.TLS.Pins,
+			Cert:       cfg.TLS.Cert,
+			Key:        cfg.TLS.Key,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load tls config: %s\n", err)
+			os.Exit(2)
+		}
+
+		proxy.TLS = tlsConfig
+	}
+
+	cipher, err := cfg.Cipher.Network()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load cipher config: %s\n", err)
+		os.Exit(2)
+	}
+	proxy.Cipher = cipher
+
+	proxy.Credentials = credentials(cfg)
+
+	router, err := newRouter(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load routing rules: %s\n", err)
+		os.Exit(2)
+	}
+	proxy.SetRouter(router)
+
+	proxy.Subscribe(func(event client.StateEvent) {
+		util.Infof("Tunnel %d of %s: %s -> %s\n", event.Tunnel, event.Server, event.From, event.To)
+	})
 
 	proxy.Connect()
 	proxy.Start()
+	applyForwards(proxy, cfg)
+
+	// 所有隧道停止重连后退出，由进程管理工具重启
+	go func() {
+		proxy.Reconnect()
 
-	go proxy.Reconnect()
+		select {
+		case <-proxy.Done():
+		default:
+			util.Errorln("All tunnels stop reconnecting, exit.")
+			os.Exit(1)
+		}
+	}()
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT)
@@ -28,13 +140,44 @@ func main() {
 	for {
 		s := <-c
 
-		log.Printf("gid:%d,Receive a signal!!!\n", gid)
+		util.Infof("gid:%d,Receive a signal!!!\n", gid)
 
 		switch s {
 		case syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT:
-			log.Printf("gid:%d,Proxy exist!!!\n", gid)
+			util.Infof("gid:%d,Proxy shutting down...\n", gid)
+
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Std())
+			if err := proxy.Shutdown(ctx); err != nil {
+				util.Warnf("gid:%d,Proxy shutdown:%s\n", gid, err.Error())
+			}
+			cancel()
+
+			util.Infof("gid:%d,Proxy exist!!!\n", gid)
 			return
 		case syscall.SIGHUP:
+			// 只重新加载日志级别、代理认证配置、路由规则与本地端口转发，其余配置需要重启
+			reloaded, err := loadConfig()
+			if err != nil {
+				util.Errorf("gid:%d,Reload config fail:%s\n", gid, err.Error())
+				continue
+			}
+
+			level, _ := util.ParseLogLevel(reloaded.LogLevel)
+			util.SetLogLevel(level)
+
+			proxy.SetAuth(credentials(reloaded), reloaded.Auth.RequireAuth)
+
+			// 规则有误时保留原有规则
+			if router, err := newRouter(reloaded); err != nil {
+				util.Errorf("gid:%d,Reload routing rules fail:%s\n", gid, err.Error())
+			} else {
+				proxy.SetRouter(router)
+			}
+
+			applyForwards(proxy, reloaded)
+			cfg.ShutdownTimeout = reloaded.ShutdownTimeout
+
+			util.Infof("gid:%d,Config reloaded.\n", gid)
 		default:
 			return
 		}
@@ -42,3 +185,47 @@ func main() {
 	}
 
 }
+
+func credentials(cfg *config.ClientConfig) client.CredentialStore {
+	if len(cfg.Auth.Users) == 0 {
+		return nil
+	}
+
+	return client.StaticCredentials(cfg.Auth.Users)
+}
+
+// newRouter 按配置读取路由规则，未配置规则文件时返回空
+func newRouter(cfg *config.ClientConfig) (*client.Router, error) {
+	if cfg.Routing.Rules == "" {
+		return nil, nil
+	}
+
+	return client.NewRouter(cfg.Routing.Rules, cfg.Routing.GeoIP)
+}
+
+// applyForwards 按配置添加本地端口转发并切换启用状态，配置中移除的转发停用，
+// 已有转发修改 remote 需要重启
+func applyForwards(proxy *client.Client, cfg *config.ClientConfig) {
+	configured := map[string]bool{}
+
+	for _, forward := range cfg.Forwards {
+		configured[forward.Local] = true
+
+		f := proxy.AddForward(forward.Local, forward.Remote)
+		if f.Remote != forward.Remote {
+			util.Warnf("Forward %s remote changed to %s,restart to take effect\n", forward.Local, forward.Remote)
+		}
+
+		if forward.Enable {
+			f.Enable()
+		} else {
+			f.Disable()
+		}
+	}
+
+	for _, f := range proxy.Forwards() {
+		if !configured[f.Local] {
+			f.Disable()
+		}
+	}
+}
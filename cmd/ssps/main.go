@@ -1,18 +1,130 @@
 package main
 
 import (
-	"log"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
+	"github.com/ssp/config"
 	"github.com/ssp/server"
+	"github.com/ssp/util"
 )
 
 func main() {
-	port := 9090
+	configPath := flag.String("c", "", "config file (json)")
+	listen := flag.String("listen", "", "listen address, overrides config")
+	logLevel := flag.String("log-level", "", "log level: debug, info, warn, error, overrides config")
+	usersFile := flag.String("users", "", "users file, overrides config")
+	hashPassword := flag.Bool("hash-password", false, "read a password from stdin, print its hash for the users file and exit")
+	flag.Parse()
 
-	server := server.New(port)
+	if *hashPassword {
+		pwd, err := readPassword(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read password: %s\n", err)
+			os.Exit(1)
+		}
+
+		hash, err := server.HashPassword(pwd)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		fmt.Println(hash)
+		return
+	}
+
+	// 读取配置文件，命令行参数优先于配置文件，SIGHUP 时重新读取
+	loadConfig := func() (*config.ServerConfig, error) {
+		cfg, err := config.LoadServerConfig(*configPath)
+		if err != nil {
+			return nil, err
+		}
+
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "listen":
+				cfg.Listen = *listen
+			case "users":
+				cfg.UsersFile = *usersFile
+			case "log-level":
+				cfg.LogLevel = *logLevel
+			}
+		})
+
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid config:\n%w", err)
+		}
+
+		return cfg, nil
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	level, _ := util.ParseLogLevel(cfg.LogLevel)
+	util.SetLogLevel(level)
+
+	users, err := server.NewFileUserStore(cfg.UsersFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load users: %s\n", err)
+		os.Exit(2)
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.TLS.Enable {
+		tlsConfig, err = server.NewTLSConfig(server.TLSOptions{
+			Cert:              cfg.TLS.Cert,
+			Key:               cfg.TLS.Key,
+			ClientCA:          cfg.TLS.ClientCA,
+			RequireClientCert: cfg.TLS.RequireClientCert,
+			AllowedClients:    cfg.TLS.AllowedClients,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load tls config: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	cipher, err := cfg.Cipher.Network()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load cipher config: %s\n", err)
+		os.Exit(2)
+	}
+
+	rules, _ := cfg.ACLRules()
+	acl := server.NewACL(rules)
+
+	reverseRules, _ := cfg.ReverseRules()
+	reverseACL := server.NewListenACL(reverseRules)
+
+	resolver, err := cfg.DNS.Resolver()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load dns config: %s\n", err)
+		os.Exit(2)
+	}
+
+	server := server.New(cfg.Listen)
+	server.ConnConfig = cfg.Connection.Network()
+	server.ConnConfig.AllowReverse = cfg.AllowReverse
+	server.Authenticator = users
+	server.AccessPolicy = acl
+	server.ListenPolicy = reverseACL
+	server.Resolver = resolver
+	server.TLS = tlsConfig
+	server.Cipher = cipher
 
 	server.Start()
 
@@ -22,16 +134,70 @@ func main() {
 	for {
 		s := <-c
 
-		log.Println("Receive a signal!!!")
+		util.Infoln("Receive a signal!!!")
 
 		switch s {
 		case syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT:
-			log.Println("Server exist!!!")
+			util.Infoln("Server shutting down...")
+
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Std())
+			if err := server.Shutdown(ctx); err != nil {
+				util.Warnf("Server shutdown:%s\n", err.Error())
+			}
+			cancel()
+
+			util.Infoln("Server exist!!!")
 			return
 		case syscall.SIGHUP:
+			// 只重新加载日志级别、用户文件、访问控制规则与 hosts 文件，其余配置需要重启
+			reloaded, err := loadConfig()
+			if err != nil {
+				util.Errorf("Reload config fail:%s\n", err.Error())
+				continue
+			}
+
+			level, _ := util.ParseLogLevel(reloaded.LogLevel)
+			util.SetLogLevel(level)
+
+			if reloaded.UsersFile != users.Path {
+				util.Warnf("usersFile changed to %s,restart to take effect\n", reloaded.UsersFile)
+			} else if err := users.Reload(); err != nil {
+				util.Errorf("Reload users fail:%s\n", err.Error())
+				continue
+			}
+
+			rules, _ := reloaded.ACLRules()
+			acl.SetRules(rules)
+
+			reverseRules, _ := reloaded.ReverseRules()
+			reverseACL.SetRules(reverseRules)
+
+			if reloaded.DNS.Hosts != resolver.HostsPath {
+				util.Warnf("dns.hosts changed to %s,restart to take effect\n", reloaded.DNS.Hosts)
+			} else if err := resolver.ReloadHosts(); err != nil {
+				util.Errorf("Reload hosts fail:%s\n", err.Error())
+			}
+			cfg.ShutdownTimeout = reloaded.ShutdownTimeout
+
+			util.Infoln("Config reloaded.")
 		default:
 			return
 		}
 
 	}
 }
+
+// readPassword 读取一行作为密码，只去掉行尾的换行，密码中可以包含空格
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+
+	pwd := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if pwd == "" {
+		return "", errors.New("empty password")
+	}
+
+	return pwd, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadPassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"line", "secret\n", "secret", false},
+		{"spaces kept", " pass with spaces \n", " pass with spaces ", false},
+		{"crlf", "secret\r\n", "secret", false},
+		{"no newline", "secret", "secret", false},
+		{"only first line", "first\nsecond\n", "first", false},
+		{"empty line", "\n", "", true},
+		{"empty input", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPassword(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readPassword() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
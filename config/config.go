@@ -0,0 +1,721 @@
+// Package config 读取并校验 sspc、ssps 的 JSON 配置，转换为 client、server、network 包的参数。
+//
+// 依赖方向为 cmd -> config -> client、server、network：校验直接复用这些包的解析函数
+// （server.ParseRule、server.NewResolver、server.ParseCertFingerprint、network.NewCipher），
+// 使配置校验与运行时的行为一致；这些包不依赖 config，可以不经配置文件单独使用。
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ssp/client"
+	"github.com/ssp/network"
+	"github.com/ssp/server"
+	"github.com/ssp/util"
+)
+
+// Duration 支持 "5s"、"1m30s" 格式的时间配置
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.New("duration must be a string such as \"5s\"")
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// ConnectionConfig 客户端与服务端共用的连接参数
+type ConnectionConfig struct {
+	HeartbeatInterval Duration `json:"heartbeatInterval"`
+	HeartbeatTimeout  Duration `json:"heartbeatTimeout"`
+	UdpIdleTimeout    Duration `json:"udpIdleTimeout"`
+	BindAcceptTimeout Duration `json:"bindAcceptTimeout"`
+	DialTimeout       Duration `json:"dialTimeout"`
+	WriteBuffSize     int      `json:"writeBuffSize"`
+	ReadBuffSize      int      `json:"readBuffSize"`
+}
+
+// ReconnectConfig 客户端断线重连的退避参数
+type ReconnectConfig struct {
+	InitialInterval Duration `json:"initialInterval"`
+	MaxInterval     Duration `json:"maxInterval"`
+	Multiplier      float64  `json:"multiplier"`
+	Jitter          float64  `json:"jitter"`
+
+	// 连续失败的最大重连次数，0 表示不限制
+	MaxAttempts int `json:"maxAttempts"`
+
+	// 连续重连的最长时间，0 表示不限制
+	MaxElapsed Duration `json:"maxElapsed"`
+}
+
+// AuthConfig SOCKS5/HTTP 代理的认证配置
+type AuthConfig struct {
+	RequireAuth bool              `json:"requireAuth"`
+	Users       map[string]string `json:"users"`
+}
+
+// LoginConfig 客户端登录服务端的凭证
+type LoginConfig struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// ClientTLSConfig 客户端 TLS 配置
+type ClientTLSConfig struct {
+	Enable     bool     `json:"enable"`
+	CA         string   `json:"ca"`
+	ServerName string   `json:"serverName"`
+	Pins       []string `json:"pins"`
+	Cert       string   `json:"cert"`
+	Key        string   `json:"key"`
+}
+
+// ServerTLSConfig 服务端 TLS 配置
+type ServerTLSConfig struct {
+	Enable            bool     `json:"enable"`
+	Cert              string   `json:"cert"`
+	Key               string   `json:"key"`
+	ClientCA          string   `json:"clientCA"`
+	RequireClientCert bool     `json:"requireClientCert"`
+	AllowedClients    []string `json:"allowedClients"`
+}
+
+// CipherConfig 预共享密钥加密配置，与 TLS 二选一
+type CipherConfig struct {
+	Enable bool   `json:"enable"`
+	Method string `json:"method"`
+	Key    string `json:"key"`
+}
+
+// ReverseConfig 远程端口转发：服务端监听 remote，连接转发到客户端可访问的 local
+type ReverseConfig struct {
+	Remote string `json:"remote"`
+	Local  string `json:"local"`
+}
+
+// ForwardConfig 本地端口转发：监听 local，连接经隧道转发到 remote
+type ForwardConfig struct {
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	Enable bool   `json:"enable"`
+}
+
+// RoutingConfig 路由规则：按目标直连、经隧道或拒绝，rules 为空时所有目标经隧道
+type RoutingConfig struct {
+	// 规则文件
+	Rules string `json:"rules"`
+
+	// GeoIP 数据库（mmdb），GEOIP 规则需要
+	GeoIP string `json:"geoip"`
+}
+
+type ClientConfig struct {
+	// 服务端地址
+	Server string `json:"server"`
+
+	// 多个服务端地址，不为空时忽略 server
+	Servers []string `json:"servers"`
+
+	// 多个服务端时的选择策略：sticky、per-request
+	ServerPolicy string `json:"serverPolicy"`
+
+	// SOCKS5 代理监听地址，同时处理 HTTP 代理请求
+	Socks5Addr string `json:"socks5Addr"`
+
+	// HTTP 代理单独监听的地址，可为空
+	HttpAddr string `json:"httpAddr"`
+
+	Auth AuthConfig `json:"auth"`
+
+	// 登录服务端的用户名/密码
+	Login LoginConfig `json:"login"`
+
+	// 与服务端之间并行的隧道数
+	Tunnels int `json:"tunnels"`
+
+	// 新通道在隧道间的分配策略：round-robin、least-channels、lowest-rtt
+	Balance string `json:"balance"`
+
+	TLS ClientTLSConfig `json:"tls"`
+
+	Cipher CipherConfig `json:"cipher"`
+
+	RpcTimeout Duration `json:"rpcTimeout"`
+
+	// 不等待服务端连接目标即应答客户端并发送数据，需服务端支持；
+	// 连接失败的原因不再通过 SOCKS5/HTTP 应答返回
+	FastOpen bool `json:"fastOpen"`
+
+	Reconnect ReconnectConfig `json:"reconnect"`
+
+	Routing RoutingConfig `json:"routing"`
+
+	// 本地端口转发
+	Forwards []ForwardConfig `json:"forwards"`
+
+	// 远程端口转发，需服务端开启 allowReverse
+	Reverse []ReverseConfig `json:"reverse"`
+
+	// 退出时等待通道结束的最长时间
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+
+	Connection ConnectionConfig `json:"connection"`
+
+	LogLevel string `json:"logLevel"`
+}
+
+// ACLRuleConfig 目标访问控制规则，按顺序匹配，第一条匹配的规则生效
+type ACLRuleConfig struct {
+	// allow、deny
+	Action string `json:"action"`
+
+	// 为空时匹配所有用户
+	Users []string `json:"users"`
+
+	CIDRs []string `json:"cidrs"`
+
+	// 域名通配，如 *.example.com
+	Domains []string `json:"domains"`
+
+	// 端口或端口范围，如 443、8000-8100
+	Ports []string `json:"ports"`
+}
+
+// DNSConfig 服务端解析目标域名的配置
+type DNSConfig struct {
+	// 上游服务器：8.8.8.8、udp://8.8.8.8:53、tcp://8.8.8.8:53、tls://1.1.1.1:853、
+	// https://dns.google/dns-query，为空时使用系统解析
+	Upstreams []string `json:"upstreams"`
+
+	// hosts 文件，优先于上游服务器
+	Hosts string `json:"hosts"`
+
+	// 地址族偏好：ipv4、ipv6、ipv4-only、ipv6-only
+	Prefer string `json:"prefer"`
+
+	// 每个上游服务器的查询超时时间
+	Timeout Duration `json:"timeout"`
+
+	// 缓存的最大条目数，为 0 时不缓存；只缓存上游服务器的应答
+	CacheSize int `json:"cacheSize"`
+}
+
+type ServerConfig struct {
+	// 监听地址
+	Listen string `json:"listen"`
+
+	// 用户文件，每行一个 name:bcrypt-hash
+	UsersFile string `json:"usersFile"`
+
+	TLS ServerTLSConfig `json:"tls"`
+
+	Cipher CipherConfig `json:"cipher"`
+
+	// 目标访问控制，没有规则匹配时拒绝回环、私有等内部地址
+	ACL []ACLRuleConfig `json:"acl"`
+
+	DNS DNSConfig `json:"dns"`
+
+	// 是否允许客户端请求监听端口（远程端口转发）
+	AllowReverse bool `json:"allowReverse"`
+
+	// 远程端口转发监听地址的访问控制，没有规则匹配时只允许在回环地址上监听非特权端口
+	ReverseACL []ACLRuleConfig `json:"reverseAcl"`
+
+	// 退出时等待通道结束的最长时间
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+
+	Connection ConnectionConfig `json:"connection"`
+
+	LogLevel string `json:"logLevel"`
+}
+
+func defaultConnectionConfig() ConnectionConfig {
+	c := network.DefaultConnectionConfig()
+
+	return ConnectionConfig{
+		HeartbeatInterval: Duration(c.HeartbeatInterval),
+		HeartbeatTimeout:  Duration(c.HeartbeatTimeout),
+		UdpIdleTimeout:    Duration(c.UdpIdleTimeout),
+		BindAcceptTimeout: Duration(c.BindAcceptTimeout),
+		DialTimeout:       Duration(c.DialTimeout),
+		WriteBuffSize:     c.WriteBuffSize,
+		ReadBuffSize:      c.ReadBuffSize,
+	}
+}
+
+func defaultReconnectConfig() ReconnectConfig {
+	b := client.DefaultBackoff()
+
+	return ReconnectConfig{
+		InitialInterval: Duration(b.InitialInterval),
+		MaxInterval:     Duration(b.MaxInterval),
+		Multiplier:      b.Multiplier,
+		Jitter:          b.Jitter,
+		MaxAttempts:     b.MaxAttempts,
+		MaxElapsed:      Duration(b.MaxElapsed),
+	}
+}
+
+func DefaultClientConfig() *ClientConfig {
+	return &ClientConfig{
+		Server:          "localhost:9090",
+		Socks5Addr:      ":1080",
+		ServerPolicy:    "sticky",
+		Tunnels:         1,
+		Balance:         "round-robin",
+		RpcTimeout:      Duration(5 * time.Second),
+		Reconnect:       defaultReconnectConfig(),
+		ShutdownTimeout: Duration(30 * time.Second),
+		Connection:      defaultConnectionConfig(),
+		LogLevel:        "info",
+	}
+}
+
+func DefaultServerConfig() *ServerConfig {
+	return &ServerConfig{
+		Listen:          ":9090",
+		DNS:             DNSConfig{Prefer: "ipv4", Timeout: Duration(5 * time.Second), CacheSize: 4096},
+		ShutdownTimeout: Duration(30 * time.Second),
+		Connection:      defaultConnectionConfig(),
+		LogLevel:        "info",
+	}
+}
+
+// LoadClientConfig 读取客户端配置，path 为空时返回默认配置
+func LoadClientConfig(path string) (*ClientConfig, error) {
+	c := DefaultClientConfig()
+
+	if err := load(path, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// LoadServerConfig 读取服务端配置，path 为空时返回默认配置
+func LoadServerConfig(path string) (*ServerConfig, error) {
+	c := DefaultServerConfig()
+
+	if err := load(path, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func load(path string, v any) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *ClientConfig) Validate() error {
+	var errs []error
+
+	if len(c.Servers) == 0 {
+		errs = append(errs, validateAddr("server", c.Server, true))
+	}
+	for i, server := range c.Servers {
+		errs = append(errs, validateAddr(fmt.Sprintf("servers[%d]", i), server, true))
+	}
+
+	switch c.ServerPolicy {
+	case "sticky", "per-request":
+	default:
+		errs = append(errs, errors.New("serverPolicy: must be one of sticky, per-request"))
+	}
+
+	errs = append(errs, validateAddr("socks5Addr", c.Socks5Addr, true))
+	errs = append(errs, validateAddr("httpAddr", c.HttpAddr, false))
+
+	if c.Auth.RequireAuth && len(c.Auth.Users) == 0 {
+		errs = append(errs, errors.New("auth.users: must not be empty when auth.requireAuth is true"))
+	}
+
+	if c.Login.Name == "" {
+		errs = append(errs, errors.New("login.name: must not be empty"))
+	}
+
+	if c.Tunnels <= 0 {
+		errs = append(errs, errors.New("tunnels: must be positive"))
+	}
+
+	switch c.Balance {
+	case "round-robin", "least-channels", "lowest-rtt":
+	default:
+		errs = append(errs, errors.New("balance: must be one of round-robin, least-channels, lowest-rtt"))
+	}
+
+	if c.TLS.Enable {
+		errs = append(errs, validateFile("tls.ca", c.TLS.CA, false))
+		errs = append(errs, validateFile("tls.cert", c.TLS.Cert, c.TLS.Key != ""))
+		errs = append(errs, validateFile("tls.key", c.TLS.Key, c.TLS.Cert != ""))
+	}
+
+	errs = append(errs, c.Cipher.validate(c.TLS.Enable))
+
+	if c.RpcTimeout <= 0 {
+		errs = append(errs, errors.New("rpcTimeout: must be positive"))
+	}
+
+	errs = append(errs, c.Reconnect.validate())
+
+	errs = append(errs, validateFile("routing.rules", c.Routing.Rules, false))
+	errs = append(errs, validateFile("routing.geoip", c.Routing.GeoIP, false))
+
+	locals := map[string]bool{}
+	for i, forward := range c.Forwards {
+		errs = append(errs, validateAddr(fmt.Sprintf("forwards[%d].local", i), forward.Local, true))
+		errs = append(errs, validateAddr(fmt.Sprintf("forwards[%d].remote", i), forward.Remote, true))
+
+		if locals[forward.Local] {
+			errs = append(errs, fmt.Errorf("forwards[%d].local: duplicate address %q", i, forward.Local))
+		}
+		locals[forward.Local] = true
+	}
+
+	for i, reverse := range c.Reverse {
+		errs = append(errs, validateAddr(fmt.Sprintf("reverse[%d].remote", i), reverse.Remote, true))
+		errs = append(errs, validateAddr(fmt.Sprintf("reverse[%d].local", i), reverse.Local, true))
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdownTimeout: must be positive"))
+	}
+
+	errs = append(errs, c.Connection.validate())
+	errs = append(errs, validateLogLevel(c.LogLevel))
+
+	return errors.Join(errs...)
+}
+
+// ReverseForwards 转换为 client 包使用的远程端口转发
+func (c *ClientConfig) ReverseForwards() []client.ReverseForward {
+	forwards := make([]client.ReverseForward, 0, len(c.Reverse))
+	for _, reverse := range c.Reverse {
+		forwards = append(forwards, client.ReverseForward{Remote: reverse.Remote, Local: reverse.Local})
+	}
+
+	return forwards
+}
+
+// ServerList 客户端连接的服务端地址列表
+func (c *ClientConfig) ServerList() []string {
+	if len(c.Servers) > 0 {
+		return c.Servers
+	}
+
+	return []string{c.Server}
+}
+
+func (c *ServerConfig) Validate() error {
+	var errs []error
+
+	errs = append(errs, validateAddr("listen", c.Listen, true))
+	errs = append(errs, c.validateTLS())
+	errs = append(errs, c.Cipher.validate(c.TLS.Enable))
+
+	if c.UsersFile == "" {
+		errs = append(errs, errors.New("usersFile: must not be empty"))
+	} else if _, err := os.Stat(c.UsersFile); err != nil {
+		errs = append(errs, fmt.Errorf("usersFile: %w", err))
+	}
+
+	if _, err := c.ACLRules(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if _, err := c.ReverseRules(); err != nil {
+		errs = append(errs, err)
+	}
+
+	errs = append(errs, c.DNS.validate())
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdownTimeout: must be positive"))
+	}
+
+	errs = append(errs, c.Connection.validate())
+	errs = append(errs, validateLogLevel(c.LogLevel))
+
+	return errors.Join(errs...)
+}
+
+// ACLRules 转换为 server 包使用的访问控制规则
+func (c *ServerConfig) ACLRules() ([]server.Rule, error) {
+	return parseRules("acl", c.ACL)
+}
+
+// ReverseRules 转换为远程端口转发监听地址的访问控制规则
+func (c *ServerConfig) ReverseRules() ([]server.Rule, error) {
+	return parseRules("reverseAcl", c.ReverseACL)
+}
+
+func parseRules(name string, configs []ACLRuleConfig) ([]server.Rule, error) {
+	var errs []error
+
+	rules := make([]server.Rule, 0, len(configs))
+	for i, rule := range configs {
+		r, err := server.ParseRule(rule.Action, rule.Users, rule.CIDRs, rule.Domains, rule.Ports)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s[%d]: %w", name, i, err))
+			continue
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, errors.Join(errs...)
+}
+
+func (c *DNSConfig) validate() error {
+	var errs []error
+
+	if _, err := server.ParseIPPreference(c.Prefer); err != nil {
+		errs = append(errs, fmt.Errorf("dns.prefer: %w", err))
+	}
+
+	if c.Timeout <= 0 {
+		errs = append(errs, errors.New("dns.timeout: must be positive"))
+	}
+
+	if c.CacheSize < 0 {
+		errs = append(errs, errors.New("dns.cacheSize: must not be negative"))
+	}
+
+	// 校验上游服务器地址及 hosts 文件内容
+	if err := validateFile("dns.hosts", c.Hosts, false); err != nil {
+		errs = append(errs, err)
+	} else if _, err := c.Resolver(); err != nil {
+		errs = append(errs, fmt.Errorf("dns.%w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Resolver 创建 server 包使用的域名解析
+func (c *DNSConfig) Resolver() (*server.Resolver, error) {
+	resolver, err := server.NewResolver(c.Upstreams, c.Hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver.Prefer, _ = server.ParseIPPreference(c.Prefer)
+	resolver.Timeout = c.Timeout.Std()
+	resolver.CacheSize = c.CacheSize
+
+	return resolver, nil
+}
+
+func (c *ServerConfig) validateTLS() error {
+	if !c.TLS.Enable {
+		return nil
+	}
+
+	var errs []error
+
+	errs = append(errs, validateFile("tls.cert", c.TLS.Cert, true))
+	errs = append(errs, validateFile("tls.key", c.TLS.Key, true))
+	errs = append(errs, validateFile("tls.clientCA", c.TLS.ClientCA, false))
+
+	if c.TLS.RequireClientCert && c.TLS.ClientCA == "" {
+		errs = append(errs, errors.New("tls.clientCA: must not be empty when tls.requireClientCert is true"))
+	}
+
+	// 没有 CA 校验证书链时 CN 可以伪造，只能使用指纹
+	if c.TLS.ClientCA == "" {
+		for i, client := range c.TLS.AllowedClients {
+			if _, ok := server.ParseCertFingerprint(client); !ok {
+				errs = append(errs, fmt.Errorf("tls.allowedClients[%d]: common name %q requires tls.clientCA, use a certificate fingerprint instead", i, client))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *CipherConfig) validate(tlsEnable bool) error {
+	if !c.Enable {
+		return nil
+	}
+
+	if tlsEnable {
+		return errors.New("cipher: tls and cipher can not be enabled at the same time")
+	}
+
+	if _, err := network.NewCipher(c.Method, c.Key); err != nil {
+		return fmt.Errorf("cipher: %w", err)
+	}
+
+	return nil
+}
+
+// Network 构造 network 包使用的加密参数，未启用时返回 nil
+func (c *CipherConfig) Network() (*network.Cipher, error) {
+	if !c.Enable {
+		return nil, nil
+	}
+
+	return network.NewCipher(c.Method, c.Key)
+}
+
+func (c *ConnectionConfig) validate() error {
+	var errs []error
+
+	positive := func(name string, d Duration) {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("connection.%s: must be positive", name))
+		}
+	}
+
+	positive("heartbeatInterval", c.HeartbeatInterval)
+	positive("heartbeatTimeout", c.HeartbeatTimeout)
+	positive("udpIdleTimeout", c.UdpIdleTimeout)
+	positive("bindAcceptTimeout", c.BindAcceptTimeout)
+	positive("dialTimeout", c.DialTimeout)
+
+	if c.HeartbeatInterval > 0 && c.HeartbeatTimeout <= c.HeartbeatInterval {
+		errs = append(errs, errors.New("connection.heartbeatTimeout: must be greater than heartbeatInterval"))
+	}
+
+	if c.WriteBuffSize <= 0 {
+		errs = append(errs, errors.New("connection.writeBuffSize: must be positive"))
+	}
+
+	if c.ReadBuffSize <= 0 {
+		errs = append(errs, errors.New("connection.readBuffSize: must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *ReconnectConfig) validate() error {
+	var errs []error
+
+	if c.InitialInterval <= 0 {
+		errs = append(errs, errors.New("reconnect.initialInterval: must be positive"))
+	}
+
+	if c.MaxInterval < c.InitialInterval {
+		errs = append(errs, errors.New("reconnect.maxInterval: must not be less than initialInterval"))
+	}
+
+	if c.Multiplier < 1 {
+		errs = append(errs, errors.New("reconnect.multiplier: must not be less than 1"))
+	}
+
+	if c.Jitter < 0 || c.Jitter > 1 {
+		errs = append(errs, errors.New("reconnect.jitter: must be between 0 and 1"))
+	}
+
+	if c.MaxAttempts < 0 {
+		errs = append(errs, errors.New("reconnect.maxAttempts: must not be negative"))
+	}
+
+	if c.MaxElapsed < 0 {
+		errs = append(errs, errors.New("reconnect.maxElapsed: must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Backoff 转换为 client 包使用的退避参数
+func (c *ReconnectConfig) Backoff() client.Backoff {
+	return client.Backoff{
+		InitialInterval: c.InitialInterval.Std(),
+		MaxInterval:     c.MaxInterval.Std(),
+		Multiplier:      c.Multiplier,
+		Jitter:          c.Jitter,
+		MaxAttempts:     c.MaxAttempts,
+		MaxElapsed:      c.MaxElapsed.Std(),
+	}
+}
+
+// Network 转换为 network 包使用的连接参数
+func (c *ConnectionConfig) Network() network.ConnectionConfig {
+	return network.ConnectionConfig{
+		HeartbeatInterval: c.HeartbeatInterval.Std(),
+		HeartbeatTimeout:  c.HeartbeatTimeout.Std(),
+		UdpIdleTimeout:    c.UdpIdleTimeout.Std(),
+		BindAcceptTimeout: c.BindAcceptTimeout.Std(),
+		DialTimeout:       c.DialTimeout.Std(),
+		WriteBuffSize:     c.WriteBuffSize,
+		ReadBuffSize:      c.ReadBuffSize,
+	}
+}
+
+func validateAddr(name string, addr string, required bool) error {
+	if addr == "" {
+		if required {
+			return fmt.Errorf("%s: must not be empty", name)
+		}
+		return nil
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("%s: invalid address %q: %w", name, addr, err)
+	}
+
+	return nil
+}
+
+func validateFile(name string, path string, required bool) error {
+	if path == "" {
+		if required {
+			return fmt.Errorf("%s: must not be empty", name)
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+func validateLogLevel(level string) error {
+	if _, err := util.ParseLogLevel(level); err != nil {
+		return fmt.Errorf("logLevel: %w", err)
+	}
+
+	return nil
+}
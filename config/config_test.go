@@ -0,0 +1,455 @@
+package config
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssp/client"
+	"github.com/ssp/network"
+	"github.com/ssp/server"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// checkErr 校验错误包含 want 中的每一项，want 为空时不应出错
+func checkErr(t *testing.T, name string, err error, want []string) {
+	t.Helper()
+
+	if len(want) == 0 {
+		if err != nil {
+			t.Fatalf("%s error = %v, want nil", name, err)
+		}
+		return
+	}
+
+	if err == nil {
+		t.Fatalf("%s error = nil, want %q", name, want)
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("%s error = %v, want %q", name, err, w)
+		}
+	}
+}
+
+func TestLoadClientConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		check   func(t *testing.T, c *ClientConfig)
+		wantErr []string
+	}{
+		{
+			name:    "partial config keeps defaults",
+			content: `{"login": {"name": "allen"}, "connection": {"dialTimeout": "1m30s"}}`,
+			check: func(t *testing.T, c *ClientConfig) {
+				want := DefaultClientConfig()
+				want.Login.Name = "allen"
+				want.Connection.DialTimeout = Duration(90 * time.Second)
+				if !reflect.DeepEqual(c, want) {
+					t.Errorf("LoadClientConfig() = %+v, want %+v", c, want)
+				}
+			},
+		},
+		{name: "unknown field", content: `{"sever": "localhost:9090"}`, wantErr: []string{`unknown field "sever"`}},
+		{name: "unknown nested field", content: `{"tls": {"enabled": true}}`, wantErr: []string{`unknown field "enabled"`}},
+		{name: "duration as number", content: `{"rpcTimeout": 5}`, wantErr: []string{`duration must be a string such as "5s"`}},
+		{name: "invalid duration", content: `{"rpcTimeout": "5 seconds"}`, wantErr: []string{`time: unknown unit`}},
+		{name: "invalid json", content: `{"server": }`, wantErr: []string{"invalid character"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, "sspc.json", tt.content)
+
+			c, err := LoadClientConfig(path)
+			checkErr(t, "LoadClientConfig()", err, tt.wantErr)
+			if err != nil {
+				return
+			}
+
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestLoadConfigPath(t *testing.T) {
+	c, err := LoadClientConfig("")
+	if err != nil {
+		t.Fatalf("LoadClientConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(c, DefaultClientConfig()) {
+		t.Errorf("LoadClientConfig() = %+v, want defaults", c)
+	}
+
+	s, err := LoadServerConfig("")
+	if err != nil {
+		t.Fatalf("LoadServerConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(s, DefaultServerConfig()) {
+		t.Errorf("LoadServerConfig() = %+v, want defaults", s)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	if _, err := LoadServerConfig(missing); err == nil || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("LoadServerConfig() error = %v, want no such file", err)
+	}
+}
+
+// 示例配置中的字段必须与配置结构一致
+func TestLoadExampleConfigs(t *testing.T) {
+	c, err := LoadClientConfig("sspc.example.json")
+	if err != nil {
+		t.Fatalf("LoadClientConfig() error = %v", err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	s, err := LoadServerConfig("ssps.example.json")
+	if err != nil {
+		t.Fatalf("LoadServerConfig() error = %v", err)
+	}
+
+	// 示例中的用户文件为相对路径，替换后校验其余字段
+	s.UsersFile = writeFile(t, "users.txt", "")
+	if err := s.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Duration
+		wantErr bool
+	}{
+		{`"5s"`, Duration(5 * time.Second), false},
+		{`"1m30s"`, Duration(90 * time.Second), false},
+		{`"0s"`, 0, false},
+		{`"-1s"`, Duration(-time.Second), false},
+
+		{`5`, 0, true},
+		{`null`, 0, true},
+		{`""`, 0, true},
+		{`"5"`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var d Duration
+			err := d.UnmarshalJSON([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if d != tt.want {
+				t.Errorf("UnmarshalJSON() = %s, want %s", d.Std(), tt.want.Std())
+			}
+		})
+	}
+
+	data, err := Duration(90 * time.Second).MarshalJSON()
+	if err != nil || string(data) != `"1m30s"` {
+		t.Errorf("MarshalJSON() = %s, %v, want \"1m30s\"", data, err)
+	}
+}
+
+func TestClientConfigValidate(t *testing.T) {
+	file := writeFile(t, "file", "")
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name    string
+		modify  func(c *ClientConfig)
+		wantErr []string
+	}{
+		{"valid", func(c *ClientConfig) {}, nil},
+		{"servers replace server", func(c *ClientConfig) { c.Server = ""; c.Servers = []string{"a:1", "b:2"} }, nil},
+		{"tls with client cert", func(c *ClientConfig) { c.TLS = ClientTLSConfig{Enable: true, CA: file, Cert: file, Key: file} }, nil},
+		{"cipher", func(c *ClientConfig) { c.Cipher = CipherConfig{Enable: true, Method: "AES-256-GCM", Key: "secret"} }, nil},
+		{"cipher disabled is not checked", func(c *ClientConfig) { c.Cipher = CipherConfig{Method: "rc4"} }, nil},
+
+		{"server empty", func(c *ClientConfig) { c.Server = "" }, []string{"server: must not be empty"}},
+		{"server without port", func(c *ClientConfig) { c.Server = "localhost" }, []string{`server: invalid address "localhost"`}},
+		{"servers entry invalid", func(c *ClientConfig) { c.Servers = []string{"a:1", "b"} }, []string{"servers[1]: invalid address"}},
+		{"server policy", func(c *ClientConfig) { c.ServerPolicy = "random" }, []string{"serverPolicy: must be one of"}},
+		{"socks5 addr empty", func(c *ClientConfig) { c.Socks5Addr = "" }, []string{"socks5Addr: must not be empty"}},
+		{"http addr invalid", func(c *ClientConfig) { c.HttpAddr = "8080" }, []string{"httpAddr: invalid address"}},
+		{"require auth without users", func(c *ClientConfig) { c.Auth.RequireAuth = true }, []string{"auth.users: must not be empty when auth.requireAuth is true"}},
+		{"login name empty", func(c *ClientConfig) { c.Login.Name = "" }, []string{"login.name: must not be empty"}},
+		{"tunnels", func(c *ClientConfig) { c.Tunnels = 0 }, []string{"tunnels: must be positive"}},
+		{"balance", func(c *ClientConfig) { c.Balance = "random" }, []string{"balance: must be one of"}},
+		{"tls ca missing", func(c *ClientConfig) { c.TLS = ClientTLSConfig{Enable: true, CA: missing} }, []string{"tls.ca: stat"}},
+		{"tls cert without key", func(c *ClientConfig) { c.TLS = ClientTLSConfig{Enable: true, Cert: file} }, []string{"tls.key: must not be empty"}},
+		{"tls and cipher", func(c *ClientConfig) {
+			c.TLS.Enable = true
+			c.Cipher = CipherConfig{Enable: true, Method: "aes-256-gcm", Key: "secret"}
+		}, []string{"cipher: tls and cipher can not be enabled at the same time"}},
+		{"cipher method", func(c *ClientConfig) { c.Cipher = CipherConfig{Enable: true, Method: "rc4", Key: "secret"} }, []string{"cipher: unsupported cipher method: rc4"}},
+		{"cipher key missing", func(c *ClientConfig) { c.Cipher = CipherConfig{Enable: true, Method: "aes-256-gcm"} }, []string{"cipher: cipher key must not be empty"}},
+		{"rpc timeout", func(c *ClientConfig) { c.RpcTimeout = 0 }, []string{"rpcTimeout: must be positive"}},
+		{"reconnect initial interval", func(c *ClientConfig) { c.Reconnect.InitialInterval = 0 }, []string{"reconnect.initialInterval: must be positive"}},
+		{"reconnect max interval", func(c *ClientConfig) { c.Reconnect.MaxInterval = Duration(time.Millisecond) }, []string{"reconnect.maxInterval: must not be less than initialInterval"}},
+		{"reconnect multiplier", func(c *ClientConfig) { c.Reconnect.Multiplier = 0.5 }, []string{"reconnect.multiplier: must not be less than 1"}},
+		{"reconnect jitter", func(c *ClientConfig) { c.Reconnect.Jitter = 1.5 }, []string{"reconnect.jitter: must be between 0 and 1"}},
+		{"reconnect max attempts", func(c *ClientConfig) { c.Reconnect.MaxAttempts = -1 }, []string{"reconnect.maxAttempts: must not be negative"}},
+		{"reconnect max elapsed", func(c *ClientConfig) { c.Reconnect.MaxElapsed = -1 }, []string{"reconnect.maxElapsed: must not be negative"}},
+		{"routing rules missing", func(c *ClientConfig) { c.Routing.Rules = missing }, []string{"routing.rules: stat"}},
+		{"forward duplicate local", func(c *ClientConfig) {
+			c.Forwards = []ForwardConfig{{Local: ":8080", Remote: "a:80"}, {Local: ":8080", Remote: "b:80"}}
+		}, []string{`forwards[1].local: duplicate address ":8080"`}},
+		{"forward remote empty", func(c *ClientConfig) { c.Forwards = []ForwardConfig{{Local: ":8080"}} }, []string{"forwards[0].remote: must not be empty"}},
+		{"reverse local invalid", func(c *ClientConfig) { c.Reverse = []ReverseConfig{{Remote: ":8080", Local: "localhost"}} }, []string{"reverse[0].local: invalid address"}},
+		{"shutdown timeout", func(c *ClientConfig) { c.ShutdownTimeout = 0 }, []string{"shutdownTimeout: must be positive"}},
+		{"heartbeat timeout", func(c *ClientConfig) { c.Connection.HeartbeatTimeout = c.Connection.HeartbeatInterval }, []string{"connection.heartbeatTimeout: must be greater than heartbeatInterval"}},
+		{"dial timeout", func(c *ClientConfig) { c.Connection.DialTimeout = 0 }, []string{"connection.dialTimeout: must be positive"}},
+		{"write buff size", func(c *ClientConfig) { c.Connection.WriteBuffSize = 0 }, []string{"connection.writeBuffSize: must be positive"}},
+		{"log level", func(c *ClientConfig) { c.LogLevel = "trace" }, []string{"logLevel: invalid log level: trace"}},
+		{"all errors reported", func(c *ClientConfig) { c.Login.Name = ""; c.Tunnels = 0 }, []string{"login.name", "tunnels"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultClientConfig()
+			c.Login.Name = "allen"
+			tt.modify(c)
+
+			checkErr(t, "Validate()", c.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestServerConfigValidate(t *testing.T) {
+	file := writeFile(t, "file", "")
+	fingerprint := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name    string
+		modify  func(c *ServerConfig)
+		wantErr []string
+	}{
+		{"valid", func(c *ServerConfig) {}, nil},
+		{"tls", func(c *ServerConfig) { c.TLS = ServerTLSConfig{Enable: true, Cert: file, Key: file} }, nil},
+		{"allowed fingerprint without ca", func(c *ServerConfig) {
+			c.TLS = ServerTLSConfig{Enable: true, Cert: file, Key: file, AllowedClients: []string{fingerprint}}
+		}, nil},
+		{"allowed cn with ca", func(c *ServerConfig) {
+			c.TLS = ServerTLSConfig{Enable: true, Cert: file, Key: file, ClientCA: file, RequireClientCert: true, AllowedClients: []string{"client"}}
+		}, nil},
+		{"acl", func(c *ServerConfig) {
+			c.ACL = []ACLRuleConfig{{Action: "allow", CIDRs: []string{"10.0.0.0/8"}, Ports: []string{"443"}}}
+		}, nil},
+
+		{"listen", func(c *ServerConfig) { c.Listen = "" }, []string{"listen: must not be empty"}},
+		{"users file empty", func(c *ServerConfig) { c.UsersFile = "" }, []string{"usersFile: must not be empty"}},
+		{"users file missing", func(c *ServerConfig) { c.UsersFile = file + ".missing" }, []string{"usersFile: stat"}},
+		{"tls cert missing", func(c *ServerConfig) { c.TLS = ServerTLSConfig{Enable: true, Key: file} }, []string{"tls.cert: must not be empty"}},
+		{"require client cert without ca", func(c *ServerConfig) {
+			c.TLS = ServerTLSConfig{Enable: true, Cert: file, Key: file, RequireClientCert: true}
+		}, []string{"tls.clientCA: must not be empty when tls.requireClientCert is true"}},
+		{"allowed cn without ca", func(c *ServerConfig) {
+			c.TLS = ServerTLSConfig{Enable: true, Cert: file, Key: file, AllowedClients: []string{fingerprint, "client"}}
+		}, []string{`tls.allowedClients[1]: common name "client" requires tls.clientCA`}},
+		{"tls and cipher", func(c *ServerConfig) {
+			c.TLS = ServerTLSConfig{Enable: true, Cert: file, Key: file}
+			c.Cipher = CipherConfig{Enable: true, Method: "chacha20-poly1305", Key: "secret"}
+		}, []string{"cipher: tls and cipher can not be enabled at the same time"}},
+		{"cipher method", func(c *ServerConfig) { c.Cipher = CipherConfig{Enable: true, Method: "rc4", Key: "secret"} }, []string{"cipher: unsupported cipher method"}},
+		{"acl action", func(c *ServerConfig) { c.ACL = []ACLRuleConfig{{Action: "allow"}, {Action: "permit"}} }, []string{`acl[1]: action: unknown action "permit"`}},
+		{"acl cidr", func(c *ServerConfig) { c.ACL = []ACLRuleConfig{{Action: "deny", CIDRs: []string{"10.0.0.1"}}} }, []string{"acl[0]:"}},
+		{"reverse acl port", func(c *ServerConfig) {
+			c.ReverseACL = []ACLRuleConfig{{Action: "allow", Ports: []string{"8100-8000"}}}
+		}, []string{`reverseAcl[0]: ports: invalid port "8100-8000"`}},
+		{"dns prefer", func(c *ServerConfig) { c.DNS.Prefer = "ipv5" }, []string{`dns.prefer: unknown ip preference "ipv5"`}},
+		{"dns timeout", func(c *ServerConfig) { c.DNS.Timeout = 0 }, []string{"dns.timeout: must be positive"}},
+		{"dns cache size", func(c *ServerConfig) { c.DNS.CacheSize = -1 }, []string{"dns.cacheSize: must not be negative"}},
+		{"dns upstream", func(c *ServerConfig) { c.DNS.Upstreams = []string{"8.8.8.8", "quic://dns.google"} }, []string{"dns.upstreams[1]:"}},
+		{"dns hosts missing", func(c *ServerConfig) { c.DNS.Hosts = file + ".missing" }, []string{"dns.hosts: stat"}},
+		{"shutdown timeout", func(c *ServerConfig) { c.ShutdownTimeout = -1 }, []string{"shutdownTimeout: must be positive"}},
+		{"read buff size", func(c *ServerConfig) { c.Connection.ReadBuffSize = 0 }, []string{"connection.readBuffSize: must be positive"}},
+		{"log level", func(c *ServerConfig) { c.LogLevel = "verbose" }, []string{"logLevel: invalid log level"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultServerConfig()
+			c.UsersFile = file
+			tt.modify(c)
+
+			checkErr(t, "Validate()", c.Validate(), tt.wantErr)
+		})
+	}
+}
+
+func TestClientConfigConversion(t *testing.T) {
+	c := DefaultClientConfig()
+
+	if got := c.ServerList(); !reflect.DeepEqual(got, []string{"localhost:9090"}) {
+		t.Errorf("ServerList() = %v, want the single server", got)
+	}
+
+	c.Servers = []string{"a:1", "b:2"}
+	if got := c.ServerList(); !reflect.DeepEqual(got, c.Servers) {
+		t.Errorf("ServerList() = %v, want %v", got, c.Servers)
+	}
+
+	if got := c.ReverseForwards(); len(got) != 0 {
+		t.Errorf("ReverseForwards() = %v, want empty", got)
+	}
+
+	c.Reverse = []ReverseConfig{{Remote: ":8080", Local: "localhost:80"}, {Remote: "0.0.0.0:2222", Local: "localhost:22"}}
+	wantForwards := []client.ReverseForward{{Remote: ":8080", Local: "localhost:80"}, {Remote: "0.0.0.0:2222", Local: "localhost:22"}}
+	if got := c.ReverseForwards(); !reflect.DeepEqual(got, wantForwards) {
+		t.Errorf("ReverseForwards() = %v, want %v", got, wantForwards)
+	}
+
+	// 默认配置与各包的默认参数一致
+	if got := c.Reconnect.Backoff(); got != client.DefaultBackoff() {
+		t.Errorf("Backoff() = %+v, want %+v", got, client.DefaultBackoff())
+	}
+	if got := c.Connection.Network(); got != network.DefaultConnectionConfig() {
+		t.Errorf("Network() = %+v, want %+v", got, network.DefaultConnectionConfig())
+	}
+
+	reconnect := ReconnectConfig{
+		InitialInterval: Duration(time.Second),
+		MaxInterval:     Duration(time.Minute),
+		Multiplier:      1.5,
+		Jitter:          0.1,
+		MaxAttempts:     3,
+		MaxElapsed:      Duration(time.Hour),
+	}
+	wantBackoff := client.Backoff{
+		InitialInterval: time.Second,
+		MaxInterval:     time.Minute,
+		Multiplier:      1.5,
+		Jitter:          0.1,
+		MaxAttempts:     3,
+		MaxElapsed:      time.Hour,
+	}
+	if got := reconnect.Backoff(); got != wantBackoff {
+		t.Errorf("Backoff() = %+v, want %+v", got, wantBackoff)
+	}
+
+	connection := ConnectionConfig{
+		HeartbeatInterval: Duration(time.Second),
+		HeartbeatTimeout:  Duration(3 * time.Second),
+		UdpIdleTimeout:    Duration(time.Minute),
+		BindAcceptTimeout: Duration(2 * time.Minute),
+		DialTimeout:       Duration(4 * time.Second),
+		WriteBuffSize:     16,
+		ReadBuffSize:      32,
+	}
+	wantConnection := network.ConnectionConfig{
+		HeartbeatInterval: time.Second,
+		HeartbeatTimeout:  3 * time.Second,
+		UdpIdleTimeout:    time.Minute,
+		BindAcceptTimeout: 2 * time.Minute,
+		DialTimeout:       4 * time.Second,
+		WriteBuffSize:     16,
+		ReadBuffSize:      32,
+	}
+	if got := connection.Network(); got != wantConnection {
+		t.Errorf("Network() = %+v, want %+v", got, wantConnection)
+	}
+}
+
+func TestCipherConfigNetwork(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  CipherConfig
+		wantNil bool
+		wantErr bool
+	}{
+		{"disabled", CipherConfig{Method: "rc4"}, true, false},
+		{"aes-256-gcm", CipherConfig{Enable: true, Method: "aes-256-gcm", Key: "secret"}, false, false},
+		{"chacha20-poly1305", CipherConfig{Enable: true, Method: "chacha20-poly1305", Key: "secret"}, false, false},
+		{"unsupported method", CipherConfig{Enable: true, Method: "rc4", Key: "secret"}, true, true},
+		{"empty key", CipherConfig{Enable: true, Method: "aes-256-gcm"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cipher, err := tt.config.Network()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Network() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (cipher == nil) != tt.wantNil {
+				t.Errorf("Network() = %v, want nil %v", cipher, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestServerConfigRules(t *testing.T) {
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/8")
+
+	c := DefaultServerConfig()
+	c.ACL = []ACLRuleConfig{
+		{Action: "deny", Domains: []string{"*.internal"}},
+		{Action: "allow", Users: []string{"allen"}, CIDRs: []string{"10.0.0.0/8"}, Ports: []string{"5432", "8000-8100"}},
+	}
+	c.ReverseACL = []ACLRuleConfig{{Action: "allow", Ports: []string{"8080"}}}
+
+	wantACL := []server.Rule{
+		{Action: server.Deny, Domains: []string{"*.internal"}},
+		{Action: server.Allow, Users: []string{"allen"}, CIDRs: []*net.IPNet{cidr}, Ports: []server.PortRange{{From: 5432, To: 5432}, {From: 8000, To: 8100}}},
+	}
+
+	acl, err := c.ACLRules()
+	if err != nil {
+		t.Fatalf("ACLRules() error = %v", err)
+	}
+	if !reflect.DeepEqual(acl, wantACL) {
+		t.Errorf("ACLRules() = %+v, want %+v", acl, wantACL)
+	}
+
+	reverse, err := c.ReverseRules()
+	if err != nil {
+		t.Fatalf("ReverseRules() error = %v", err)
+	}
+	if want := []server.Rule{{Action: server.Allow, Ports: []server.PortRange{{From: 8080, To: 8080}}}}; !reflect.DeepEqual(reverse, want) {
+		t.Errorf("ReverseRules() = %+v, want %+v", reverse, want)
+	}
+
+	// 出错时返回其余合法的规则及所有错误
+	c.ACL = append(c.ACL, ACLRuleConfig{Action: "permit"}, ACLRuleConfig{Action: "deny", Ports: []string{"x"}})
+	acl, err = c.ACLRules()
+	checkErr(t, "ACLRules()", err, []string{"acl[2]:", "acl[3]:"})
+	if !reflect.DeepEqual(acl, wantACL) {
+		t.Errorf("ACLRules() = %+v, want %+v", acl, wantACL)
+	}
+}
+
+func TestDNSConfigResolver(t *testing.T) {
+	hosts := writeFile(t, "hosts", "192.0.2.1 example.test\n")
+
+	c := DNSConfig{Upstreams: []string{"8.8.8.8", "tls://1.1.1.1"}, Hosts: hosts, Prefer: "ipv6-only", Timeout: Duration(2 * time.Second), CacheSize: 16}
+
+	resolver, err := c.Resolver()
+	if err != nil {
+		t.Fatalf("Resolver() error = %v", err)
+	}
+	if resolver.Prefer != server.IPv6Only || resolver.Timeout != 2*time.Second || resolver.CacheSize != 16 || resolver.HostsPath != hosts {
+		t.Errorf("Resolver() = %+v", resolver)
+	}
+
+	c.Upstreams = []string{"ftp://8.8.8.8"}
+	if _, err := c.Resolver(); err == nil {
+		t.Error("Resolver() with invalid upstream error = nil")
+	}
+}
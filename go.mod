@@ -3,6 +3,9 @@ module github.com/ssp
 go 1.20
 
 require (
-	github.com/golang/protobuf v1.5.3 // indirect
-	google.golang.org/protobuf v1.30.0 // indirect
+	github.com/golang/protobuf v1.5.3
+	golang.org/x/crypto v0.9.0
+	google.golang.org/protobuf v1.30.0
 )
+
+require golang.org/x/sys v0.9.0 // indirect
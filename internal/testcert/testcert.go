@@ -0,0 +1,136 @@
+// Package testcert 为 TLS 相关测试生成证书并在本地连接上验证握手
+package testcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Cert 测试时生成的 ECDSA 自签名证书，同时可作为 CA
+type Cert struct {
+	Cert    *x509.Certificate
+	TLSCert tls.Certificate
+
+	// PEM 格式的证书及私钥文件，位于测试的临时目录
+	CertPath string
+	KeyPath  string
+}
+
+// New 生成 CN 为 cn 的证书，同时适用于 localhost、127.0.0.1 的服务端及客户端认证
+func New(t *testing.T, cn string) *Cert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	c := &Cert{
+		CertPath: filepath.Join(t.TempDir(), cn+".pem"),
+		KeyPath:  filepath.Join(t.TempDir(), cn+".key"),
+	}
+
+	if err := os.WriteFile(c.CertPath, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.KeyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.TLSCert, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	if c.Cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// Handshake 在本地 TCP 连接上完成握手并交换一个字节，任一端失败时返回错误
+func Handshake(t *testing.T, serverConfig *tls.Config, clientConfig *tls.Config) error {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(deadline)
+
+		// TLS 1.3 中客户端证书被拒绝时客户端的握手可能已经完成，由读取第一个记录发现
+		server := tls.Server(conn, serverConfig)
+		_, err = server.Read(make([]byte, 1))
+		if err == nil {
+			_, err = server.Write([]byte{0})
+		}
+		serverErr <- err
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+
+	client := tls.Client(conn, clientConfig)
+	_, err = client.Write([]byte{0})
+	if err == nil {
+		_, err = client.Read(make([]byte, 1))
+	}
+
+	if sErr := <-serverErr; sErr != nil {
+		return sErr
+	}
+
+	return err
+}
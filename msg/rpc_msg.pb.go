@@ -265,6 +265,7 @@ type NewChannelRes struct {
 	Code      int32  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
 	Msg       string `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
 	ChannelId uint32 `protobuf:"varint,3,opt,name=channelId,proto3" json:"channelId,omitempty"`
+	BindAddr  string `protobuf:"bytes,4,opt,name=bindAddr,proto3" json:"bindAddr,omitempty"`
 }
 
 func (x *NewChannelRes) Reset() {
@@ -320,6 +321,438 @@ func (x *NewChannelRes) GetChannelId() uint32 {
 	return 0
 }
 
+func (x *NewChannelRes) GetBindAddr() string {
+	if x != nil {
+		return x.BindAddr
+	}
+	return ""
+}
+
+type NewAssociateReq struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TraceId string `protobuf:"bytes,1,opt,name=traceId,proto3" json:"traceId,omitempty"`
+}
+
+func (x *NewAssociateReq) Reset() {
+	*x = NewAssociateReq{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_msg_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NewAssociateReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewAssociateReq) ProtoMessage() {}
+
+func (x *NewAssociateReq) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_msg_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewAssociateReq.ProtoReflect.Descriptor instead.
+func (*NewAssociateReq) Descriptor() ([]byte, []int) {
+	return file_rpc_msg_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *NewAssociateReq) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+type NewAssociateRes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code        int32  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Msg         string `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	AssociateId uint32 `protobuf:"varint,3,opt,name=associateId,proto3" json:"associateId,omitempty"`
+}
+
+func (x *NewAssociateRes) Reset() {
+	*x = NewAssociateRes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_msg_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NewAssociateRes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewAssociateRes) ProtoMessage() {}
+
+func (x *NewAssociateRes) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_msg_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewAssociateRes.ProtoReflect.Descriptor instead.
+func (*NewAssociateRes) Descriptor() ([]byte, []int) {
+	return file_rpc_msg_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *NewAssociateRes) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *NewAssociateRes) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *NewAssociateRes) GetAssociateId() uint32 {
+	if x != nil {
+		return x.AssociateId
+	}
+	return 0
+}
+
+type BindAcceptReq struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ChannelId uint32 `protobuf:"varint,1,opt,name=channelId,proto3" json:"channelId,omitempty"`
+	TraceId   string `protobuf:"bytes,2,opt,name=traceId,proto3" json:"traceId,omitempty"`
+}
+
+func (x *BindAcceptReq) Reset() {
+	*x = BindAcceptReq{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_msg_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BindAcceptReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BindAcceptReq) ProtoMessage() {}
+
+func (x *BindAcceptReq) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_msg_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BindAcceptReq.ProtoReflect.Descriptor instead.
+func (*BindAcceptReq) Descriptor() ([]byte, []int) {
+	return file_rpc_msg_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BindAcceptReq) GetChannelId() uint32 {
+	if x != nil {
+		return x.ChannelId
+	}
+	return 0
+}
+
+func (x *BindAcceptReq) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+type BindAcceptRes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code     int32  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Msg      string `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	PeerAddr string `protobuf:"bytes,3,opt,name=peerAddr,proto3" json:"peerAddr,omitempty"`
+}
+
+func (x *BindAcceptRes) Reset() {
+	*x = BindAcceptRes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_msg_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BindAcceptRes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BindAcceptRes) ProtoMessage() {}
+
+func (x *BindAcceptRes) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_msg_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BindAcceptRes.ProtoReflect.Descriptor instead.
+func (*BindAcceptRes) Descriptor() ([]byte, []int) {
+	return file_rpc_msg_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *BindAcceptRes) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *BindAcceptRes) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *BindAcceptRes) GetPeerAddr() string {
+	if x != nil {
+		return x.PeerAddr
+	}
+	return ""
+}
+
+type ReverseListenReq struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Addr    string `protobuf:"bytes,1,opt,name=addr,proto3" json:"addr,omitempty"`
+	TraceId string `protobuf:"bytes,2,opt,name=traceId,proto3" json:"traceId,omitempty"`
+}
+
+func (x *ReverseListenReq) Reset() {
+	*x = ReverseListenReq{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_msg_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReverseListenReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReverseListenReq) ProtoMessage() {}
+
+func (x *ReverseListenReq) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_msg_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReverseListenReq.ProtoReflect.Descriptor instead.
+func (*ReverseListenReq) Descriptor() ([]byte, []int) {
+	return file_rpc_msg_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ReverseListenReq) GetAddr() string {
+	if x != nil {
+		return x.Addr
+	}
+	return ""
+}
+
+func (x *ReverseListenReq) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+type ReverseListenRes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Code       int32  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Msg        string `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	ListenerId uint32 `protobuf:"varint,3,opt,name=listenerId,proto3" json:"listenerId,omitempty"`
+	BindAddr   string `protobuf:"bytes,4,opt,name=bindAddr,proto3" json:"bindAddr,omitempty"`
+}
+
+func (x *ReverseListenRes) Reset() {
+	*x = ReverseListenRes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_msg_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReverseListenRes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReverseListenRes) ProtoMessage() {}
+
+func (x *ReverseListenRes) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_msg_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReverseListenRes.ProtoReflect.Descriptor instead.
+func (*ReverseListenRes) Descriptor() ([]byte, []int) {
+	return file_rpc_msg_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ReverseListenRes) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *ReverseListenRes) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *ReverseListenRes) GetListenerId() uint32 {
+	if x != nil {
+		return x.ListenerId
+	}
+	return 0
+}
+
+func (x *ReverseListenRes) GetBindAddr() string {
+	if x != nil {
+		return x.BindAddr
+	}
+	return ""
+}
+
+type ReverseChannelReq struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ListenerId uint32 `protobuf:"varint,1,opt,name=listenerId,proto3" json:"listenerId,omitempty"`
+	ChannelId  uint32 `protobuf:"varint,2,opt,name=channelId,proto3" json:"channelId,omitempty"`
+	PeerAddr   string `protobuf:"bytes,3,opt,name=peerAddr,proto3" json:"peerAddr,omitempty"`
+	TraceId    string `protobuf:"bytes,4,opt,name=traceId,proto3" json:"traceId,omitempty"`
+}
+
+func (x *ReverseChannelReq) Reset() {
+	*x = ReverseChannelReq{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_rpc_msg_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReverseChannelReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReverseChannelReq) ProtoMessage() {}
+
+func (x *ReverseChannelReq) ProtoReflect() protoreflect.Message {
+	mi := &file_rpc_msg_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReverseChannelReq.ProtoReflect.Descriptor instead.
+func (*ReverseChannelReq) Descriptor() ([]byte, []int) {
+	return file_rpc_msg_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ReverseChannelReq) GetListenerId() uint32 {
+	if x != nil {
+		return x.ListenerId
+	}
+	return 0
+}
+
+func (x *ReverseChannelReq) GetChannelId() uint32 {
+	if x != nil {
+		return x.ChannelId
+	}
+	return 0
+}
+
+func (x *ReverseChannelReq) GetPeerAddr() string {
+	if x != nil {
+		return x.PeerAddr
+	}
+	return ""
+}
+
+func (x *ReverseChannelReq) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
 var File_rpc_msg_proto protoreflect.FileDescriptor
 
 var file_rpc_msg_proto_rawDesc = []byte{
@@ -339,14 +772,54 @@ var file_rpc_msg_proto_rawDesc = []byte{
 	0x0d, 0x6e, 0x65, 0x77, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x12, 0x12,
 	0x0a, 0x04, 0x61, 0x64, 0x64, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x64,
 	0x64, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x22, 0x53, 0x0a, 0x0d,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x22, 0x6f, 0x0a, 0x0d,
 	0x6e, 0x65, 0x77, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x52, 0x65, 0x73, 0x12, 0x12, 0x0a,
 	0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64,
 	0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
 	0x6d, 0x73, 0x67, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x49, 0x64,
 	0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x49,
-	0x64, 0x42, 0x07, 0x5a, 0x05, 0x2e, 0x2f, 0x6d, 0x73, 0x67, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x64, 0x12, 0x1a, 0x0a, 0x08, 0x62, 0x69, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x62, 0x69, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x22, 0x2b, 0x0a,
+	0x0f, 0x6e, 0x65, 0x77, 0x41, 0x73, 0x73, 0x6f, 0x63, 0x69, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71,
+	0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x22, 0x59, 0x0a, 0x0f, 0x6e, 0x65,
+	0x77, 0x41, 0x73, 0x73, 0x6f, 0x63, 0x69, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x12, 0x12, 0x0a,
+	0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64,
+	0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6d, 0x73, 0x67, 0x12, 0x20, 0x0a, 0x0b, 0x61, 0x73, 0x73, 0x6f, 0x63, 0x69, 0x61, 0x74, 0x65,
+	0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x61, 0x73, 0x73, 0x6f, 0x63, 0x69,
+	0x61, 0x74, 0x65, 0x49, 0x64, 0x22, 0x47, 0x0a, 0x0d, 0x62, 0x69, 0x6e, 0x64, 0x41, 0x63, 0x63,
+	0x65, 0x70, 0x74, 0x52, 0x65, 0x71, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65,
+	0x6c, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x63, 0x68, 0x61, 0x6e, 0x6e,
+	0x65, 0x6c, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63, 0x65, 0x49, 0x64, 0x22, 0x51,
+	0x0a, 0x0d, 0x62, 0x69, 0x6e, 0x64, 0x41, 0x63, 0x63, 0x65, 0x70, 0x74, 0x52, 0x65, 0x73, 0x12,
+	0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63,
+	0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6d, 0x73, 0x67, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x65, 0x65, 0x72, 0x41, 0x64, 0x64,
+	0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x65, 0x65, 0x72, 0x41, 0x64, 0x64,
+	0x72, 0x22, 0x40, 0x0a, 0x10, 0x72, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x4c, 0x69, 0x73, 0x74,
+	0x65, 0x6e, 0x52, 0x65, 0x71, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x64, 0x64, 0x72, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x64, 0x64, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61,
+	0x63, 0x65, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63,
+	0x65, 0x49, 0x64, 0x22, 0x74, 0x0a, 0x10, 0x72, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x4c, 0x69,
+	0x73, 0x74, 0x65, 0x6e, 0x52, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d,
+	0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x12, 0x1e, 0x0a,
+	0x0a, 0x6c, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x65, 0x72, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x0a, 0x6c, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x62, 0x69, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x62, 0x69, 0x6e, 0x64, 0x41, 0x64, 0x64, 0x72, 0x22, 0x87, 0x01, 0x0a, 0x11, 0x72, 0x65,
+	0x76, 0x65, 0x72, 0x73, 0x65, 0x43, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x52, 0x65, 0x71, 0x12,
+	0x1e, 0x0a, 0x0a, 0x6c, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x65, 0x72, 0x49, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0a, 0x6c, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x65, 0x72, 0x49, 0x64, 0x12,
+	0x1c, 0x0a, 0x09, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0d, 0x52, 0x09, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x49, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x70, 0x65, 0x65, 0x72, 0x41, 0x64, 0x64, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x70, 0x65, 0x65, 0x72, 0x41, 0x64, 0x64, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x72, 0x61,
+	0x63, 0x65, 0x49, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x74, 0x72, 0x61, 0x63,
+	0x65, 0x49, 0x64, 0x42, 0x07, 0x5a, 0x05, 0x2e, 0x2f, 0x6d, 0x73, 0x67, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -361,13 +834,20 @@ func file_rpc_msg_proto_rawDescGZIP() []byte {
 	return file_rpc_msg_proto_rawDescData
 }
 
-var file_rpc_msg_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_rpc_msg_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_rpc_msg_proto_goTypes = []interface{}{
-	(*RpcMsg)(nil),        // 0: msg.RpcMsg
-	(*LoginReq)(nil),      // 1: msg.LoginReq
-	(*CommonRes)(nil),     // 2: msg.CommonRes
-	(*NewChannelReq)(nil), // 3: msg.newChannelReq
-	(*NewChannelRes)(nil), // 4: msg.newChannelRes
+	(*RpcMsg)(nil),            // 0: msg.RpcMsg
+	(*LoginReq)(nil),          // 1: msg.LoginReq
+	(*CommonRes)(nil),         // 2: msg.CommonRes
+	(*NewChannelReq)(nil),     // 3: msg.newChannelReq
+	(*NewChannelRes)(nil),     // 4: msg.newChannelRes
+	(*NewAssociateReq)(nil),   // 5: msg.newAssociateReq
+	(*NewAssociateRes)(nil),   // 6: msg.newAssociateRes
+	(*BindAcceptReq)(nil),     // 7: msg.bindAcceptReq
+	(*BindAcceptRes)(nil),     // 8: msg.bindAcceptRes
+	(*ReverseListenReq)(nil),  // 9: msg.reverseListenReq
+	(*ReverseListenRes)(nil),  // 10: msg.reverseListenRes
+	(*ReverseChannelReq)(nil), // 11: msg.reverseChannelReq
 }
 var file_rpc_msg_proto_depIdxs = []int32{
 	0, // [0:0] is the sub-list for method output_type
@@ -443,6 +923,90 @@ func file_rpc_msg_proto_init() {
 				return nil
 			}
 		}
+		file_rpc_msg_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NewAssociateReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_msg_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NewAssociateRes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_msg_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BindAcceptReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_msg_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BindAcceptRes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_msg_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReverseListenReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_msg_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReverseListenRes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_rpc_msg_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReverseChannelReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -450,7 +1014,7 @@ func file_rpc_msg_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_rpc_msg_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
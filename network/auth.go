@@ -0,0 +1,47 @@
+package network
+
+// Authenticator 校验客户端登录的用户名/密码
+type Authenticator interface {
+	Authenticate(name string, pwd string) bool
+}
+
+func (c *Connection) SetAuthenticator(authenticator Authenticator) {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+
+	c.authenticator = authenticator
+}
+
+// Authenticated 连接是否已登录成功
+func (c *Connection) Authenticated() bool {
+	c.authMutex.RLock()
+	defer c.authMutex.RUnlock()
+
+	return c.user != ""
+}
+
+// User 登录成功的用户名
+func (c *Connection) User() string {
+	c.authMutex.RLock()
+	defer c.authMutex.RUnlock()
+
+	return c.user
+}
+
+// authenticate 校验用户名/密码，成功后记录用户名；未设置 Authenticator 时拒绝所有登录。
+// 校验可能较慢（bcrypt），期间不持有锁，避免阻塞 Authenticated、User 等读取
+func (c *Connection) authenticate(name string, pwd string) bool {
+	c.authMutex.RLock()
+	authenticator := c.authenticator
+	c.authMutex.RUnlock()
+
+	if authenticator == nil || name == "" || !authenticator.Authenticate(name, pwd) {
+		return false
+	}
+
+	c.authMutex.Lock()
+	c.user = name
+	c.authMutex.Unlock()
+
+	return true
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type staticAuthenticator map[string]string
+
+func (a staticAuthenticator) Authenticate(name string, pwd string) bool {
+	password, ok := a[name]
+	return ok && password == pwd
+}
+
+// blockingAuthenticator 在 release 关闭前阻塞校验，模拟较慢的 bcrypt
+type blockingAuthenticator struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (a *blockingAuthenticator) Authenticate(name string, pwd string) bool {
+	close(a.started)
+	<-a.release
+	return true
+}
+
+func TestConnectionAuthenticate(t *testing.T) {
+	authenticator := staticAuthenticator{"allen": "secret"}
+
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		user          string
+		pwd           string
+		want          bool
+	}{
+		{"valid", authenticator, "allen", "secret", true},
+		{"wrong password", authenticator, "allen", "wrong", false},
+		{"unknown user", authenticator, "bob", "secret", false},
+		{"empty name", staticAuthenticator{"": ""}, "", "", false},
+		{"no authenticator", nil, "allen", "secret", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, _ := connectionPair(t)
+			if tt.authenticator != nil {
+				conn.SetAuthenticator(tt.authenticator)
+			}
+
+			if got := conn.authenticate(tt.user, tt.pwd); got != tt.want {
+				t.Fatalf("authenticate() = %v, want %v", got, tt.want)
+			}
+			if conn.Authenticated() != tt.want {
+				t.Errorf("Authenticated() = %v, want %v", conn.Authenticated(), tt.want)
+			}
+
+			wantUser := ""
+			if tt.want {
+				wantUser = tt.user
+			}
+			if got := conn.User(); got != wantUser {
+				t.Errorf("User() = %q, want %q", got, wantUser)
+			}
+		})
+	}
+}
+
+// 校验密码期间不持有锁，Authenticated、User、SetAuthenticator 不被阻塞
+func TestConnectionAuthenticateUnlocked(t *testing.T) {
+	conn, _ := connectionPair(t)
+
+	authenticator := &blockingAuthenticator{started: make(chan struct{}), release: make(chan struct{})}
+	conn.SetAuthenticator(authenticator)
+
+	result := make(chan bool, 1)
+	go func() { result <- conn.authenticate("allen", "secret") }()
+
+	<-authenticator.started
+
+	within(t, time.Second, "read during authenticate", func() error {
+		if conn.Authenticated() || conn.User() != "" {
+			return errors.New("authenticated before the password was checked")
+		}
+		conn.SetAuthenticator(authenticator)
+		return nil
+	})
+
+	close(authenticator.release)
+
+	if !<-result {
+		t.Fatal("authenticate() = false")
+	}
+	if got := conn.User(); got != "allen" {
+		t.Errorf("User() = %q, want \"allen\"", got)
+	}
+}
@@ -4,26 +4,52 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 
-	"log"
+	"github.com/ssp/util"
 )
 
 type channelFlag uint8
 
+var ErrChannelClosed = errors.New("Channel Close.")
+
 const (
 	channelOpenFlag  channelFlag = 1
 	channelCloseFlag channelFlag = 2
 )
 
+const (
+	// 通道初始窗口大小，对端最多发送该数量的未确认数据
+	InitialWindowSize = 256 * 1024
+
+	// 单个数据消息的最大长度
+	maxFlowFrameSize = 32 * 1024
+
+	// 发送窗口的上限
+	maxWindowSize = 1<<31 - 1
+)
+
 type Channel struct {
 	sync.Mutex
 
 	// 通道id
 	Id uint32
 
-	// 读缓存
-	ReadBuff chan []byte
+	// 读缓存，对端发送的数据不超过接收窗口，写入时不会阻塞连接的读协程
+	readBuff [][]byte
+
+	// 读缓存中的字节数
+	readBuffSize int
+
+	// 已读取但未通知对端的字节数
+	consumed int
+
+	// 发送窗口，对端允许继续发送的字节数
+	sendWindow int
+
+	// 读缓存、发送窗口或状态变化时通知等待的协程
+	cond *sync.Cond
 
 	// Connection
 	UnderlyingConn *Connection
@@ -31,14 +57,27 @@ type Channel struct {
 	// 状态
 	flag channelFlag
 
+	// 对端已结束发送
+	readClosed bool
+
+	// 本端已结束发送
+	writeClosed bool
+
 	// traceId
 	TraceId string
+
+	// 服务端连接目标地址时绑定的本地地址，以 OPEN 打开的通道为空
+	BindAddr string
+
+	// 对端拒绝打开通道的原因，关闭后由读写返回
+	rejectErr error
 }
 
 func NewChannel(id uint32, conn *Connection) *Channel {
 	channel := new(Channel)
 
-	channel.ReadBuff = make(chan []byte, 1024)
+	channel.cond = sync.NewCond(&channel.Mutex)
+	channel.sendWindow = InitialWindowSize
 	channel.UnderlyingConn = conn
 	channel.Id = id
 	channel.flag = channelOpenFlag
@@ -46,54 +85,256 @@ func NewChannel(id uint32, conn *Connection) *Channel {
 	return channel
 }
 
+// Write 按发送窗口分段发送数据，窗口用完时阻塞当前协程，直到对端更新窗口或通道关闭
 func (c *Channel) Write(p []byte) (n int, err error) {
-	wrLen := len(p)
+	for len(p) > 0 {
+		c.Lock()
+
+		for c.sendWindow <= 0 && c.flag != channelCloseFlag && !c.writeClosed {
+			c.cond.Wait()
+		}
 
-	flowMsg := BuildMsgOfFlow(p, c.Id)
-	SendMessge(context.TODO(), c.UnderlyingConn, flowMsg)
+		if c.flag == channelCloseFlag {
+			err := c.closedErr()
+			c.Unlock()
+			return n, err
+		}
 
-	return wrLen, nil
+		if c.writeClosed {
+			c.Unlock()
+			return n, errors.New("Channel write closed.")
+		}
+
+		size := len(p)
+		if size > c.sendWindow {
+			size = c.sendWindow
+		}
+		if size > maxFlowFrameSize {
+			size = maxFlowFrameSize
+		}
+
+		c.sendWindow -= size
+
+		c.Unlock()
+
+		flowMsg := BuildMsgOfFlow(p[:size], c.Id)
+		SendMessge(context.TODO(), c.UnderlyingConn, flowMsg)
+
+		p = p[size:]
+		n += size
+	}
+
+	return n, nil
 }
 
+// Read 读取数据，读取量累计达到半个窗口时通知对端更新发送窗口
 func (c *Channel) Read(p []byte) (n int, err error) {
+	c.Lock()
 
-	for data := range c.ReadBuff {
-		cLen := copy(p, data)
-		return cLen, nil
+	for len(c.readBuff) == 0 && c.flag != channelCloseFlag && !c.readClosed {
+		c.cond.Wait()
 	}
 
-	return -1, errors.New("Channel Close.")
-}
+	// 关闭后仍返回已缓存的数据，对端正常结束发送时返回 EOF
+	if len(c.readBuff) == 0 {
+		readClosed, err := c.readClosed, c.closedErr()
+		c.Unlock()
+		if readClosed {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
 
-func (c *Channel) Close() error {
+	n = copy(p, c.readBuff[0])
+	if n == len(c.readBuff[0]) {
+		c.readBuff[0] = nil
+		c.readBuff = c.readBuff[1:]
+	} else {
+		c.readBuff[0] = c.readBuff[0][n:]
+	}
+
+	c.readBuffSize -= n
+	c.consumed += n
+
+	increment := 0
+	if c.consumed >= InitialWindowSize/2 && c.flag != channelCloseFlag && !c.readClosed {
+		increment = c.consumed
+		c.consumed = 0
+	}
+
+	c.Unlock()
+
+	if increment > 0 {
+		windowMsg := BuildMsgOfWindowUpdate(c.Id, uint32(increment))
+		SendMessge(context.TODO(), c.UnderlyingConn, windowMsg)
+	}
+
+	return n, nil
+}
 
+// CloseWrite 结束本端发送，通知对端读取到 EOF，本端仍可继续读取
+func (c *Channel) CloseWrite() error {
 	c.Lock()
 
 	if c.flag == channelCloseFlag {
+		c.Unlock()
+		return ErrChannelClosed
+	}
+
+	if c.writeClosed {
+		c.Unlock()
 		return nil
 	}
 
-	c.flag = channelCloseFlag
+	c.writeClosed = true
+	c.cond.Broadcast()
 
 	c.Unlock()
 
-	close(c.ReadBuff)
+	finMsg := BuildMsgOfFin(c.Id)
+	SendMessge(context.TODO(), c.UnderlyingConn, finMsg)
 
-	c.UnderlyingConn.RemoveChannel(c.Id)
+	util.Infof("%s,Close write channel %s \n", c.TraceId, c.String())
 
-	log.Printf("%s,Close channel %s \n", c.TraceId, c.String())
+	return nil
+}
+
+// remoteCloseWrite 对端结束发送
+func (c *Channel) remoteCloseWrite() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.readClosed = true
+	c.cond.Broadcast()
+}
+
+// Close 关闭通道并通知对端关闭
+func (c *Channel) Close() error {
+
+	if !c.shutdown(false) {
+		return nil
+	}
+
+	c.UnderlyingConn.closeChannel(c.Id, CloseMsgCmd)
+
+	util.Infof("%s,Close channel %s \n", c.TraceId, c.String())
 
 	return nil
 }
 
-func (c *Channel) AppendReadBuff(data []byte) {
+// Reset 异常关闭通道，丢弃未读取的数据并通知对端重置
+func (c *Channel) Reset() error {
+
+	if !c.shutdown(true) {
+		return nil
+	}
+
+	c.UnderlyingConn.closeChannel(c.Id, RstMsgCmd)
+
+	util.Infof("%s,Reset channel %s \n", c.TraceId, c.String())
+
+	return nil
+}
+
+// shutdown 只关闭本端通道，返回是否由本次调用关闭
+func (c *Channel) shutdown(reset bool) bool {
 	c.Lock()
 	defer c.Unlock()
 
-	if c.flag != channelCloseFlag {
-		c.ReadBuff <- data
+	if c.flag == channelCloseFlag {
+		return false
 	}
 
+	c.flag = channelCloseFlag
+
+	if reset {
+		c.readBuff = nil
+		c.readBuffSize = 0
+	}
+
+	c.cond.Broadcast()
+
+	return true
+}
+
+// reject 拒绝对端以 OPEN 打开的通道，RST 中携带响应码
+func (c *Channel) reject(code int32) {
+
+	if !c.shutdown(true) {
+		return
+	}
+
+	c.UnderlyingConn.resetChannel(c.Id)
+
+	if !c.UnderlyingConn.Closed() {
+		SendMessge(context.TODO(), c.UnderlyingConn, BuildMsgOfChannelReject(c.Id, code))
+	}
+
+	util.Infof("%s,Reject channel %s,code:%d \n", c.TraceId, c.String(), code)
+}
+
+// rejected 对端拒绝打开通道，需在 shutdown 之前调用
+func (c *Channel) rejected(code int32) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.rejectErr = &RemoteError{Code: code, Msg: "channel open rejected"}
+}
+
+// closedErr 通道关闭后读写返回的错误，需持有锁
+func (c *Channel) closedErr() error {
+	if c.rejectErr != nil {
+		return c.rejectErr
+	}
+
+	return ErrChannelClosed
+}
+
+// AppendReadBuff 写入读缓存，不阻塞；对端发送的数据超过接收窗口时视为协议错误，丢弃数据并重置通道
+func (c *Channel) AppendReadBuff(data []byte) {
+	c.Lock()
+
+	if c.flag == channelCloseFlag {
+		c.Unlock()
+		return
+	}
+
+	// 已读取但未通知对端的数据仍占用对端的发送窗口
+	if c.readBuffSize+c.consumed+len(data) > InitialWindowSize {
+		c.Unlock()
+
+		util.Errorf("%s,Channel %d receive window exceeded:%d \n", c.TraceId, c.Id, c.readBuffSize+c.consumed+len(data))
+		c.Reset()
+
+		return
+	}
+
+	c.readBuff = append(c.readBuff, data)
+	c.readBuffSize += len(data)
+
+	c.cond.Broadcast()
+
+	c.Unlock()
+}
+
+// UpdateWindow 对端确认数据后增加发送窗口，窗口超过 maxWindowSize 时视为协议错误并重置通道
+func (c *Channel) UpdateWindow(increment uint32) {
+	c.Lock()
+
+	if int64(c.sendWindow)+int64(increment) > maxWindowSize {
+		c.Unlock()
+
+		util.Errorf("%s,Channel %d send window overflow:%d+%d \n", c.TraceId, c.Id, c.sendWindow, increment)
+		c.Reset()
+
+		return
+	}
+
+	c.sendWindow += int(increment)
+
+	c.cond.Broadcast()
+
+	c.Unlock()
 }
 
 func (c *Channel) String() string {
@@ -101,5 +342,8 @@ func (c *Channel) String() string {
 }
 
 func (c *Channel) Available() bool {
+	c.Lock()
+	defer c.Unlock()
+
 	return c.flag == channelOpenFlag
 }
@@ -0,0 +1,265 @@
+package network
+
+import (
+	"container/list"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// 支持的加密方式
+const (
+	Aes256GcmMethod        = "aes-256-gcm"
+	Chacha20Poly1305Method = "chacha20-poly1305"
+)
+
+const (
+	// 每个方向的会话盐长度
+	saltSize = 32
+
+	// 加密记录的长度字段
+	recordLenSize = 4
+
+	// 单个记录的最大长度，与 msg.Encode 帧长度一致
+	maxRecordSize = 16 * 1024 * 1024
+
+	// 记录已使用过的会话盐个数
+	saltFilterSize = 100000
+)
+
+var subkeyInfo = []byte("ssp-subkey")
+
+// Cipher 基于预共享密钥的 AEAD 加密参数，同一进程内的连接共用
+type Cipher struct {
+	method string
+
+	// 由预共享密钥派生的主密钥
+	key []byte
+
+	// 用于检测重放的会话盐集合
+	salts *saltFilter
+}
+
+func NewCipher(method string, psk string) (*Cipher, error) {
+	method = strings.ToLower(method)
+	if method != Aes256GcmMethod && method != Chacha20Poly1305Method {
+		return nil, errors.New("unsupported cipher method: " + method)
+	}
+
+	if psk == "" {
+		return nil, errors.New("cipher key must not be empty")
+	}
+
+	key := sha256.Sum256([]byte(psk))
+
+	return &Cipher{method: method, key: key[:], salts: newSaltFilter(saltFilterSize)}, nil
+}
+
+// Wrap 对连接加密，每次 Write 的数据作为一个记录加密
+func (c *Cipher) Wrap(conn net.Conn) net.Conn {
+	return &CipherConn{Conn: conn, cipher: c}
+}
+
+func (c *Cipher) newAead(salt []byte) (cipher.AEAD, error) {
+	subkey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, c.key, salt, subkeyInfo), subkey); err != nil {
+		return nil, err
+	}
+
+	if c.method == Chacha20Poly1305Method {
+		return chacha20poly1305.New(subkey)
+	}
+
+	block, err := aes.NewCipher(subkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// CipherConn 加密连接。每个方向先发送随机会话盐，之后每个记录格式为：
+// [加密的 4 字节长度][tag][加密的数据][tag]，nonce 为每个方向从 0 开始递增的计数器
+type CipherConn struct {
+	net.Conn
+
+	cipher *Cipher
+
+	writeMutex sync.Mutex
+	writeAead  cipher.AEAD
+	writeNonce []byte
+
+	readMutex sync.Mutex
+	readAead  cipher.AEAD
+	readNonce []byte
+
+	// 已解密未读取的数据
+	readBuff []byte
+}
+
+func (c *CipherConn) Write(p []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	var out []byte
+
+	if c.writeAead == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return 0, err
+		}
+
+		// 记录自己发送的会话盐，防止数据被反射回本端
+		c.cipher.salts.add(salt)
+
+		aead, err := c.cipher.newAead(salt)
+		if err != nil {
+			return 0, err
+		}
+
+		c.writeAead = aead
+		c.writeNonce = make([]byte, aead.NonceSize())
+		out = append(out, salt...)
+	}
+
+	overhead := c.writeAead.Overhead()
+	lenBuf := make([]byte, recordLenSize)
+	binary.BigEndian.PutUint32(lenBuf, uint32(len(p)))
+
+	out = append(make([]byte, 0, len(out)+recordLenSize+len(p)+2*overhead), out...)
+	out = c.writeAead.Seal(out, c.writeNonce, lenBuf, nil)
+	increment(c.writeNonce)
+	out = c.writeAead.Seal(out, c.writeNonce, p, nil)
+	increment(c.writeNonce)
+
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (c *CipherConn) Read(p []byte) (int, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	if len(c.readBuff) == 0 {
+		record, err := c.readRecord()
+		if err != nil {
+			return 0, err
+		}
+		c.readBuff = record
+	}
+
+	n := copy(p, c.readBuff)
+	c.readBuff = c.readBuff[n:]
+
+	return n, nil
+}
+
+// readRecord 读取并解密一个记录，校验失败时返回错误，由上层关闭连接
+func (c *CipherConn) readRecord() ([]byte, error) {
+	if c.readAead == nil {
+		salt := make([]byte, saltSize)
+		if _, err := io.ReadFull(c.Conn, salt); err != nil {
+			return nil, err
+		}
+
+		if !c.cipher.salts.add(salt) {
+			return nil, errors.New("cipher: replayed session salt")
+		}
+
+		aead, err := c.cipher.newAead(salt)
+		if err != nil {
+			return nil, err
+		}
+
+		c.readAead = aead
+		c.readNonce = make([]byte, aead.NonceSize())
+	}
+
+	overhead := c.readAead.Overhead()
+
+	lenBuf := make([]byte, recordLenSize+overhead)
+	if _, err := io.ReadFull(c.Conn, lenBuf); err != nil {
+		return nil, err
+	}
+
+	plainLen, err := c.readAead.Open(lenBuf[:0], c.readNonce, lenBuf, nil)
+	if err != nil {
+		return nil, errors.New("cipher: invalid record length: " + err.Error())
+	}
+	increment(c.readNonce)
+
+	size := binary.BigEndian.Uint32(plainLen)
+	if size > maxRecordSize {
+		return nil, errors.New("cipher: record too large")
+	}
+
+	record := make([]byte, int(size)+overhead)
+	if _, err := io.ReadFull(c.Conn, record); err != nil {
+		return nil, err
+	}
+
+	plain, err := c.readAead.Open(record[:0], c.readNonce, record, nil)
+	if err != nil {
+		return nil, errors.New("cipher: invalid record: " + err.Error())
+	}
+	increment(c.readNonce)
+
+	return plain, nil
+}
+
+// increment nonce 按小端递增
+func increment(nonce []byte) {
+	for i := range nonce {
+		nonce[i]++
+		if nonce[i] != 0 {
+			return
+		}
+	}
+}
+
+// saltFilter 记录最近使用过的会话盐，超过容量时淘汰最早的记录
+type saltFilter struct {
+	sync.Mutex
+
+	capacity int
+	salts    map[string]*list.Element
+	order    *list.List
+}
+
+func newSaltFilter(capacity int) *saltFilter {
+	return &saltFilter{capacity: capacity, salts: map[string]*list.Element{}, order: list.New()}
+}
+
+// add 记录会话盐，已存在时返回 false
+func (f *saltFilter) add(salt []byte) bool {
+	f.Lock()
+	defer f.Unlock()
+
+	key := string(salt)
+	if _, ok := f.salts[key]; ok {
+		return false
+	}
+
+	f.salts[key] = f.order.PushBack(key)
+
+	if f.order.Len() > f.capacity {
+		oldest := f.order.Front()
+		f.order.Remove(oldest)
+		delete(f.salts, oldest.Value.(string))
+	}
+
+	return true
+}
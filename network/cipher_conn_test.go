@@ -0,0 +1,171 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+// bufConn 写入记录到 written，读取来自 r
+type bufConn struct {
+	net.Conn
+	r       io.Reader
+	written bytes.Buffer
+}
+
+func (c *bufConn) Read(p []byte) (int, error)  { return c.r.Read(p) }
+func (c *bufConn) Write(p []byte) (int, error) { return c.written.Write(p) }
+
+func mustCipher(t *testing.T, method string, psk string) *Cipher {
+	t.Helper()
+
+	c, err := NewCipher(method, psk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// seal 用 c 加密 records，返回发送的数据及每个记录结束的位置
+func seal(t *testing.T, c *Cipher, records ...string) ([]byte, []int) {
+	t.Helper()
+
+	conn := &bufConn{}
+	wrapped := c.Wrap(conn)
+
+	var ends []int
+	for _, record := range records {
+		if _, err := wrapped.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+		ends = append(ends, conn.written.Len())
+	}
+
+	return conn.written.Bytes(), ends
+}
+
+// open 用 c 解密 data 中的所有记录
+func open(c *Cipher, data []byte) (string, error) {
+	wrapped := c.Wrap(&bufConn{r: bytes.NewReader(data)})
+	out, err := io.ReadAll(wrapped)
+	return string(out), err
+}
+
+func TestCipherConnRoundTrip(t *testing.T) {
+	for _, method := range []string{Aes256GcmMethod, Chacha20Poly1305Method} {
+		t.Run(method, func(t *testing.T) {
+			data, _ := seal(t, mustCipher(t, method, "secret"), "hello", "", "world")
+
+			got, err := open(mustCipher(t, method, "secret"), data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "helloworld" {
+				t.Errorf("got %q, want %q", got, "helloworld")
+			}
+		})
+	}
+}
+
+func TestCipherConnRejectsTampered(t *testing.T) {
+	data, ends := seal(t, mustCipher(t, Chacha20Poly1305Method, "secret"), "first record", "second record")
+
+	flip := func(i int) []byte {
+		tampered := append([]byte(nil), data...)
+		tampered[i] ^= 0x01
+		return tampered
+	}
+
+	// 交换两个记录的顺序
+	swapped := append([]byte(nil), data[:saltSize]...)
+	swapped = append(swapped, data[ends[0]:ends[1]]...)
+	swapped = append(swapped, data[saltSize:ends[0]]...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		cipher *Cipher
+	}{
+		{"salt", flip(0), mustCipher(t, Chacha20Poly1305Method, "secret")},
+		{"length", flip(saltSize), mustCipher(t, Chacha20Poly1305Method, "secret")},
+		{"length tag", flip(saltSize + recordLenSize), mustCipher(t, Chacha20Poly1305Method, "secret")},
+		{"payload", flip(ends[0] - 20), mustCipher(t, Chacha20Poly1305Method, "secret")},
+		{"second record", flip(ends[1] - 1), mustCipher(t, Chacha20Poly1305Method, "secret")},
+		{"reordered records", swapped, mustCipher(t, Chacha20Poly1305Method, "secret")},
+		{"truncated", data[:ends[1]-1], mustCipher(t, Chacha20Poly1305Method, "secret")},
+		{"wrong key", data, mustCipher(t, Chacha20Poly1305Method, "other")},
+		{"wrong method", data, mustCipher(t, Aes256GcmMethod, "secret")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := open(tt.cipher, tt.data); err == nil {
+				t.Error("tampered data accepted")
+			}
+		})
+	}
+}
+
+func TestCipherConnRejectsReplay(t *testing.T) {
+	sender := mustCipher(t, Aes256GcmMethod, "secret")
+	receiver := mustCipher(t, Aes256GcmMethod, "secret")
+
+	data, _ := seal(t, sender, "login")
+
+	if _, err := open(receiver, data); err != nil {
+		t.Fatalf("first session rejected: %v", err)
+	}
+
+	if _, err := open(receiver, data); err == nil {
+		t.Error("replayed session accepted")
+	}
+
+	// 发送方不接受被反射回来的数据
+	if _, err := open(sender, data); err == nil {
+		t.Error("reflected session accepted")
+	}
+}
+
+func TestSaltFilter(t *testing.T) {
+	filter := newSaltFilter(2)
+
+	steps := []struct {
+		salt string
+		want bool
+	}{
+		{"a", true},
+		{"b", true},
+		{"a", false},
+		{"c", true},
+		// 超过容量后淘汰最早的 a
+		{"a", true},
+		{"c", false},
+	}
+
+	for i, step := range steps {
+		if got := filter.add([]byte(step.salt)); got != step.want {
+			t.Errorf("step %d: add(%q) = %v, want %v", i, step.salt, got, step.want)
+		}
+	}
+}
+
+func TestNewCipherInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		psk    string
+	}{
+		{"unknown method", "rc4-md5", "secret"},
+		{"empty key", Aes256GcmMethod, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCipher(tt.method, tt.psk); err == nil {
+				t.Error("NewCipher() succeeded")
+			}
+		})
+	}
+}
@@ -3,11 +3,12 @@ package network
 import (
 	"bufio"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -22,6 +23,20 @@ const (
 	PongMsgCmd MsgCmd = 6
 	RpcMsgCmd  MsgCmd = 11
 	FlowMsgCmd MsgCmd = 12
+
+	DatagramMsgCmd      MsgCmd = 13
+	DatagramCloseMsgCmd MsgCmd = 14
+
+	WindowUpdateMsgCmd MsgCmd = 15
+	FinMsgCmd          MsgCmd = 16
+
+	CloseMsgCmd MsgCmd = 17
+	RstMsgCmd   MsgCmd = 18
+
+	GoAwayMsgCmd MsgCmd = 19
+
+	// 以发起方分配的 id 打开通道，数据紧随其后发送，无需等待响应
+	OpenMsgCmd MsgCmd = 20
 )
 
 type connectonFlag uint8
@@ -31,11 +46,56 @@ const (
 	connectionCloseFlag connectonFlag = 2
 )
 
+// 本端发送 RST 后忽略该通道迟到消息的时间，RST 不需要确认，超过该时间后不再记录
+const resetLinger = 30 * time.Second
+
+// ConnectionConfig 连接参数
+type ConnectionConfig struct {
+	// 心跳间隔
+	HeartbeatInterval time.Duration
+
+	// 心跳超时时间，超过该时间未收到心跳则关闭连接
+	HeartbeatTimeout time.Duration
+
+	// UDP 关联空闲超时时间
+	UdpIdleTimeout time.Duration
+
+	// BIND 等待对端连接的超时时间
+	BindAcceptTimeout time.Duration
+
+	// 服务端连接目标的超时时间，应小于客户端的 RPC 超时时间，使客户端收到超时的原因
+	DialTimeout time.Duration
+
+	// 写缓存大小（消息数）
+	WriteBuffSize int
+
+	// UDP 关联读缓存大小（数据包数），通道读缓存由流控窗口限制
+	ReadBuffSize int
+
+	// 是否允许客户端请求服务端监听端口（远程端口转发）
+	AllowReverse bool
+}
+
+func DefaultConnectionConfig() ConnectionConfig {
+	return ConnectionConfig{
+		HeartbeatInterval: 5 * time.Second,
+		HeartbeatTimeout:  15 * time.Second,
+		UdpIdleTimeout:    60 * time.Second,
+		BindAcceptTimeout: 2 * time.Minute,
+		DialTimeout:       4 * time.Second,
+		WriteBuffSize:     1024,
+		ReadBuffSize:      1024,
+	}
+}
+
 type Connection struct {
 	// 底层网络连接
 	conn net.Conn
 
-	// Channel ID 生成器
+	// 连接参数
+	config ConnectionConfig
+
+	// Channel ID 生成器，本端申请的通道与 UDP 关联使用偶数 id，以 OPEN 打开的通道使用奇数 id
 	channelIdGenerator *util.Id
 
 	// Rpc ID 生成器
@@ -44,18 +104,57 @@ type Connection struct {
 	// 写缓存
 	writerBuff chan []byte
 
+	// 连接关闭时关闭，通知读写协程退出
+	closed chan struct{}
+
 	// 通道集合
 	channels map[uint32]*Channel
 
+	// UDP 关联集合
+	datagrams map[uint32]*Datagram
+
+	// BIND 监听集合，key 为通道 id
+	binds map[uint32]*BindListener
+
+	// 远程端口转发监听集合
+	reverses map[uint32]net.Listener
+
+	// 远程端口转发监听 ID 生成器
+	reverseIdGenerator *util.Id
+
 	// 响应处理集合
 	promises map[uint32]*RpcPromise
 
 	// 连接状态
 	flag connectonFlag
 
+	// 登录校验
+	authenticator Authenticator
+
+	// 登录成功的用户名
+	user string
+
+	// 目标访问控制
+	policy AccessPolicy
+
+	// 远程端口转发监听地址的访问控制
+	listenPolicy AccessPolicy
+
+	// 目标域名解析
+	resolver Resolver
+
 	// 读写锁，控制对 channels 字段的并发读写
 	chMutex sync.RWMutex
 
+	// 读写锁，控制对 datagrams 字段的并发读写
+	dgMutex sync.RWMutex
+
+	// 互斥锁，控制对 binds、reverses 字段的并发读写
+	bindMutex sync.Mutex
+
+	// 读写锁，控制对 authenticator、user、policy、listenPolicy、resolver 字段的并发读写
+	authMutex sync.RWMutex
+
 	// 读写锁，控制对 flag 字段的并发读写
 	flagMutex sync.RWMutex
 
@@ -68,28 +167,56 @@ type Connection struct {
 	// 最近一次心跳时间
 	lastBeatTime time.Time
 
-	// 远端待删除的 channel 列表
-	remomtePendingClose chan uint32
+	// 最近一次发送 ping 的时间（UnixNano）
+	pingTime atomic.Int64
+
+	// 最近一次 ping/pong 往返时间（纳秒）
+	rtt atomic.Int64
+
+	// 本端已发送 GOAWAY，不再接受新的通道请求
+	goAway atomic.Bool
+
+	// 对端已发送 GOAWAY，不再向对端请求新的通道
+	remoteGoAway atomic.Bool
+
+	// 本端已发送 CLOSE、等待对端确认的通道 id，由 chMutex 保护
+	pendingClose map[uint32]struct{}
 
-	// 待删除的 channel 列表
-	pendingClose chan uint32
+	// 本端已发送 RST 的通道 id 及发送时间，由 chMutex 保护
+	resetChannels map[uint32]time.Time
+
+	// 上次清理 resetChannels 的时间，由 chMutex 保护
+	resetPurged time.Time
+
+	// 处理对端请求的 RPC 命令，为空时拒绝所有请求
+	service *Service
 }
 
 func NewConnection(conn net.Conn) *Connection {
+	return NewConnectionWithConfig(conn, DefaultConnectionConfig())
+}
+
+func NewConnectionWithConfig(conn net.Conn, config ConnectionConfig) *Connection {
 
 	connection := new(Connection)
 	connection.conn = conn
+	connection.config = config
 	connection.channelIdGenerator = util.NewId(0)
 	connection.requestIdGenerator = util.NewId(0)
-	connection.writerBuff = make(chan []byte, 1024)
+	connection.reverseIdGenerator = util.NewId(0)
+	connection.writerBuff = make(chan []byte, config.WriteBuffSize)
+	connection.closed = make(chan struct{})
 
 	connection.channels = map[uint32]*Channel{}
+	connection.datagrams = map[uint32]*Datagram{}
+	connection.binds = map[uint32]*BindListener{}
+	connection.reverses = map[uint32]net.Listener{}
 	connection.promises = map[uint32]*RpcPromise{}
 
 	connection.flag = connectionOpenFlag
 
-	connection.remomtePendingClose = make(chan uint32, 100)
-	connection.pendingClose = make(chan uint32, 100)
+	connection.pendingClose = map[uint32]struct{}{}
+	connection.resetChannels = map[uint32]time.Time{}
 
 	connection.lastBeatTime = time.Now()
 
@@ -110,14 +237,14 @@ func (c *Connection) Read() {
 		if err == nil && len(data) > 0 {
 			err := proto.Unmarshal(data, m)
 			if err != nil {
-				log.Printf("Close connection:%s:%s \n", c.conn.RemoteAddr(), err.Error())
+				util.Errorf("Close connection:%s:%s \n", c.conn.RemoteAddr(), err.Error())
 				c.Close()
 
 				break
 			}
 		}
 		if err != nil {
-			log.Printf("Close connection:%s:%s \n", c.conn.RemoteAddr(), err.Error())
+			util.Errorf("Close connection:%s:%s \n", c.conn.RemoteAddr(), err.Error())
 			c.Close()
 
 			break
@@ -126,10 +253,27 @@ func (c *Connection) Read() {
 		cmd := MsgCmd(m.Cmd)
 		switch cmd {
 		case RpcMsgCmd:
-			go c.RpcProcess(ctx, m.Data)
+			c.RpcProcess(ctx, m.Data)
+		case OpenMsgCmd:
+			c.Open(ctx, m)
 		case FlowMsgCmd:
 			// 写入channel
 			c.Flow(m)
+		case WindowUpdateMsgCmd:
+			c.WindowUpdate(m)
+		case FinMsgCmd:
+			c.Fin(m)
+		case CloseMsgCmd:
+			c.ChannelClose(m)
+		case RstMsgCmd:
+			c.ChannelReset(m)
+		case GoAwayMsgCmd:
+			util.Infof("Receive go away from:%s \n", c.conn.RemoteAddr())
+			c.remoteGoAway.Store(true)
+		case DatagramMsgCmd:
+			c.Datagram(m)
+		case DatagramCloseMsgCmd:
+			c.DatagramClose(m)
 		case PingMsgCmd:
 			c.doPing()
 		case PongMsgCmd:
@@ -142,46 +286,92 @@ func (c *Connection) Read() {
 }
 
 func (c *Connection) Close() {
-	log.Printf("Start close connection .....")
+	util.Infof("Start close connection .....")
 
 	c.flagMutex.Lock()
 
 	if c.flag == connectionCloseFlag {
+		c.flagMutex.Unlock()
 		return
 	}
 
 	c.flag = connectionCloseFlag
 
 	c.flagMutex.Unlock()
-	// 关闭 写缓存
-	close(c.writerBuff)
+	// 通知写协程退出
+	close(c.closed)
+
+	// 等待中的请求立即失败
+	c.failPromises(ErrConnectionClosed)
 
 	// 关闭通道
-	for id, ch := range c.channels {
+	c.chMutex.RLock()
+	channels := make([]*Channel, 0, len(c.channels))
+	for _, ch := range c.channels {
+		channels = append(channels, ch)
+	}
+	c.chMutex.RUnlock()
+
+	for _, ch := range channels {
 
-		log.Printf("Close channel: %d \n", id)
+		util.Infof("Close channel: %d \n", ch.Id)
 		ch.Close()
 	}
 
-	c.channels = nil
-	c.promises = nil
+	// 关闭 UDP 关联
+	c.dgMutex.RLock()
+	datagrams := make([]*Datagram, 0, len(c.datagrams))
+	for _, datagram := range c.datagrams {
+		datagrams = append(datagrams, datagram)
+	}
+	c.dgMutex.RUnlock()
+
+	for _, datagram := range datagrams {
+		util.Infof("Close datagram: %d \n", datagram.Id)
+		datagram.shutdown()
+	}
+
+	// 关闭 BIND 监听
+	c.bindMutex.Lock()
+	for id, listener := range c.binds {
+		util.Infof("Close bind listener: %d \n", id)
+		listener.Close()
+	}
+	c.binds = map[uint32]*BindListener{}
+
+	for id, listener := range c.reverses {
+		util.Infof("Close reverse listener: %d \n", id)
+		listener.Close()
+	}
+	c.reverses = map[uint32]net.Listener{}
+	c.bindMutex.Unlock()
 
 	c.conn.Close()
 
-	log.Printf("End close connection.....")
+	util.Infof("End close connection.....")
 }
 
 func (c *Connection) Write() {
 	defer util.Trace("", "Client Write")()
 
-	for data := range c.writerBuff {
-		c.conn.Write(data)
+	for {
+		select {
+		case data := <-c.writerBuff:
+			if _, err := c.conn.Write(data); err != nil {
+				util.Errorf("Close connection:%s:%s \n", c.conn.RemoteAddr(), err.Error())
+				c.Close()
+
+				return
+			}
+		case <-c.closed:
+			return
+		}
 	}
 
 }
 
 func (c *Connection) doPing() {
-	// log.Printf("Receive a ping:%s,%s.\n", c.conn.RemoteAddr(), time.Now())
+	// util.Infof("Receive a ping:%s,%s.\n", c.conn.RemoteAddr(), time.Now())
 	c.lastBeatTime = time.Now()
 
 	pongMsg := BuildMsgOfPong()
@@ -189,13 +379,17 @@ func (c *Connection) doPing() {
 }
 
 func (c *Connection) doPong() {
-	//log.Printf("Receive a pong:%s,%s.\n", c.conn.RemoteAddr(), time.Now())
+	//util.Infof("Receive a pong:%s,%s.\n", c.conn.RemoteAddr(), time.Now())
 	c.lastBeatTime = time.Now()
+
+	if pingTime := c.pingTime.Load(); pingTime > 0 {
+		c.rtt.Store(c.lastBeatTime.UnixNano() - pingTime)
+	}
 }
 
 func (c *Connection) PingPongAndTimeout() {
-	// 5S 触发一次判断
-	c.ticker = time.NewTicker(5 * time.Second)
+	// 每个心跳间隔触发一次判断
+	c.ticker = time.NewTicker(c.config.HeartbeatInterval)
 	defer c.ticker.Stop()
 
 	for {
@@ -205,14 +399,16 @@ func (c *Connection) PingPongAndTimeout() {
 			if c.doTimeout() {
 				return
 			}
+		case <-c.closed:
+			return
 		}
 	}
 
 }
 
 func (c *Connection) Timeout() {
-	// 5S 触发一次判断
-	c.ticker = time.NewTicker(5 * time.Second)
+	// 每个心跳间隔触发一次判断
+	c.ticker = time.NewTicker(c.config.HeartbeatInterval)
 	defer c.ticker.Stop()
 
 	for {
@@ -221,19 +417,23 @@ func (c *Connection) Timeout() {
 			if c.doTimeout() {
 				return
 			}
+		case <-c.closed:
+			return
 		}
 	}
 }
 
 func (c *Connection) ping() {
+	c.pingTime.Store(time.Now().UnixNano())
+
 	pingMsg := BuildMsgOfPing()
 	SendMessge(context.TODO(), c, pingMsg)
 }
 
 func (c *Connection) doTimeout() bool {
-	if c.lastBeatTime.Add(15 * time.Second).Before(time.Now()) {
+	if c.lastBeatTime.Add(c.config.HeartbeatTimeout).Before(time.Now()) {
 
-		log.Printf("Connection Timeout:%s,%s.\n", c.conn.RemoteAddr(), time.Now())
+		util.Errorf("Connection Timeout:%s,%s.\n", c.conn.RemoteAddr(), time.Now())
 
 		c.Close()
 
@@ -246,17 +446,17 @@ func (c *Connection) doTimeout() bool {
 
 func (c *Connection) WriteBytes(data []byte) error {
 
-	c.flagMutex.Lock()
-
-	if c.flag == connectionCloseFlag {
-		log.Printf("Cann't write data,because connection was closed!\n")
+	if c.Closed() {
+		util.Errorf("Cann't write data,because connection was closed!\n")
 
 		return errors.New("Connection was closed!")
 	}
 
-	c.flagMutex.Unlock()
-
-	c.writerBuff <- data
+	select {
+	case c.writerBuff <- data:
+	case <-c.closed:
+		return errors.New("Connection was closed!")
+	}
 
 	return nil
 }
@@ -264,7 +464,7 @@ func (c *Connection) WriteBytes(data []byte) error {
 func (c *Connection) ApplyChannel() *Channel {
 
 	// 申请一个唯一的通道 id
-	id := c.channelIdGenerator.IncrementAndGet()
+	id := c.channelIdGenerator.IncrementAndGet() * 2
 	channel := NewChannel(id, c)
 
 	c.chMutex.Lock()
@@ -277,6 +477,57 @@ func (c *Connection) ApplyChannel() *Channel {
 
 }
 
+// OpenChannel 以本端分配的奇数 id 打开到 addr 的通道，不等待对端连接目标即返回，
+// 之后写入的数据由对端缓存到连接成功；对端连接失败时以携带原因的 RST 拒绝
+func (c *Connection) OpenChannel(ctx context.Context, addr string) (*Channel, error) {
+	traceId, _ := ctx.Value("traceId").(string)
+
+	id := c.channelIdGenerator.IncrementAndGet()*2 - 1
+	channel := NewChannel(id, c)
+	channel.TraceId = traceId
+
+	c.RegChannel(id, channel)
+
+	openMsg := BuildMsgOfOpen(id, &msg.NewChannelReq{Addr: addr, TraceId: traceId})
+	if err := SendMessge(ctx, c, openMsg); err != nil {
+		c.RemoveChannel(id)
+		return nil, err
+	}
+
+	return channel, nil
+}
+
+// Open 对端以奇数 id 打开通道，在读协程中注册通道以缓存随后到达的数据，
+// 连接目标在单独的协程中进行；偶数 id 或已存在的 id 可能属于本端正在使用的通道，
+// 不能以 RST 拒绝，视为协议错误关闭连接
+func (c *Connection) Open(ctx context.Context, m *msg.Msg) {
+	if m.Id%2 == 0 {
+		util.Errorf("Close connection:%s:open a channel with even id %d \n", c.conn.RemoteAddr(), m.Id)
+		c.Close()
+		return
+	}
+
+	if _, exists := c.getChannel(m.Id); exists {
+		util.Errorf("Close connection:%s:open an existing channel %d \n", c.conn.RemoteAddr(), m.Id)
+		c.Close()
+		return
+	}
+
+	channelReq := &msg.NewChannelReq{}
+	if err := proto.Unmarshal(m.Data, channelReq); err != nil {
+		util.Errorf("Invalid open message:%d,%s \n", m.Id, c.conn.RemoteAddr())
+		SendMessge(ctx, c, BuildMsgOfChannelReject(m.Id, FailCode))
+		return
+	}
+
+	channel := NewChannel(m.Id, c)
+	channel.TraceId = channelReq.TraceId
+
+	c.RegChannel(m.Id, channel)
+
+	go acceptOpenChannel(ctx, c, channel, channelReq)
+}
+
 func (c *Connection) RegChannel(channelId uint32, channel *Channel) bool {
 
 	c.chMutex.Lock()
@@ -299,28 +550,365 @@ func (c *Connection) RemoveChannel(channelId uint32) bool {
 	return true
 }
 
-func (c *Connection) Flow(msg *msg.Msg) {
+// closeChannel 删除本端通道并通知对端，CLOSE 需要对端回复 CLOSE 确认
+func (c *Connection) closeChannel(channelId uint32, cmd MsgCmd) {
+
+	if cmd == CloseMsgCmd {
+		c.chMutex.Lock()
+		delete(c.channels, channelId)
+		c.pendingClose[channelId] = struct{}{}
+		c.chMutex.Unlock()
+	} else {
+		c.resetChannel(channelId)
+	}
+
+	if c.Closed() {
+		return
+	}
+
+	if cmd == CloseMsgCmd {
+		SendMessge(context.TODO(), c, BuildMsgOfChannelClose(channelId))
+	} else {
+		SendMessge(context.TODO(), c, BuildMsgOfChannelReset(channelId))
+	}
+}
 
-	id := msg.Id
+// takeChannel 收到对端 CLOSE/RST 时取出通道，pending 表示该通道由本端关闭、正在等待确认
+func (c *Connection) takeChannel(channelId uint32) (channel *Channel, ok bool, pending bool) {
+	c.chMutex.Lock()
+	defer c.chMutex.Unlock()
+
+	if _, pending = c.pendingClose[channelId]; pending {
+		delete(c.pendingClose, channelId)
+		return nil, false, true
+	}
+
+	channel, ok = c.channels[channelId]
+	if ok {
+		delete(c.channels, channelId)
+	}
+
+	return channel, ok, false
+}
+
+// resetChannel 删除本端通道并记录即将发送的 RST
+func (c *Connection) resetChannel(channelId uint32) {
+	c.chMutex.Lock()
+	defer c.chMutex.Unlock()
 
-	if channel, ok := c.channels[id]; ok {
+	delete(c.channels, channelId)
+	c.markReset(channelId)
+}
+
+// markReset 记录已发送 RST 的通道，需持有 chMutex；每隔 resetLinger 清理一次过期的记录
+func (c *Connection) markReset(channelId uint32) {
+	now := time.Now()
+
+	if now.Sub(c.resetPurged) >= resetLinger {
+		for id, resetAt := range c.resetChannels {
+			if now.Sub(resetAt) >= resetLinger {
+				delete(c.resetChannels, id)
+			}
+		}
+		c.resetPurged = now
+	}
+
+	c.resetChannels[channelId] = now
+}
+
+// unknownChannel 收到未知通道的消息时回复 RST；本端刚关闭或已重置的通道直接忽略，
+// 避免对端在收到 RST 之前发送的每条消息都触发一次 RST
+func (c *Connection) unknownChannel(channelId uint32) {
+	c.chMutex.Lock()
+
+	_, pending := c.pendingClose[channelId]
+	resetAt, reset := c.resetChannels[channelId]
+
+	ignore := pending || (reset && time.Since(resetAt) < resetLinger)
+	if !ignore {
+		c.markReset(channelId)
+	}
+
+	c.chMutex.Unlock()
+
+	if ignore {
+		return
+	}
+
+	util.Debugf("Reset unknown channel:%d \n", channelId)
+
+	SendMessge(context.TODO(), c, BuildMsgOfChannelReset(channelId))
+}
+
+// ChannelCount 当前打开的通道数
+func (c *Connection) ChannelCount() int {
+	c.chMutex.RLock()
+	defer c.chMutex.RUnlock()
+
+	return len(c.channels)
+}
+
+// RTT 最近一次心跳的往返时间，尚未收到 pong 时为 0
+func (c *Connection) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
+
+func (c *Connection) getChannel(channelId uint32) (*Channel, bool) {
+	c.chMutex.RLock()
+	defer c.chMutex.RUnlock()
+
+	channel, ok := c.channels[channelId]
+
+	return channel, ok
+}
+
+func (c *Connection) Flow(msg *msg.Msg) {
+
+	if channel, ok := c.getChannel(msg.Id); ok {
 		channel.AppendReadBuff(msg.Data)
+	} else {
+		c.unknownChannel(msg.Id)
+	}
+}
+
+func (c *Connection) Fin(msg *msg.Msg) {
+
+	if channel, ok := c.getChannel(msg.Id); ok {
+		channel.remoteCloseWrite()
+	} else {
+		c.unknownChannel(msg.Id)
+	}
+}
+
+// ChannelClose 对端关闭通道，本端关闭后回复 CLOSE 确认
+func (c *Connection) ChannelClose(msg *msg.Msg) {
+
+	channel, ok, pending := c.takeChannel(msg.Id)
+	if pending {
+		return
+	}
+
+	if !ok {
+		c.unknownChannel(msg.Id)
+		return
+	}
+
+	if channel.shutdown(false) {
+		util.Infof("%s,Remote close channel %s \n", channel.TraceId, channel.String())
+	}
+
+	SendMessge(context.TODO(), c, BuildMsgOfChannelClose(msg.Id))
+}
+
+// ChannelReset 对端重置通道，不回复；RST 携带响应码时为对端拒绝打开通道
+func (c *Connection) ChannelReset(msg *msg.Msg) {
+
+	channel, ok, _ := c.takeChannel(msg.Id)
+	if !ok {
+		return
+	}
+
+	if len(msg.Data) == 4 {
+		channel.rejected(int32(binary.BigEndian.Uint32(msg.Data)))
+	}
+
+	if channel.shutdown(true) {
+		util.Infof("%s,Remote reset channel %s \n", channel.TraceId, channel.String())
+	}
+}
+
+func (c *Connection) WindowUpdate(msg *msg.Msg) {
+
+	if len(msg.Data) != 4 {
+		util.Errorf("Invalid window update message:%d \n", msg.Id)
+		return
+	}
+
+	if channel, ok := c.getChannel(msg.Id); ok {
+		channel.UpdateWindow(binary.BigEndian.Uint32(msg.Data))
+	} else {
+		c.unknownChannel(msg.Id)
+	}
+}
+
+func (c *Connection) ApplyDatagram() *Datagram {
+
+	// 与通道共用 id 生成器
+	id := c.channelIdGenerator.IncrementAndGet() * 2
+	datagram := NewDatagram(id, c)
+
+	c.dgMutex.Lock()
+
+	c.datagrams[id] = datagram
+
+	c.dgMutex.Unlock()
+
+	return datagram
+}
+
+func (c *Connection) RegDatagram(datagramId uint32, datagram *Datagram) bool {
+
+	c.dgMutex.Lock()
+
+	c.datagrams[datagramId] = datagram
+
+	c.dgMutex.Unlock()
+
+	return true
+}
+
+func (c *Connection) RemoveDatagram(datagramId uint32) bool {
+
+	c.dgMutex.Lock()
+
+	delete(c.datagrams, datagramId)
+
+	c.dgMutex.Unlock()
+
+	return true
+}
+
+func (c *Connection) getDatagram(datagramId uint32) (*Datagram, bool) {
+	c.dgMutex.RLock()
+	defer c.dgMutex.RUnlock()
+
+	datagram, ok := c.datagrams[datagramId]
+
+	return datagram, ok
+}
+
+func (c *Connection) Datagram(msg *msg.Msg) {
+
+	if datagram, ok := c.getDatagram(msg.Id); ok {
+		datagram.AppendReadBuff(msg.Data)
+	}
+}
+
+func (c *Connection) DatagramClose(msg *msg.Msg) {
+
+	if datagram, ok := c.getDatagram(msg.Id); ok {
+		datagram.shutdown()
 	}
 }
 
+// BindListener BIND 监听及请求中期望的对端地址
+type BindListener struct {
+	net.Listener
+
+	// 期望的对端 IP，为空时接受任意对端
+	Peers []net.IP
+}
+
+// Allowed 连接是否来自期望的对端
+func (l *BindListener) Allowed(addr net.Addr) bool {
+	if len(l.Peers) == 0 {
+		return true
+	}
+
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, ip := range l.Peers {
+		if ip.Equal(tcpAddr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Connection) RegBind(channelId uint32, listener *BindListener) bool {
+
+	c.bindMutex.Lock()
+
+	c.binds[channelId] = listener
+
+	c.bindMutex.Unlock()
+
+	return true
+}
+
+// TakeBind 取出并移除通道对应的 BIND 监听
+func (c *Connection) TakeBind(channelId uint32) (*BindListener, bool) {
+
+	c.bindMutex.Lock()
+	defer c.bindMutex.Unlock()
+
+	listener, ok := c.binds[channelId]
+	delete(c.binds, channelId)
+
+	return listener, ok
+}
+
+// RegReverse 登记远程端口转发监听并分配 id，连接关闭后由 Close 关闭监听；连接已关闭时返回 false
+func (c *Connection) RegReverse(listener net.Listener) (uint32, bool) {
+
+	c.bindMutex.Lock()
+	defer c.bindMutex.Unlock()
+
+	if c.Closed() {
+		return 0, false
+	}
+
+	id := c.reverseIdGenerator.IncrementAndGet()
+	c.reverses[id] = listener
+
+	return id, true
+}
+
+// RegPromise 注册等待响应的请求，连接已关闭时返回 false
 func (c *Connection) RegPromise(requestId uint32, promise *RpcPromise) bool {
 
 	c.promiseMutex.Lock()
+	defer c.promiseMutex.Unlock()
+
+	if c.Closed() {
+		return false
+	}
+
 	c.promises[requestId] = promise
-	c.promiseMutex.Unlock()
 
 	return true
 }
 
+// removePromise 删除等待中的请求，已被取出处理时返回 false
+func (c *Connection) removePromise(requestId uint32) bool {
+
+	c.promiseMutex.Lock()
+	defer c.promiseMutex.Unlock()
+
+	_, ok := c.promises[requestId]
+	delete(c.promises, requestId)
+
+	return ok
+}
+
+// failPromises 连接关闭时结束所有等待中的请求
+func (c *Connection) failPromises(err error) {
+
+	c.promiseMutex.Lock()
+	promises := c.promises
+	c.promises = map[uint32]*RpcPromise{}
+	c.promiseMutex.Unlock()
+
+	for _, promise := range promises {
+		promise.fail(err)
+	}
+}
+
+// PromiseProcess 在读协程中处理响应，回调完成前不会读取后续消息
 func (c *Connection) PromiseProcess(result *msg.RpcMsg) {
 
 	requestId := result.Id
-	if promise, ok := c.promises[requestId]; ok {
+
+	c.promiseMutex.Lock()
+	promise, ok := c.promises[requestId]
+	delete(c.promises, requestId)
+	c.promiseMutex.Unlock()
+
+	if ok {
 		promise.Set(result)
 	}
 }
@@ -337,11 +925,7 @@ func (c *Connection) RpcProcess(ctx context.Context, message []byte) error {
 		}
 
 		if rpcMsg.Type == uint32(ReqType) {
-			cmd := RpcCmd(rpcMsg.Cmd)
-			if processor, ok := Processors[cmd]; ok {
-				rpcContext := NewContext(c)
-				processor(ctx, rpcContext, rpcMsg)
-			}
+			go c.service.serve(ctx, NewContext(c), rpcMsg)
 		} else {
 			c.PromiseProcess(rpcMsg)
 		}
@@ -351,6 +935,68 @@ func (c *Connection) RpcProcess(ctx context.Context, message []byte) error {
 	return nil
 }
 
+// SetService 设置处理对端请求的 RPC 命令，需在 Read 之前调用
+func (c *Connection) SetService(service *Service) {
+	c.service = service
+}
+
+func (c *Connection) Config() ConnectionConfig {
+	return c.config
+}
+
+// GoAway 通知对端不再发起新的通道，本端也拒绝新的通道请求
+func (c *Connection) GoAway() {
+	if c.goAway.CompareAndSwap(false, true) {
+		SendMessge(context.TODO(), c, BuildMsgOfGoAway())
+	}
+}
+
+// Draining 本端已发送 GOAWAY
+func (c *Connection) Draining() bool {
+	return c.goAway.Load()
+}
+
+// RemoteGoAway 对端已发送 GOAWAY
+func (c *Connection) RemoteGoAway() bool {
+	return c.remoteGoAway.Load()
+}
+
+// Drain 发送 GOAWAY 后等待通道与 UDP 关联结束，ctx 结束时不再等待，最后关闭连接
+func (c *Connection) Drain(ctx context.Context) error {
+	c.GoAway()
+	defer c.Close()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		c.dgMutex.RLock()
+		datagrams := len(c.datagrams)
+		c.dgMutex.RUnlock()
+
+		if c.ChannelCount() == 0 && datagrams == 0 {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-c.closed:
+			return nil
+		case <-ctx.Done():
+			util.Warnf("Drain connection %s timeout,channels:%d,datagrams:%d \n", c.conn.RemoteAddr(), c.ChannelCount(), datagrams)
+			return ctx.Err()
+		}
+	}
+}
+
+// Done 连接关闭时关闭返回的 channel
+func (c *Connection) Done() <-chan struct{} {
+	return c.closed
+}
+
 func (c *Connection) Closed() bool {
+	c.flagMutex.RLock()
+	defer c.flagMutex.RUnlock()
+
 	return c.flag == connectionCloseFlag
 }
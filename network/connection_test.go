@@ -0,0 +1,700 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/ssp/msg"
+)
+
+func TestBindListenerAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		peers []net.IP
+		addr  net.Addr
+		want  bool
+	}{
+		{"any peer", nil, &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4000}, true},
+		{"expected peer", []net.IP{net.ParseIP("203.0.113.7")}, &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4000}, true},
+		{"ipv4-mapped peer", []net.IP{net.ParseIP("203.0.113.7")}, &net.TCPAddr{IP: net.ParseIP("::ffff:203.0.113.7"), Port: 4000}, true},
+		{"one of resolved peers", []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("203.0.113.7")}, &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 4000}, true},
+		{"unexpected peer", []net.IP{net.ParseIP("203.0.113.7")}, &net.TCPAddr{IP: net.ParseIP("203.0.113.8"), Port: 4000}, false},
+		{"non tcp addr", []net.IP{net.ParseIP("203.0.113.7")}, &net.UDPAddr{IP: net.ParseIP("203.0.113.7"), Port: 4000}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := &BindListener{Peers: tt.peers}
+			if got := listener.Allowed(tt.addr); got != tt.want {
+				t.Errorf("Allowed(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+// connectionPair 通过 net.Pipe 连接的两个 Connection
+func connectionPair(t *testing.T) (*Connection, *Connection) {
+	t.Helper()
+
+	a, b := net.Pipe()
+	connA, connB := NewConnection(a), NewConnection(b)
+
+	for _, conn := range []*Connection{connA, connB} {
+		go conn.Read()
+		go conn.Write()
+	}
+
+	t.Cleanup(func() {
+		connA.Close()
+		connB.Close()
+	})
+
+	return connA, connB
+}
+
+// channelPair 在 local 申请通道，并在 remote 注册同一 id 的通道
+func channelPair(local *Connection, remote *Connection) (*Channel, *Channel) {
+	channel := local.ApplyChannel()
+
+	peer := NewChannel(channel.Id, remote)
+	remote.RegChannel(channel.Id, peer)
+
+	return channel, peer
+}
+
+// within 在 timeout 内执行 f
+func within(t *testing.T, timeout time.Duration, name string, f func() error) {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	case <-time.After(timeout):
+		t.Fatalf("%s: timeout after %s", name, timeout)
+	}
+}
+
+func TestStalledChannelDoesNotBlockSiblings(t *testing.T) {
+	connA, connB := connectionPair(t)
+
+	stalledA, stalledB := channelPair(connA, connB)
+	siblingA, siblingB := channelPair(connA, connB)
+
+	// 对端不读取 stalled，写满窗口后写入阻塞
+	var stalledWritten int64
+	stalledDone := make(chan error, 1)
+	go func() {
+		n, err := stalledA.Write(make([]byte, 2*InitialWindowSize))
+		stalledWritten = int64(n)
+		stalledDone <- err
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stalledB.Lock()
+		buffered := stalledB.readBuffSize
+		stalledB.Unlock()
+
+		if buffered == InitialWindowSize {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stalled channel buffered %d bytes, want %d", buffered, InitialWindowSize)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-stalledDone:
+		t.Fatalf("write to stalled channel returned early: %v", err)
+	default:
+	}
+
+	// 同一连接上的其它通道仍可双向收发，数据量超过一个窗口
+	payload := bytes.Repeat([]byte("sibling"), InitialWindowSize/3)
+
+	within(t, 2*time.Second, "sibling a->b", func() error {
+		go siblingA.Write(payload)
+
+		got := make([]byte, len(payload))
+		if _, err := io.ReadFull(siblingB, got); err != nil {
+			return err
+		}
+		if !bytes.Equal(got, payload) {
+			return errors.New("sibling data mismatch")
+		}
+		return nil
+	})
+
+	within(t, 2*time.Second, "sibling b->a", func() error {
+		go siblingB.Write([]byte("pong"))
+
+		got := make([]byte, 4)
+		if _, err := io.ReadFull(siblingA, got); err != nil {
+			return err
+		}
+		if string(got) != "pong" {
+			return errors.New("sibling data mismatch")
+		}
+		return nil
+	})
+
+	// 恢复读取后被阻塞的写入完成
+	within(t, 2*time.Second, "stalled channel resumes", func() error {
+		if _, err := io.ReadFull(stalledB, make([]byte, 2*InitialWindowSize)); err != nil {
+			return err
+		}
+		return <-stalledDone
+	})
+
+	if stalledWritten != 2*InitialWindowSize {
+		t.Errorf("stalled channel wrote %d bytes, want %d", stalledWritten, 2*InitialWindowSize)
+	}
+}
+
+func TestChannelWindowViolationResets(t *testing.T) {
+	connA, connB := connectionPair(t)
+
+	channelA, channelB := channelPair(connA, connB)
+
+	// 绕过发送窗口直接发送超过接收窗口的数据
+	for sent := 0; sent <= InitialWindowSize; sent += maxFlowFrameSize {
+		SendMessge(context.Background(), connA, BuildMsgOfFlow(make([]byte, maxFlowFrameSize), channelA.Id))
+	}
+
+	within(t, 2*time.Second, "peer reset", func() error {
+		_, err := channelA.Read(make([]byte, 1))
+		if !errors.Is(err, ErrChannelClosed) {
+			return fmt.Errorf("read from violating channel: %v", err)
+		}
+		return nil
+	})
+
+	if channelB.Available() {
+		t.Error("receiver kept the channel open after window violation")
+	}
+
+	channelB.Lock()
+	buffered := channelB.readBuffSize
+	channelB.Unlock()
+
+	if buffered != 0 {
+		t.Errorf("receiver kept %d bytes after reset", buffered)
+	}
+
+	if _, ok := connB.getChannel(channelB.Id); ok {
+		t.Error("receiver did not remove the reset channel")
+	}
+}
+
+// 一端结束发送后，对端读取完已发送的数据后得到 EOF，反方向仍可收发，直到对端也结束发送
+func TestChannelHalfClose(t *testing.T) {
+	connA, connB := connectionPair(t)
+
+	channelA, channelB := channelPair(connA, connB)
+
+	if _, err := channelA.Write([]byte("request")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := channelA.CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite() error = %v", err)
+	}
+
+	within(t, 2*time.Second, "read until fin", func() error {
+		got, err := io.ReadAll(channelB)
+		if err != nil || string(got) != "request" {
+			return fmt.Errorf("ReadAll() = %q, %v, want \"request\"", got, err)
+		}
+		return nil
+	})
+
+	if _, err := channelA.Write([]byte("x")); err == nil {
+		t.Error("Write() after CloseWrite succeeded")
+	}
+	if err := channelA.CloseWrite(); err != nil {
+		t.Errorf("second CloseWrite() error = %v", err)
+	}
+
+	// 反方向的数据量超过一个窗口，收到 FIN 的一端仍需更新窗口
+	payload := bytes.Repeat([]byte("response"), InitialWindowSize/4)
+	go channelB.Write(payload)
+
+	within(t, 2*time.Second, "reverse direction", func() error {
+		got := make([]byte, len(payload))
+		if _, err := io.ReadFull(channelA, got); err != nil {
+			return err
+		}
+		if !bytes.Equal(got, payload) {
+			return errors.New("reverse data mismatch")
+		}
+		return nil
+	})
+
+	if err := channelB.CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite() error = %v", err)
+	}
+
+	within(t, 2*time.Second, "reverse fin", func() error {
+		if n, err := channelA.Read(make([]byte, 1)); err != io.EOF {
+			return fmt.Errorf("Read() = %d, %v, want EOF", n, err)
+		}
+		return nil
+	})
+
+	// 半关闭不关闭通道
+	if !channelA.Available() || !channelB.Available() {
+		t.Error("half-closed channel is not available")
+	}
+}
+
+func TestChannelUpdateWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		increments []uint32
+		wantWindow int
+		wantOpen   bool
+	}{
+		{"increment", []uint32{1024}, InitialWindowSize + 1024, true},
+		{"up to max", []uint32{maxWindowSize - InitialWindowSize}, maxWindowSize, true},
+		{"overflow", []uint32{math.MaxUint32}, InitialWindowSize, false},
+		{"overflow after increments", []uint32{math.MaxInt32 / 2, math.MaxInt32 / 2}, InitialWindowSize + math.MaxInt32/2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connA, _ := connectionPair(t)
+			channel := connA.ApplyChannel()
+
+			for _, increment := range tt.increments {
+				channel.UpdateWindow(increment)
+			}
+
+			if channel.Available() != tt.wantOpen {
+				t.Errorf("Available() = %v, want %v", channel.Available(), tt.wantOpen)
+			}
+
+			channel.Lock()
+			window := channel.sendWindow
+			channel.Unlock()
+
+			if window != tt.wantWindow {
+				t.Errorf("sendWindow = %d, want %d", window, tt.wantWindow)
+			}
+		})
+	}
+}
+
+// rawPeer 在 net.Pipe 的另一端直接收发消息帧，用于检查 Connection 发送的控制消息
+type rawPeer struct {
+	conn   net.Conn
+	frames chan *msg.Msg
+}
+
+func newRawPeer(t *testing.T) (*Connection, *rawPeer) {
+	t.Helper()
+
+	a, b := net.Pipe()
+	conn := NewConnection(b)
+
+	go conn.Read()
+	go conn.Write()
+
+	peer := &rawPeer{conn: a, frames: make(chan *msg.Msg, 64)}
+
+	go func() {
+		defer close(peer.frames)
+
+		reader := bufio.NewReader(a)
+		for {
+			data, err := msg.Decode(reader)
+			if err != nil {
+				return
+			}
+
+			m := &msg.Msg{}
+			if err := proto.Unmarshal(data, m); err != nil {
+				return
+			}
+			peer.frames <- m
+		}
+	}()
+
+	t.Cleanup(func() {
+		conn.Close()
+		a.Close()
+	})
+
+	return conn, peer
+}
+
+func (p *rawPeer) send(t *testing.T, m *msg.Msg) {
+	t.Helper()
+
+	data, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = msg.Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// frame 收到的消息帧的命令及 id
+type frame struct {
+	cmd MsgCmd
+	id  uint32
+}
+
+// sync 发送 ping 并返回收到 pong 之前的消息帧；对端按顺序处理消息，
+// 收到 pong 时之前发送的消息均已处理
+func (p *rawPeer) sync(t *testing.T) []frame {
+	t.Helper()
+
+	p.send(t, BuildMsgOfPing())
+
+	var frames []frame
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case m, ok := <-p.frames:
+			if !ok {
+				t.Fatal("connection closed")
+			}
+			if MsgCmd(m.Cmd) == PongMsgCmd {
+				return frames
+			}
+			frames = append(frames, frame{MsgCmd(m.Cmd), m.Id})
+		case <-timeout:
+			t.Fatal("pong timeout")
+		}
+	}
+}
+
+func TestChannelCloseHandshake(t *testing.T) {
+	const id, unknownId = 3, 5
+
+	late := []*msg.Msg{
+		BuildMsgOfFlow([]byte("late"), id),
+		BuildMsgOfFin(id),
+		BuildMsgOfWindowUpdate(id, 1024),
+		BuildMsgOfFlow([]byte("late"), id),
+	}
+
+	tests := []struct {
+		name  string
+		local func(conn *Connection, channel *Channel)
+		peer  []*msg.Msg
+
+		// 本端发送的消息帧
+		want     []frame
+		wantOpen bool
+	}{
+		{
+			name:  "local close acknowledged",
+			local: func(conn *Connection, channel *Channel) { channel.Close() },
+			peer:  append(late, BuildMsgOfChannelClose(id)),
+			want:  []frame{{CloseMsgCmd, id}},
+		},
+		{
+			name: "remote close",
+			peer: []*msg.Msg{BuildMsgOfChannelClose(id)},
+			want: []frame{{CloseMsgCmd, id}},
+		},
+		{
+			name:  "simultaneous close",
+			local: func(conn *Connection, channel *Channel) { channel.Close() },
+			peer:  []*msg.Msg{BuildMsgOfChannelClose(id)},
+			want:  []frame{{CloseMsgCmd, id}},
+		},
+		{
+			name:  "local reset ignores late frames",
+			local: func(conn *Connection, channel *Channel) { channel.Reset() },
+			peer:  append(late, BuildMsgOfChannelClose(id)),
+			want:  []frame{{RstMsgCmd, id}},
+		},
+		{
+			name:  "reject ignores late frames",
+			local: func(conn *Connection, channel *Channel) { channel.reject(ForbiddenCode) },
+			peer:  late,
+			want:  []frame{{RstMsgCmd, id}},
+		},
+		{
+			name: "remote reset",
+			peer: []*msg.Msg{BuildMsgOfChannelReset(id)},
+		},
+		{
+			name: "unknown channel reset once",
+			peer: []*msg.Msg{
+				BuildMsgOfFlow([]byte("late"), unknownId),
+				BuildMsgOfFlow([]byte("late"), unknownId),
+				BuildMsgOfFin(unknownId),
+				BuildMsgOfChannelClose(unknownId),
+			},
+			want:     []frame{{RstMsgCmd, unknownId}},
+			wantOpen: true,
+		},
+		{
+			name: "expired reset record",
+			local: func(conn *Connection, channel *Channel) {
+				conn.chMutex.Lock()
+				conn.resetChannels[unknownId] = time.Now().Add(-resetLinger)
+				conn.chMutex.Unlock()
+			},
+			peer:     []*msg.Msg{BuildMsgOfFlow([]byte("late"), unknownId)},
+			want:     []frame{{RstMsgCmd, unknownId}},
+			wantOpen: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, peer := newRawPeer(t)
+
+			channel := NewChannel(id, conn)
+			conn.RegChannel(id, channel)
+
+			if tt.local != nil {
+				tt.local(conn, channel)
+			}
+			for _, m := range tt.peer {
+				peer.send(t, m)
+			}
+
+			if got := peer.sync(t); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent frames = %v, want %v", got, tt.want)
+			}
+
+			if channel.Available() != tt.wantOpen {
+				t.Errorf("Available() = %v, want %v", channel.Available(), tt.wantOpen)
+			}
+			if _, ok := conn.getChannel(id); ok != tt.wantOpen {
+				t.Errorf("channel registered = %v, want %v", ok, tt.wantOpen)
+			}
+
+			conn.chMutex.RLock()
+			pending := len(conn.pendingClose)
+			conn.chMutex.RUnlock()
+
+			if pending != 0 {
+				t.Errorf("pendingClose has %d ids after the handshake", pending)
+			}
+		})
+	}
+}
+
+// 清理超过 resetLinger 的记录，保留最近的记录
+func TestMarkResetPurge(t *testing.T) {
+	conn := NewConnection(nil)
+
+	now := time.Now()
+	conn.resetChannels[1] = now.Add(-2 * resetLinger)
+	conn.resetChannels[3] = now.Add(-resetLinger / 2)
+	conn.resetPurged = now.Add(-resetLinger)
+
+	conn.markReset(5)
+
+	for id, want := range map[uint32]bool{1: false, 3: true, 5: true} {
+		if _, ok := conn.resetChannels[id]; ok != want {
+			t.Errorf("reset record %d kept = %v, want %v", id, ok, want)
+		}
+	}
+
+	// 距上次清理不足 resetLinger 时不再清理
+	conn.resetChannels[7] = now.Add(-2 * resetLinger)
+	conn.markReset(9)
+
+	if _, ok := conn.resetChannels[7]; !ok {
+		t.Error("reset records purged again within resetLinger")
+	}
+}
+
+// listenPolicy 只允许在 0.0.0.0 上监听
+type listenPolicy struct{}
+
+func (listenPolicy) Allow(user string, host string, ip net.IP, port int) bool {
+	return ip.Equal(net.IPv4zero)
+}
+
+func TestListenAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  AccessPolicy
+		addr    string
+		want    string
+		wantErr error
+	}{
+		{"loopback by default", nil, "127.0.0.1:8080", "127.0.0.1:8080", nil},
+		{"ipv6 loopback by default", nil, "[::1]:8080", "[::1]:8080", nil},
+		{"random port by default", nil, "127.0.0.1:0", "127.0.0.1:0", nil},
+		{"privileged port by default", nil, "127.0.0.1:80", "", errForbidden},
+		{"all interfaces by default", nil, ":8080", "", errForbidden},
+		{"unspecified by default", nil, "0.0.0.0:8080", "", errForbidden},
+		{"all interfaces by policy", listenPolicy{}, ":8080", ":8080", nil},
+		{"loopback by policy", listenPolicy{}, "127.0.0.1:8080", "", errForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := NewConnection(nil)
+			conn.SetListenPolicy(tt.policy)
+
+			got, err := conn.listenAllowed(context.Background(), tt.addr)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("listenAllowed(%s) error = %v, want %v", tt.addr, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("listenAllowed(%s) = %s, want %s", tt.addr, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewConnection(nil).listenAllowed(context.Background(), "127.0.0.1"); err == nil {
+		t.Error("listenAllowed() accepted an addr without port")
+	}
+}
+
+// policyFunc 以函数实现 AccessPolicy
+type policyFunc func(user string, host string, ip net.IP, port int) bool
+
+func (f policyFunc) Allow(user string, host string, ip net.IP, port int) bool {
+	return f(user, host, ip, port)
+}
+
+// staticResolver 按固定表解析域名
+type staticResolver map[string][]net.IP
+
+func (r staticResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ips, ok := r[host]; ok {
+		return ips, nil
+	}
+	return nil, fmt.Errorf("no such host %s", host)
+}
+
+func TestResolveAllowed(t *testing.T) {
+	resolver := staticResolver{
+		"mixed.example":  {net.ParseIP("10.0.0.1"), net.ParseIP("203.0.113.7")},
+		"rebind.example": {net.ParseIP("127.0.0.1")},
+	}
+	public := policyFunc(func(user string, host string, ip net.IP, port int) bool {
+		return !ip.IsLoopback() && !ip.IsPrivate()
+	})
+
+	tests := []struct {
+		name     string
+		policy   AccessPolicy
+		addr     string
+		want     []string
+		wantCode int32
+	}{
+		{"no policy", nil, "mixed.example:443", []string{"10.0.0.1:443", "203.0.113.7:443"}, 0},
+		{"drop internal addresses", public, "mixed.example:443", []string{"203.0.113.7:443"}, 0},
+		{"domain to internal address", public, "rebind.example:443", nil, ForbiddenCode},
+		{"internal ip", public, "127.0.0.1:80", nil, ForbiddenCode},
+		{"resolve fail", public, "missing.example:443", nil, ResolveFailCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := NewConnection(nil)
+			conn.SetResolver(resolver)
+			conn.SetAccessPolicy(tt.policy)
+
+			got, err := conn.resolveAllowed(context.Background(), tt.addr)
+
+			var remoteErr *RemoteError
+			if tt.wantCode != 0 {
+				if !errors.As(err, &remoteErr) || remoteErr.Code != tt.wantCode {
+					t.Fatalf("resolveAllowed(%s) error = %v, want code %d", tt.addr, err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveAllowed(%s) error = %v", tt.addr, err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("resolveAllowed(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenProtocolViolation(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         uint32
+		existing   bool
+		data       []byte
+		wantClosed bool
+	}{
+		// 偶数 id 由本端分配，RST 会重置本端的通道
+		{"even id", 2, true, nil, true},
+		{"unused even id", 4, false, nil, true},
+		{"existing odd id", 3, true, nil, true},
+		{"invalid payload", 5, false, []byte{0xff}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connA, connB := connectionPair(t)
+
+			// 对端发起 OPEN 前注册同一 id 的通道，拒绝时由它收到 RST
+			opener := NewChannel(tt.id, connA)
+			connA.RegChannel(tt.id, opener)
+
+			if tt.existing {
+				connB.RegChannel(tt.id, NewChannel(tt.id, connB))
+			}
+
+			openMsg := BuildMsgOfOpen(tt.id, &msg.NewChannelReq{Addr: "127.0.0.1:1"})
+			if tt.data != nil {
+				openMsg.Data = tt.data
+			}
+			SendMessge(context.Background(), connA, openMsg)
+
+			if tt.wantClosed {
+				within(t, 2*time.Second, "close connection", func() error {
+					for !connB.Closed() {
+						time.Sleep(10 * time.Millisecond)
+					}
+					return nil
+				})
+				return
+			}
+
+			within(t, 2*time.Second, "reject channel", func() error {
+				_, err := opener.Read(make([]byte, 1))
+
+				var remoteErr *RemoteError
+				if !errors.As(err, &remoteErr) || remoteErr.Code != FailCode {
+					return fmt.Errorf("read from rejected channel: %v", err)
+				}
+				return nil
+			})
+
+			if connB.Closed() {
+				t.Error("connection closed for an invalid payload")
+			}
+		})
+	}
+}
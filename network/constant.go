@@ -1,9 +1,7 @@
 package network
 
 import (
-	"context"
-
-	"github.com/ssp/msg"
+	"github.com/golang/protobuf/proto"
 )
 
 type RpcCmd uint32
@@ -11,6 +9,30 @@ type RpcCmd uint32
 const (
 	LoginCmd        RpcCmd = 11
 	BuildChannelCmd RpcCmd = 12
+
+	BuildAssociateCmd RpcCmd = 13
+	BindCmd           RpcCmd = 14
+	BindAcceptCmd     RpcCmd = 15
+
+	ReverseListenCmd  RpcCmd = 16
+	ReverseChannelCmd RpcCmd = 17
+)
+
+// 响应码
+const (
+	SuccessCode         int32 = 1
+	FailCode            int32 = -1
+	AuthFailCode        int32 = -2
+	UnauthenticatedCode int32 = -3
+	UnsupportedCode     int32 = -4
+	ForbiddenCode       int32 = -5
+	ResolveFailCode     int32 = -6
+
+	// 连接目标失败的原因
+	ConnRefusedCode     int32 = -7
+	NetUnreachableCode  int32 = -8
+	HostUnreachableCode int32 = -9
+	TimeoutCode         int32 = -10
 )
 
 type RpcMsgType uint32
@@ -22,5 +44,4 @@ const (
 
 type RequestId uint32
 
-type RpcProcessor func(ctx context.Context, rpcContext *Context, message *msg.RpcMsg)
-type RpcCallback func(message *msg.RpcMsg)
+type RpcCallback func(res proto.Message)
@@ -1,9 +1,8 @@
 package network
 
 import (
-	"log"
-
 	"github.com/ssp/msg"
+	"github.com/ssp/util"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -11,6 +10,9 @@ type Context struct {
 
 	// 连接对象
 	conn *Connection
+
+	// 响应发送后执行的函数
+	afterReply []func()
 }
 
 func NewContext(conn *Connection) *Context {
@@ -21,6 +23,16 @@ func NewContext(conn *Connection) *Context {
 	return context
 }
 
+// Conn 收到请求的连接
+func (c *Context) Conn() *Connection {
+	return c.conn
+}
+
+// AfterReply 注册在成功响应发送后执行的函数，用于对端收到响应之后才开始转发数据
+func (c *Context) AfterReply(f func()) {
+	c.afterReply = append(c.afterReply, f)
+}
+
 func (c *Context) SendMessge(message *msg.Msg) {
 	bMsg, err := proto.Marshal(message)
 
@@ -30,9 +42,23 @@ func (c *Context) SendMessge(message *msg.Msg) {
 
 	data, err := msg.Encode(bMsg)
 	if err != nil {
-		log.Printf("send %d \n", len(data))
+		util.Infof("send %d \n", len(data))
 		return
 	}
 
 	c.conn.WriteBytes(data)
 }
+
+// reply 发送 req 的响应
+func (c *Context) reply(req *msg.RpcMsg, res proto.Message) {
+	data, err := proto.Marshal(res)
+	if err != nil {
+		util.Errorf("Invlid rpc response!:%s \n", err.Error())
+		return
+	}
+
+	rpcMsg := BuildResponseHeader(req)
+	rpcMsg.Data = data
+
+	c.SendMessge(BuildMsgOfRpc(rpcMsg))
+}
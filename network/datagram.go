@@ -0,0 +1,114 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ssp/util"
+)
+
+type Datagram struct {
+	sync.Mutex
+
+	// 关联id
+	Id uint32
+
+	// 读缓存，每个元素为一个完整的 SOCKS5 UDP 数据包
+	ReadBuff chan []byte
+
+	// Connection
+	UnderlyingConn *Connection
+
+	// 状态
+	flag channelFlag
+
+	// traceId
+	TraceId string
+}
+
+func NewDatagram(id uint32, conn *Connection) *Datagram {
+	datagram := new(Datagram)
+
+	datagram.ReadBuff = make(chan []byte, conn.config.ReadBuffSize)
+	datagram.UnderlyingConn = conn
+	datagram.Id = id
+	datagram.flag = channelOpenFlag
+
+	return datagram
+}
+
+// Write 发送一个数据包，p 为带 SOCKS5 UDP 头的完整数据包
+func (d *Datagram) Write(p []byte) (n int, err error) {
+	if !d.Available() {
+		return 0, errors.New("Datagram Close.")
+	}
+
+	// 复制一份，调用方会复用 p
+	data := make([]byte, len(p))
+	copy(data, p)
+
+	datagramMsg := BuildMsgOfDatagram(data, d.Id)
+	SendMessge(context.TODO(), d.UnderlyingConn, datagramMsg)
+
+	return len(p), nil
+}
+
+// Close 关闭关联并通知对端
+func (d *Datagram) Close() error {
+	if d.shutdown() {
+		closeMsg := BuildMsgOfDatagramClose(d.Id)
+		SendMessge(context.TODO(), d.UnderlyingConn, closeMsg)
+	}
+
+	return nil
+}
+
+// shutdown 只关闭本地关联，返回是否由本次调用关闭
+func (d *Datagram) shutdown() bool {
+	d.Lock()
+
+	if d.flag == channelCloseFlag {
+		d.Unlock()
+		return false
+	}
+
+	d.flag = channelCloseFlag
+	close(d.ReadBuff)
+
+	d.Unlock()
+
+	d.UnderlyingConn.RemoveDatagram(d.Id)
+
+	util.Infof("%s,Close datagram %s \n", d.TraceId, d.String())
+
+	return true
+}
+
+// AppendReadBuff 写入读缓存，缓存已满时丢弃数据包，不阻塞连接的读协程
+func (d *Datagram) AppendReadBuff(data []byte) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.flag == channelCloseFlag {
+		return
+	}
+
+	select {
+	case d.ReadBuff <- data:
+	default:
+		util.Infof("%s,Datagram %d read buffer full, drop packet \n", d.TraceId, d.Id)
+	}
+}
+
+func (d *Datagram) String() string {
+	return fmt.Sprintf("%s-%s:%d", d.UnderlyingConn.conn.RemoteAddr(), d.UnderlyingConn.conn.LocalAddr(), d.Id)
+}
+
+func (d *Datagram) Available() bool {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.flag == channelOpenFlag
+}
@@ -0,0 +1,47 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// DialErrorCode 连接目标失败的错误对应的响应码：对端返回的错误使用其中的响应码，
+// 本地的连接错误按原因归类，无法归类时为 FailCode
+func DialErrorCode(err error) int32 {
+	var remote *RemoteError
+	if errors.As(err, &remote) {
+		return remote.Code
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ResolveFailCode
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ConnRefusedCode
+	case errors.Is(err, syscall.ENETUNREACH):
+		return NetUnreachableCode
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.EHOSTDOWN):
+		return HostUnreachableCode
+	case errors.Is(err, syscall.ETIMEDOUT), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, ErrRpcTimeout):
+		return TimeoutCode
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return TimeoutCode
+	}
+
+	return FailCode
+}
+
+// dialError 连接目标失败时返回给客户端的错误
+func dialError(err error) *RemoteError {
+	return &RemoteError{Code: DialErrorCode(err), Msg: err.Error()}
+}
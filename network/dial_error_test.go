@@ -0,0 +1,74 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// timeoutError 只实现 net.Error 的超时错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// opError 模拟 net.Dial 返回的错误
+func opError(errno syscall.Errno) error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)}
+}
+
+func TestDialErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int32
+	}{
+		{"remote error", &RemoteError{Code: ForbiddenCode, Msg: "not allowed by ruleset"}, ForbiddenCode},
+		{"wrapped remote error", fmt.Errorf("open channel: %w", &RemoteError{Code: ConnRefusedCode}), ConnRefusedCode},
+		{"dns error", &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "missing.example", IsNotFound: true}}, ResolveFailCode},
+		{"dns timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, ResolveFailCode},
+		{"connection refused", opError(syscall.ECONNREFUSED), ConnRefusedCode},
+		{"network unreachable", opError(syscall.ENETUNREACH), NetUnreachableCode},
+		{"host unreachable", opError(syscall.EHOSTUNREACH), HostUnreachableCode},
+		{"host down", opError(syscall.EHOSTDOWN), HostUnreachableCode},
+		{"connect timeout", opError(syscall.ETIMEDOUT), TimeoutCode},
+		{"context deadline", fmt.Errorf("dial: %w", context.DeadlineExceeded), TimeoutCode},
+		{"io deadline", &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, TimeoutCode},
+		{"rpc timeout", ErrRpcTimeout, TimeoutCode},
+		{"net timeout", &net.OpError{Op: "dial", Err: timeoutError{}}, TimeoutCode},
+		{"connection reset", opError(syscall.ECONNRESET), FailCode},
+		{"context canceled", context.Canceled, FailCode},
+		{"other", errors.New("boom"), FailCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DialErrorCode(tt.err); got != tt.want {
+				t.Errorf("DialErrorCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialErrorCodeRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = net.Dial("tcp", addr)
+	if err == nil {
+		t.Skip("closed port accepted the connection")
+	}
+
+	if got := DialErrorCode(err); got != ConnRefusedCode {
+		t.Errorf("DialErrorCode(%v) = %d, want %d", err, got, ConnRefusedCode)
+	}
+}
@@ -3,22 +3,36 @@ package network
 import (
 	"context"
 	"io"
-	"log"
+	"sync"
 
 	"github.com/ssp/util"
 )
 
+// FlowForward 双向转发通道与远程连接的数据，一个方向正常结束时只关闭对应的写方向，
+// 两个方向都结束时关闭通道和远程连接，任一方向出错时重置通道
 func FlowForward(ctx context.Context, client *Channel, target *RemoteConn) {
 
 	traceId, _ := ctx.Value("traceId").(string)
 
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	closeAll := func() {
+		client.Close()
+		target.Close()
+	}
+
+	resetAll := func() {
+		client.Reset()
+		target.Close()
+	}
+
 	ch2connForward := func(src *Channel, dest *RemoteConn) {
 
 		defer util.Trace(traceId, "ch2connForward")()
-		defer src.Close()
-		defer dest.Close()
+		defer wg.Done()
 
-		log.Printf("%s,Start ch2conn: %s\n", traceId, client)
+		util.Infof("%s,Start ch2conn: %s\n", traceId, client)
 
 		var written int64
 		var err error
@@ -26,18 +40,21 @@ func FlowForward(ctx context.Context, client *Channel, target *RemoteConn) {
 			written, err = io.Copy(dest.Target, src)
 		}
 
-		log.Printf("%s,Close ch2conn: %s,written:%d\n", traceId, client, written)
+		util.Infof("%s,Close ch2conn: %s,written:%d\n", traceId, client, written)
 		if err != nil {
-			log.Printf("%s,Close ch2conn: %s,case:%s\n", traceId, client, err.Error())
+			util.Errorf("%s,Close ch2conn: %s,case:%s\n", traceId, client, err.Error())
+			resetAll()
+			return
 		}
+
+		dest.CloseWrite()
 	}
 
 	conn2chForward := func(src *RemoteConn, dest *Channel) {
 		defer util.Trace(traceId, "conn2chForward")()
-		defer src.Close()
-		defer dest.Close()
+		defer wg.Done()
 
-		log.Printf("%s,Start conn2ch: %s\n", traceId, client)
+		util.Infof("%s,Start conn2ch: %s\n", traceId, client)
 
 		var written int64
 		var err error
@@ -46,13 +63,60 @@ func FlowForward(ctx context.Context, client *Channel, target *RemoteConn) {
 			written, err = io.Copy(dest, src.Target)
 		}
 
-		log.Printf("%s,Close conn2ch: %s,written:%d\n", traceId, client, written)
+		util.Infof("%s,Close conn2ch: %s,written:%d\n", traceId, client, written)
 		if err != nil {
-			log.Printf("%s,Close conn2ch: %s,case:%s\n", traceId, client, err.Error())
+			util.Errorf("%s,Close conn2ch: %s,case:%s\n", traceId, client, err.Error())
+			resetAll()
+			return
 		}
 
+		dest.CloseWrite()
 	}
 
 	go ch2connForward(client, target)
 	go conn2chForward(target, client)
+
+	go func() {
+		wg.Wait()
+		closeAll()
+	}()
+}
+
+// ConnForward 双向转发两个远程连接的数据，用于不经过隧道的直连，
+// 一个方向正常结束时只关闭对应的写方向，两个方向都结束或任一方向出错时关闭两端
+func ConnForward(ctx context.Context, client *RemoteConn, target *RemoteConn) {
+
+	traceId, _ := ctx.Value("traceId").(string)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	closeAll := func() {
+		client.Close()
+		target.Close()
+	}
+
+	forward := func(name string, src *RemoteConn, dest *RemoteConn) {
+		defer util.Trace(traceId, name)()
+		defer wg.Done()
+
+		written, err := io.Copy(dest.Target, src.Target)
+
+		util.Infof("%s,Close %s: %s,written:%d\n", traceId, name, src.Target.RemoteAddr(), written)
+		if err != nil {
+			util.Errorf("%s,Close %s: %s,case:%s\n", traceId, name, src.Target.RemoteAddr(), err.Error())
+			closeAll()
+			return
+		}
+
+		dest.CloseWrite()
+	}
+
+	go forward("client2target", client, target)
+	go forward("target2client", target, client)
+
+	go func() {
+		wg.Wait()
+		closeAll()
+	}()
 }
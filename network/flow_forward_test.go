@@ -0,0 +1,116 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// forwardPair 在通道对的远端与 TCP 连接之间转发，返回本端通道与目标端的连接
+func forwardPair(t *testing.T) (*Channel, *Channel, *net.TCPConn) {
+	t.Helper()
+
+	connA, connB := connectionPair(t)
+	local, remote := channelPair(connA, connB)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	target, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer, err := listener.Accept()
+	if err != nil {
+		target.Close()
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		target.Close()
+		peer.Close()
+	})
+
+	FlowForward(context.Background(), remote, NewRemoteConn(target))
+
+	return local, remote, peer.(*net.TCPConn)
+}
+
+// 一个方向结束后只关闭对应的写方向，另一方向继续转发直到其结束，之后关闭通道
+func TestFlowForwardHalfClose(t *testing.T) {
+	// 超过一个窗口的数据，验证半关闭后窗口更新仍然有效
+	payload := bytes.Repeat([]byte("payload!"), InitialWindowSize/4)
+
+	tests := []struct {
+		name string
+
+		// 由哪一端先结束发送
+		channelFirst bool
+	}{
+		{"channel fin first", true},
+		{"target fin first", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local, remote, peer := forwardPair(t)
+
+			// first 先发送少量数据并结束，second 随后发送大量数据并结束
+			var firstW, secondW io.Writer = local, peer
+			var firstR, secondR io.Reader = peer, local
+			firstClose, secondClose := local.CloseWrite, peer.CloseWrite
+			if !tt.channelFirst {
+				firstW, secondW = peer, local
+				firstR, secondR = local, peer
+				firstClose, secondClose = peer.CloseWrite, local.CloseWrite
+			}
+
+			if _, err := firstW.Write([]byte("request")); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := firstClose(); err != nil {
+				t.Fatalf("CloseWrite() error = %v", err)
+			}
+
+			within(t, 2*time.Second, "first direction", func() error {
+				got, err := io.ReadAll(firstR)
+				if err != nil || string(got) != "request" {
+					return fmt.Errorf("ReadAll() = %q, %v, want \"request\"", got, err)
+				}
+				return nil
+			})
+
+			go func() {
+				secondW.Write(payload)
+				secondClose()
+			}()
+
+			within(t, 2*time.Second, "second direction", func() error {
+				got, err := io.ReadAll(secondR)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(got, payload) {
+					return fmt.Errorf("received %d bytes, want %d", len(got), len(payload))
+				}
+				return nil
+			})
+
+			// 两个方向都结束后关闭通道
+			within(t, 2*time.Second, "channel closed", func() error {
+				for remote.Available() || local.Available() {
+					time.Sleep(10 * time.Millisecond)
+				}
+				return nil
+			})
+		})
+	}
+}
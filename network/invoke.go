@@ -0,0 +1,129 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/ssp/msg"
+	"github.com/ssp/util"
+)
+
+var (
+	// ErrRpcTimeout 在 ctx 截止时间之前没有收到响应
+	ErrRpcTimeout = errors.New("rpc timeout")
+
+	// ErrConnectionClosed 连接已关闭或在等待响应时关闭
+	ErrConnectionClosed = errors.New("connection closed")
+
+	// ErrInvalidResponse 响应无法解析
+	ErrInvalidResponse = errors.New("invalid rpc response")
+)
+
+// RemoteError 对端返回的失败响应码
+type RemoteError struct {
+	Code int32
+	Msg  string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("remote error %d: %s", e.Code, e.Msg)
+}
+
+// 各请求对应的响应消息
+var rpcResponses = map[RpcCmd]func() proto.Message{
+	LoginCmd:          func() proto.Message { return &msg.CommonRes{} },
+	BuildChannelCmd:   func() proto.Message { return &msg.NewChannelRes{} },
+	BuildAssociateCmd: func() proto.Message { return &msg.NewAssociateRes{} },
+	BindCmd:           func() proto.Message { return &msg.NewChannelRes{} },
+	BindAcceptCmd:     func() proto.Message { return &msg.BindAcceptRes{} },
+	ReverseListenCmd:  func() proto.Message { return &msg.ReverseListenRes{} },
+	ReverseChannelCmd: func() proto.Message { return &msg.CommonRes{} },
+}
+
+// codedRes 带响应码的响应消息
+type codedRes interface {
+	GetCode() int32
+	GetMsg() string
+}
+
+// Invoke 发送请求并等待响应，ctx 截止或取消时返回，
+// 响应码不是 SuccessCode 时同时返回响应与 *RemoteError
+func (c *Connection) Invoke(ctx context.Context, cmd RpcCmd, req proto.Message) (proto.Message, error) {
+	return c.InvokeWithCallback(ctx, cmd, req, nil)
+}
+
+// InvokeWithCallback 同 Invoke，callback 在连接读协程中处理响应时调用，
+// 返回前不会处理后续消息，用于在对端数据到达之前注册通道
+func (c *Connection) InvokeWithCallback(ctx context.Context, cmd RpcCmd, req proto.Message, callback RpcCallback) (proto.Message, error) {
+	newRes, ok := rpcResponses[cmd]
+	if !ok {
+		return nil, fmt.Errorf("unknown rpc cmd: %d", cmd)
+	}
+
+	return c.invoke(ctx, cmd, req, newRes, callback)
+}
+
+// Call 发送自定义命令的请求，按 Res 类型解析响应，其它同 Invoke
+func Call[Res any, PRes interface {
+	*Res
+	proto.Message
+}](ctx context.Context, c *Connection, cmd RpcCmd, req proto.Message) (PRes, error) {
+
+	res, err := c.invoke(ctx, cmd, req, func() proto.Message { return PRes(new(Res)) }, nil)
+	if res == nil {
+		return nil, err
+	}
+
+	return res.(PRes), err
+}
+
+func (c *Connection) invoke(ctx context.Context, cmd RpcCmd, req proto.Message, newRes func() proto.Message, callback RpcCallback) (proto.Message, error) {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	request := BuildRequestHeader(c, cmd)
+	request.Data = data
+
+	promise := NewRpcPromise(newRes, callback)
+	if !c.RegPromise(request.Id, promise) {
+		return nil, ErrConnectionClosed
+	}
+
+	if err := SendMessge(ctx, c, BuildMsgOfRpc(request)); err != nil {
+		c.removePromise(request.Id)
+		return nil, ErrConnectionClosed
+	}
+
+	var result rpcResult
+
+	select {
+	case result = <-promise.result:
+	case <-ctx.Done():
+		// 响应已经在处理中时仍然使用该响应
+		if c.removePromise(request.Id) {
+			traceId, _ := ctx.Value("traceId").(string)
+			util.Errorf("%s,Rpc %d cancelled:%s\n", traceId, cmd, ctx.Err())
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, ErrRpcTimeout
+			}
+			return nil, ctx.Err()
+		}
+
+		result = <-promise.result
+	}
+
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	if coded, ok := result.res.(codedRes); ok && coded.GetCode() != SuccessCode {
+		return result.res, &RemoteError{Code: coded.GetCode(), Msg: coded.GetMsg()}
+	}
+
+	return result.res, nil
+}
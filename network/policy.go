@@ -0,0 +1,110 @@
+package network
+
+import (
+	"context"
+	"net"
+)
+
+// AccessPolicy 目标访问控制，host 为请求中的主机名或 IP，ip 为实际连接的地址
+type AccessPolicy interface {
+	Allow(user string, host string, ip net.IP, port int) bool
+}
+
+// errForbidden 目标被访问控制拒绝
+var errForbidden = &RemoteError{Code: ForbiddenCode, Msg: "not allowed by ruleset"}
+
+// SetAccessPolicy 设置目标访问控制，为空时允许所有目标
+func (c *Connection) SetAccessPolicy(policy AccessPolicy) {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+
+	c.policy = policy
+}
+
+// allow 当前登录用户能否访问目标
+func (c *Connection) allow(host string, ip net.IP, port int) bool {
+	c.authMutex.RLock()
+	defer c.authMutex.RUnlock()
+
+	return c.policy == nil || c.policy.Allow(c.user, host, ip, port)
+}
+
+// resolveAllowed 解析目标地址，返回访问控制允许连接的地址，全部被拒绝时返回 errForbidden；
+// 按解析后的 IP 校验，指向内部地址的域名同样会被拒绝
+func (c *Connection) resolveAllowed(ctx context.Context, addr string) ([]string, error) {
+	host, service, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := net.DefaultResolver.LookupPort(ctx, "tcp", service)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := c.lookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, ip := range ips {
+		if c.allow(host, ip, port) {
+			addrs = append(addrs, net.JoinHostPort(ip.String(), service))
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, errForbidden
+	}
+
+	return addrs, nil
+}
+
+// SetListenPolicy 设置远程端口转发监听地址的访问控制，为空时只允许在回环地址上监听非特权端口
+func (c *Connection) SetListenPolicy(policy AccessPolicy) {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+
+	c.listenPolicy = policy
+}
+
+// listenAllowed 校验远程端口转发的监听地址，返回实际监听的地址，被拒绝时返回 errForbidden；
+// 主机名为空时按 0.0.0.0 校验并监听所有地址，域名按解析出的第一个地址校验并监听该地址
+func (c *Connection) listenAllowed(ctx context.Context, addr string) (string, error) {
+	host, service, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+
+	port, err := net.DefaultResolver.LookupPort(ctx, "tcp", service)
+	if err != nil {
+		return "", err
+	}
+
+	ip := net.IPv4zero
+	if host != "" {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return "", err
+		}
+
+		ip = ips[0]
+		addr = net.JoinHostPort(ip.String(), service)
+	}
+
+	c.authMutex.RLock()
+	policy, user := c.listenPolicy, c.user
+	c.authMutex.RUnlock()
+
+	allowed := ip.IsLoopback() && (port == 0 || port >= 1024)
+	if policy != nil {
+		allowed = policy.Allow(user, host, ip, port)
+	}
+
+	if !allowed {
+		return "", errForbidden
+	}
+
+	return addr, nil
+}
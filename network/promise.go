@@ -1,54 +1,51 @@
 package network
 
 import (
-	"log"
-	"time"
-
+	"github.com/golang/protobuf/proto"
 	"github.com/ssp/msg"
 )
 
+type rpcResult struct {
+	res proto.Message
+	err error
+}
+
+// RpcPromise 等待中的 RPC 请求，结果只会写入一次且不会阻塞
 type RpcPromise struct {
-	timer    *time.Timer
-	result   chan *msg.RpcMsg
+	result chan rpcResult
+
+	// 创建响应消息
+	newRes func() proto.Message
+
 	callback RpcCallback
-	traceId  string
 }
 
-func NewRpcPromise(traceId string, timeout time.Duration, callback RpcCallback) *RpcPromise {
+func NewRpcPromise(newRes func() proto.Message, callback RpcCallback) *RpcPromise {
 	promise := &RpcPromise{}
 
-	promise.timer = time.NewTimer(timeout)
-	promise.result = make(chan *msg.RpcMsg)
+	promise.result = make(chan rpcResult, 1)
+	promise.newRes = newRes
 	promise.callback = callback
-	promise.traceId = traceId
 
 	return promise
 }
 
-func (p *RpcPromise) Get() (*msg.RpcMsg, bool) {
-	var res *msg.RpcMsg
-
-	select {
-	case res := <-p.result:
-		p.timer.Stop()
+// Set 在连接读协程中调用，回调先于结果返回执行
+func (p *RpcPromise) Set(message *msg.RpcMsg) {
+	res := p.newRes()
 
-		if p.callback != nil {
-			p.callback(res)
-		}
-
-		return res, true
-	case <-p.timer.C: //超时
-		log.Printf("%s,RpcPromise timeout.\n", p.traceId)
-		return res, false
+	if err := proto.Unmarshal(message.Data, res); err != nil {
+		p.fail(ErrInvalidResponse)
+		return
 	}
-}
-
-func (p *RpcPromise) Set(res *msg.RpcMsg) bool {
-	p.result <- res
 
 	if p.callback != nil {
 		p.callback(res)
 	}
 
-	return true
+	p.result <- rpcResult{res: res}
+}
+
+func (p *RpcPromise) fail(err error) {
+	p.result <- rpcResult{err: err}
 }
@@ -1,9 +1,10 @@
 package network
 
 import (
-	"log"
 	"net"
 	"sync"
+
+	"github.com/ssp/util"
 )
 
 type remoteConnFlag uint8
@@ -42,7 +43,7 @@ func (r *RemoteConn) Close() error {
 		r.flag = closeFlag
 		err := r.Target.Close()
 
-		log.Printf("%s,Close remote conn: %s:%s\n", r.TraceId, r.Target.LocalAddr(), r.Target.RemoteAddr())
+		util.Infof("%s,Close remote conn: %s:%s\n", r.TraceId, r.Target.LocalAddr(), r.Target.RemoteAddr())
 
 		return err
 	}
@@ -50,6 +51,25 @@ func (r *RemoteConn) Close() error {
 	return nil
 }
 
+// CloseWrite 关闭远程连接的写方向，不支持半关闭的连接直接关闭
+func (r *RemoteConn) CloseWrite() error {
+
+	r.Lock()
+	defer r.Unlock()
+
+	if r.flag != openFlag {
+		return nil
+	}
+
+	if conn, ok := r.Target.(interface{ CloseWrite() error }); ok {
+		util.Infof("%s,Close write remote conn: %s:%s\n", r.TraceId, r.Target.LocalAddr(), r.Target.RemoteAddr())
+		return conn.CloseWrite()
+	}
+
+	r.flag = closeFlag
+	return r.Target.Close()
+}
+
 func (r *RemoteConn) Available() bool {
 	r.Lock()
 	defer r.Unlock()
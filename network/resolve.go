@@ -0,0 +1,169 @@
+package network
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Resolver 目标域名解析，返回的地址按优先连接的顺序排列
+type Resolver interface {
+	LookupIP(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// 解析失败，客户端据此返回 SOCKS5 应答 0x04
+func errResolve(err error) *RemoteError {
+	return &RemoteError{Code: ResolveFailCode, Msg: err.Error()}
+}
+
+// SetResolver 设置目标域名解析，为空时使用系统解析
+func (c *Connection) SetResolver(resolver Resolver) {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+
+	c.resolver = resolver
+}
+
+// lookupIP 解析目标主机，host 为 IP 时直接返回，解析失败时返回 ResolveFailCode
+func (c *Connection) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	c.authMutex.RLock()
+	resolver := c.resolver
+	c.authMutex.RUnlock()
+
+	var ips []net.IP
+	var err error
+
+	if resolver != nil {
+		ips, err = resolver.LookupIP(ctx, host)
+	} else {
+		ips, err = net.DefaultResolver.LookupIP(ctx, "ip", host)
+	}
+
+	if err == nil && len(ips) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	if err != nil {
+		return nil, errResolve(err)
+	}
+
+	return ips, nil
+}
+
+// resolveUDPAddr 解析 UDP 目标地址，使用第一个解析结果
+func (c *Connection) resolveUDPAddr(ctx context.Context, addr string) (*net.UDPAddr, error) {
+	host, service, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := net.DefaultResolver.LookupPort(ctx, "udp", service)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := c.lookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &net.UDPAddr{IP: ips[0], Port: port}, nil
+}
+
+// Happy Eyeballs（RFC 8305）中前一个连接尚未完成时发起下一个连接的间隔
+const connectionAttemptDelay = 250 * time.Millisecond
+
+// dialAny 按 Happy Eyeballs 连接各地址：IPv4、IPv6 交替排列，保持第一个地址的协议优先，
+// 前一个连接失败或超过 connectionAttemptDelay 未完成时发起下一个，返回第一个成功的连接
+func dialAny(ctx context.Context, addrs []string) (net.Conn, error) {
+	addrs = interleave(addrs)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	results := make(chan result, len(addrs))
+
+	var dialer net.Dialer
+	dial := func(addr string) {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		results <- result{conn, err}
+	}
+
+	var lastErr error
+	var delay <-chan time.Time
+	next, pending := 0, 0
+
+	for {
+		if next < len(addrs) {
+			go dial(addrs[next])
+			next++
+			pending++
+			delay = time.After(connectionAttemptDelay)
+		}
+
+		if pending == 0 {
+			return nil, lastErr
+		}
+
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// 关闭其余随后完成的连接
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(pending)
+
+				return r.conn, nil
+			}
+
+			lastErr = r.err
+
+		case <-delay:
+		}
+	}
+}
+
+// interleave IPv4、IPv6 地址交替排列，第一个地址的协议在前
+func interleave(addrs []string) []string {
+	var primary, secondary []string
+
+	isIPv4 := func(addr string) bool {
+		host, _, _ := net.SplitHostPort(addr)
+		ip := net.ParseIP(host)
+		return ip != nil && ip.To4() != nil
+	}
+
+	for _, addr := range addrs {
+		if len(primary) == 0 || isIPv4(addr) == isIPv4(primary[0]) {
+			primary = append(primary, addr)
+		} else {
+			secondary = append(secondary, addr)
+		}
+	}
+
+	result := make([]string, 0, len(addrs))
+	for i := 0; i < len(primary) || i < len(secondary); i++ {
+		if i < len(primary) {
+			result = append(result, primary[i])
+		}
+		if i < len(secondary) {
+			result = append(result, secondary[i])
+		}
+	}
+
+	return result
+}
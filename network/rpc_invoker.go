@@ -2,7 +2,9 @@ package network
 
 import (
 	"context"
-	"log"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"net"
 	"time"
 
@@ -11,146 +13,447 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-func RpcInvoker(ctx context.Context, conn *Connection, message *msg.RpcMsg, timeout time.Duration, callbck RpcCallback) *RpcPromise {
-
+func SendMessge(ctx context.Context, conn *Connection, message *msg.Msg) error {
 	traceId, _ := ctx.Value("traceId").(string)
 
-	defer util.Trace(traceId, "Client RpcInvoker")()
+	bMsg, err := proto.Marshal(message)
 
-	rpcMsg := BuildMsgOfRpc(message)
-	log.Printf("%s,send message: %v \n", traceId, rpcMsg)
+	if err != nil {
+		return err
+	}
 
-	SendMessge(ctx, conn, rpcMsg)
+	data, err := msg.Encode(bMsg)
+	if err != nil {
+		util.Infof("%s,send %d \n", traceId, len(data))
+		return err
+	}
 
-	promise := NewRpcPromise(traceId, timeout, callbck)
-	conn.RegPromise(message.Id, promise)
+	return conn.WriteBytes(data)
+}
 
-	return promise
+// errGoingAway 已发送 GOAWAY，不再接受新的请求
+var errGoingAway = &RemoteError{Code: FailCode, Msg: "going away"}
 
-}
+// errUnauthenticated 连接未登录
+var errUnauthenticated = &RemoteError{Code: UnauthenticatedCode, Msg: "unauthenticated"}
 
-func SendMessge(ctx context.Context, conn *Connection, message *msg.Msg) {
-	traceId, _ := ctx.Value("traceId").(string)
+func BuildNewChannel(ctx context.Context, rpcContext *Context, channelReq *msg.NewChannelReq) (*msg.NewChannelRes, error) {
 
-	bMsg, err := proto.Marshal(message)
+	traceId := channelReq.TraceId
+	newCtx := context.WithValue(ctx, "traceId", traceId)
+	defer util.Trace(traceId, "Server BuildNewChannel")()
 
+	dest, err := dialTarget(ctx, rpcContext.conn, channelReq)
 	if err != nil {
+		return nil, err
+	}
+
+	channel := rpcContext.conn.ApplyChannel()
+	channel.TraceId = traceId
+
+	// 对端收到响应、注册通道之后再转发
+	rpcContext.AfterReply(func() {
+		target := NewRemoteConn(dest)
+		target.TraceId = traceId
+		FlowForward(newCtx, channel, target)
+	})
+
+	return &msg.NewChannelRes{ChannelId: channel.Id, BindAddr: dest.LocalAddr().String()}, nil
+}
+
+// acceptOpenChannel 为对端以 OPEN 打开的通道连接目标，成功后转发通道中已缓存及之后的数据，
+// 失败时以携带原因的 RST 拒绝；连接期间对端已关闭通道时丢弃连接
+func acceptOpenChannel(ctx context.Context, conn *Connection, channel *Channel, channelReq *msg.NewChannelReq) {
+
+	traceId := channelReq.TraceId
+	newCtx := context.WithValue(ctx, "traceId", traceId)
+	defer util.Trace(traceId, "Server acceptOpenChannel")()
+
+	// 与 BuildChannelCmd 相同，服务端未提供该命令时不允许打开通道
+	if _, ok := conn.service.handler(BuildChannelCmd); !ok {
+		util.Errorf("%s,Refuse open channel on connection without channel service:%s \n", traceId, conn.conn.RemoteAddr())
+
+		channel.reject(UnsupportedCode)
 		return
 	}
 
-	data, err := msg.Encode(bMsg)
+	dest, err := dialTarget(ctx, conn, channelReq)
 	if err != nil {
-		log.Printf("%s,send %d \n", traceId, len(data))
+		channel.reject(DialErrorCode(err))
+		return
+	}
+
+	if !channel.Available() {
+		util.Infof("%s,Channel %s closed before dial completed \n", traceId, channel.String())
+
+		dest.Close()
 		return
 	}
 
-	conn.WriteBytes(data)
+	channel.BindAddr = dest.LocalAddr().String()
+
+	target := NewRemoteConn(dest)
+	target.TraceId = traceId
+	FlowForward(newCtx, channel, target)
 }
 
-func BuildNewChannel(ctx context.Context, rpcContext *Context, message *msg.RpcMsg) {
+// dialTarget 校验连接状态与访问规则后连接通道请求的目标，失败时返回 *RemoteError
+func dialTarget(ctx context.Context, conn *Connection, channelReq *msg.NewChannelReq) (net.Conn, error) {
 
-	var rpcMsg *msg.RpcMsg
-	var resMsg *msg.Msg
+	traceId := channelReq.TraceId
 
-	channelReq := &msg.NewChannelReq{}
+	if !conn.Authenticated() {
+		util.Errorf("%s,Refuse new channel request on unauthenticated connection:%s \n", traceId, conn.conn.RemoteAddr())
 
-	err := proto.Unmarshal(message.Data, channelReq)
-	if err != nil {
+		return nil, errUnauthenticated
+	}
+
+	// 已发送 GOAWAY，不再接受新的请求
+	if conn.Draining() {
+		util.Warnf("%s,Refuse new channel request on draining connection:%s \n", traceId, conn.conn.RemoteAddr())
 
-		log.Printf("Invlid new channel request!:%s \n", err.Error())
+		return nil, errGoingAway
+	}
 
-		rpcMsg = BuildNewChannelRes(message, 0, -1, err.Error())
-		resMsg = BuildMsgOfRpc(rpcMsg)
+	util.Infof("%s,Receive a new channel request:%+v \n", traceId, channelReq)
 
-		rpcContext.SendMessge(resMsg)
+	addrs, err := conn.resolveAllowed(ctx, channelReq.Addr)
+	if errors.Is(err, errForbidden) {
+		util.Warnf("%s,Refuse new channel request to %s of user %s by ruleset \n", traceId, channelReq.Addr, conn.User())
 
-		return
+		return nil, err
 	}
 
-	traceId := channelReq.TraceId
-	newCtx := context.WithValue(ctx, "traceId", traceId)
-	defer util.Trace(traceId, "Server BuildNewChannel")()
+	if err != nil {
 
-	log.Printf("%s,Receive a new channel request:%+v \n", traceId, channelReq)
+		util.Errorf("%s,Resolve %s error:%s \n", traceId, channelReq.Addr, err.Error())
 
-	// TODO
-	channel := rpcContext.conn.ApplyChannel()
-	channel.TraceId = traceId
+		return nil, err
+	}
 
 	// 建立 TCP 连接
-	// TODO
-	destAddrPort := channelReq.Addr
-	dest, err := net.Dial("tcp", destAddrPort)
+	dialCtx, cancel := context.WithTimeout(ctx, conn.config.DialTimeout)
+	dest, err := dialAny(dialCtx, addrs)
+	cancel()
 
 	if err != nil {
 
-		log.Printf("%s,Net Dial error:%s \n", traceId, err.Error())
+		util.Errorf("%s,Net Dial error:%s \n", traceId, err.Error())
 
-		rpcMsg = BuildNewChannelRes(message, channel.Id, -1, err.Error())
-		resMsg = BuildMsgOfRpc(rpcMsg)
+		return nil, dialError(err)
+	}
+
+	return dest, nil
+}
 
-		rpcContext.SendMessge(resMsg)
+func BuildNewAssociate(ctx context.Context, rpcContext *Context, associateReq *msg.NewAssociateReq) (*msg.NewAssociateRes, error) {
 
-		return
+	traceId := associateReq.TraceId
+	newCtx := context.WithValue(ctx, "traceId", traceId)
+	defer util.Trace(traceId, "Server BuildNewAssociate")()
+
+	if !rpcContext.conn.Authenticated() {
+		util.Errorf("%s,Refuse new associate request on unauthenticated connection:%s \n", traceId, rpcContext.conn.conn.RemoteAddr())
+
+		return nil, errUnauthenticated
 	}
 
-	// 转发
-	target := NewRemoteConn(dest)
-	target.TraceId = traceId
-	FlowForward(newCtx, channel, target)
+	// 已发送 GOAWAY，不再接受新的请求
+	if rpcContext.conn.Draining() {
+		util.Warnf("%s,Refuse new associate request on draining connection:%s \n", traceId, rpcContext.conn.conn.RemoteAddr())
 
-	rpcMsg = BuildNewChannelRes(message, channel.Id, 1, "success")
-	resMsg = BuildMsgOfRpc(rpcMsg)
-	rpcContext.SendMessge(resMsg)
+		return nil, errGoingAway
+	}
+
+	util.Infof("%s,Receive a new associate request:%+v \n", traceId, associateReq)
+
+	// 监听随机端口，用于与目标之间收发数据包
+	target, err := net.ListenUDP("udp", nil)
+	if err != nil {
+
+		util.Errorf("%s,Listen udp error:%s \n", traceId, err.Error())
+
+		return nil, err
+	}
+
+	datagram := rpcContext.conn.ApplyDatagram()
+	datagram.TraceId = traceId
 
+	// 转发
+	rpcContext.AfterReply(func() {
+		UdpForward(newCtx, datagram, target, rpcContext.conn.config.UdpIdleTimeout)
+	})
+
+	return &msg.NewAssociateRes{AssociateId: datagram.Id}, nil
 }
 
-func Login(ctx context.Context, rpcContext *Context, message *msg.RpcMsg) {
-	defer util.Trace("Server Login", "")()
+func BuildBindChannel(ctx context.Context, rpcContext *Context, bindReq *msg.NewChannelReq) (*msg.NewChannelRes, error) {
+
+	traceId := bindReq.TraceId
+	defer util.Trace(traceId, "Server BuildBindChannel")()
+
+	if !rpcContext.conn.Authenticated() {
+		util.Errorf("%s,Refuse bind request on unauthenticated connection:%s \n", traceId, rpcContext.conn.conn.RemoteAddr())
+
+		return nil, errUnauthenticated
+	}
 
-	log.Printf("receive a login request:%+v\n", message)
+	// 已发送 GOAWAY，不再接受新的请求
+	if rpcContext.conn.Draining() {
+		util.Warnf("%s,Refuse bind request on draining connection:%s \n", traceId, rpcContext.conn.conn.RemoteAddr())
 
-	res := BuildCommonRes(message)
-	resMsg := BuildMsgOfRpc(res)
+		return nil, errGoingAway
+	}
+
+	util.Infof("%s,Receive a bind request:%+v \n", traceId, bindReq)
+
+	// 只接受来自期望对端的连接，未指定对端地址时接受任意对端
+	var peers []net.IP
+	if host, _, err := net.SplitHostPort(bindReq.Addr); err == nil && host != "" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			peers, err = rpcContext.conn.lookupIP(ctx, host)
+			if err != nil {
+
+				util.Errorf("%s,Resolve bind peer %s error:%s \n", traceId, host, err.Error())
+
+				return nil, err
+			}
+		}
+	}
+
+	// 在通往期望对端的本地地址上监听随机端口
+	bindIp := bindLocalIp(rpcContext.conn, bindReq.Addr)
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: bindIp})
+	if err != nil {
 
-	rpcContext.SendMessge(resMsg)
+		util.Errorf("%s,Listen bind addr error:%s \n", traceId, err.Error())
 
+		return nil, err
+	}
+
+	channel := rpcContext.conn.ApplyChannel()
+	channel.TraceId = traceId
+
+	rpcContext.conn.RegBind(channel.Id, &BindListener{Listener: listener, Peers: peers})
+
+	return &msg.NewChannelRes{ChannelId: channel.Id, BindAddr: listener.Addr().String()}, nil
 }
 
-func BuildLoginReq(conn *Connection) *msg.RpcMsg {
-	request := BuildRequestHeader(conn, LoginCmd)
+func AcceptBindChannel(ctx context.Context, rpcContext *Context, acceptReq *msg.BindAcceptReq) (*msg.BindAcceptRes, error) {
+
+	traceId := acceptReq.TraceId
+	newCtx := context.WithValue(ctx, "traceId", traceId)
+	defer util.Trace(traceId, "Server AcceptBindChannel")()
+
+	channel, ok := rpcContext.conn.getChannel(acceptReq.ChannelId)
+	listener, found := rpcContext.conn.TakeBind(acceptReq.ChannelId)
+	if !ok || !found {
+
+		util.Infof("%s,Unknown bind channel:%d \n", traceId, acceptReq.ChannelId)
+
+		if found {
+			listener.Close()
+		}
+
+		return nil, errors.New("unknown bind channel")
+	}
+
+	// 只接受一个来自期望对端的连接，其它连接直接关闭
+	listener.Listener.(*net.TCPListener).SetDeadline(time.Now().Add(rpcContext.conn.config.BindAcceptTimeout))
+
+	var peer net.Conn
+	var err error
+	for {
+		peer, err = listener.Accept()
+		if err != nil || listener.Allowed(peer.RemoteAddr()) {
+			break
+		}
 
-	loginReq := &msg.LoginReq{}
-	loginReq.Name = "Allen"
-	loginReq.Pwd = "Allen"
+		util.Warnf("%s,Bind refuse a conn from unexpected peer:%s \n", traceId, peer.RemoteAddr())
+
+		peer.Close()
+	}
+	listener.Close()
 
-	bLoginReq, err := proto.Marshal(loginReq)
 	if err != nil {
-		panic(err)
+
+		util.Errorf("%s,Bind accept error:%s \n", traceId, err.Error())
+
+		channel.Close()
+
+		return nil, err
 	}
 
-	request.Data = bLoginReq
+	util.Infof("%s,Bind accept a conn:%s \n", traceId, peer.RemoteAddr())
 
-	return request
+	// 转发
+	rpcContext.AfterReply(func() {
+		target := NewRemoteConn(peer)
+		target.TraceId = traceId
+		FlowForward(newCtx, channel, target)
+	})
 
+	return &msg.BindAcceptRes{PeerAddr: peer.RemoteAddr().String()}, nil
 }
 
-func BuildNewChannelReq(conn *Connection, addr string, traceId string) *msg.RpcMsg {
-	request := BuildRequestHeader(conn, BuildChannelCmd)
+// ReverseListen 在服务端监听客户端指定的地址，每个接入的连接通过 ReverseChannelCmd 请求客户端建立通道
+func ReverseListen(ctx context.Context, rpcContext *Context, listenReq *msg.ReverseListenReq) (*msg.ReverseListenRes, error) {
+
+	traceId := listenReq.TraceId
+	newCtx := context.WithValue(ctx, "traceId", traceId)
+	defer util.Trace(traceId, "Server ReverseListen")()
+
+	if !rpcContext.conn.Authenticated() {
+		util.Errorf("%s,Refuse reverse listen request on unauthenticated connection:%s \n", traceId, rpcContext.conn.conn.RemoteAddr())
+
+		return nil, errUnauthenticated
+	}
+
+	// 已发送 GOAWAY，不再接受新的请求
+	if rpcContext.conn.Draining() {
+		util.Warnf("%s,Refuse reverse listen request on draining connection:%s \n", traceId, rpcContext.conn.conn.RemoteAddr())
+
+		return nil, errGoingAway
+	}
+
+	if !rpcContext.conn.config.AllowReverse {
+		util.Errorf("%s,Refuse reverse listen request,reverse forwarding is disabled:%s \n", traceId, rpcContext.conn.conn.RemoteAddr())
+
+		return nil, errors.New("reverse forwarding is disabled")
+	}
+
+	util.Infof("%s,Receive a reverse listen request:%+v \n", traceId, listenReq)
+
+	listenAddr, err := rpcContext.conn.listenAllowed(ctx, listenReq.Addr)
+	if errors.Is(err, errForbidden) {
+		util.Warnf("%s,Refuse reverse listen on %s of user %s by ruleset \n", traceId, listenReq.Addr, rpcContext.conn.User())
+
+		return nil, err
+	}
+
+	if err != nil {
+
+		util.Errorf("%s,Resolve reverse listen addr %s error:%s \n", traceId, listenReq.Addr, err.Error())
 
-	channelReq := &msg.NewChannelReq{}
-	channelReq.Addr = addr
-	channelReq.TraceId = traceId
+		return nil, err
+	}
 
-	bChannelReq, err := proto.Marshal(channelReq)
+	listener, err := net.Listen("tcp", listenAddr)
 	if err != nil {
-		panic(err)
+
+		util.Errorf("%s,Listen reverse addr error:%s \n", traceId, err.Error())
+
+		return nil, err
 	}
 
-	request.Data = bChannelReq
+	listenerId, ok := rpcContext.conn.RegReverse(listener)
+	if !ok {
+		listener.Close()
 
-	return request
+		return nil, ErrConnectionClosed
+	}
+
+	// 对端收到响应、记录监听之后再接受连接
+	rpcContext.AfterReply(func() {
+		go acceptReverse(newCtx, rpcContext.conn, listenerId, listener)
+	})
+
+	return &msg.ReverseListenRes{ListenerId: listenerId, BindAddr: listener.Addr().String()}, nil
+}
+
+// acceptReverse 接受远程端口转发监听上的连接，直到监听随连接关闭
+func acceptReverse(ctx context.Context, conn *Connection, listenerId uint32, listener net.Listener) {
+
+	traceId, _ := ctx.Value("traceId").(string)
+
+	for {
+		peer, err := listener.Accept()
+		if err != nil {
+			util.Infof("%s,Reverse listener %d closed:%s \n", traceId, listenerId, err.Error())
+			return
+		}
+
+		// 已发送 GOAWAY，不再建立新的通道
+		if conn.Draining() {
+			peer.Close()
+			continue
+		}
+
+		go openReverseChannel(ctx, conn, listenerId, peer)
+	}
+}
+
+// openReverseChannel 请求客户端为 peer 建立通道，客户端连接本地服务成功后开始转发
+func openReverseChannel(ctx context.Context, conn *Connection, listenerId uint32, peer net.Conn) {
+
+	channel := conn.ApplyChannel()
+
+	traceId, _ := ctx.Value("traceId").(string)
+	traceId = fmt.Sprintf("%s,reverse:%d", traceId, channel.Id)
+	channel.TraceId = traceId
+
+	defer util.Trace(traceId, "Server openReverseChannel")()
+
+	util.Infof("%s,Reverse listener %d accept a conn:%s \n", traceId, listenerId, peer.RemoteAddr())
+
+	channelReq := &msg.ReverseChannelReq{
+		ListenerId: listenerId,
+		ChannelId:  channel.Id,
+		PeerAddr:   peer.RemoteAddr().String(),
+		TraceId:    traceId,
+	}
 
+	// 客户端在心跳超时时间内没有响应视为失败
+	rpcCtx, cancel := context.WithTimeout(ctx, conn.config.HeartbeatTimeout)
+	defer cancel()
+
+	if _, err := conn.Invoke(rpcCtx, ReverseChannelCmd, channelReq); err != nil {
+		util.Errorf("%s,Reverse channel request fail:%s \n", traceId, err.Error())
+
+		// 客户端可能已在超时后注册通道
+		channel.Reset()
+		peer.Close()
+
+		return
+	}
+
+	target := NewRemoteConn(peer)
+	target.TraceId = traceId
+	FlowForward(context.WithValue(ctx, "traceId", traceId), channel, target)
+}
+
+// bindLocalIp 返回连接期望对端时使用的本地 IP，无法确定时使用隧道连接的本地 IP
+func bindLocalIp(conn *Connection, expectAddr string) net.IP {
+	if host, port, err := net.SplitHostPort(expectAddr); err == nil {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			// UDP 连接不会发送数据，只用于查询路由
+			if probe, err := net.Dial("udp", net.JoinHostPort(host, port)); err == nil {
+				defer probe.Close()
+
+				return probe.LocalAddr().(*net.UDPAddr).IP
+			}
+		}
+	}
+
+	if addr, ok := conn.conn.LocalAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+
+	return nil
+}
+
+func Login(ctx context.Context, rpcContext *Context, loginReq *msg.LoginReq) (*msg.CommonRes, error) {
+	defer util.Trace("Server Login", "")()
+
+	util.Infof("receive a login request:%s,%s\n", loginReq.Name, rpcContext.conn.conn.RemoteAddr())
+
+	if !rpcContext.conn.authenticate(loginReq.Name, loginReq.Pwd) {
+		util.Errorf("login fail:%s,%s\n", loginReq.Name, rpcContext.conn.conn.RemoteAddr())
+
+		return nil, &RemoteError{Code: AuthFailCode, Msg: "invalid name or password"}
+	}
+
+	return &msg.CommonRes{}, nil
 }
 
 func BuildMsgOfRpc(message *msg.RpcMsg) *msg.Msg {
@@ -161,7 +464,7 @@ func BuildMsgOfRpc(message *msg.RpcMsg) *msg.Msg {
 
 	rpcMsg, err := proto.Marshal(message)
 	if err != nil {
-		log.Println("Invlid rpc message!")
+		util.Errorln("Invlid rpc message!")
 		panic(err)
 	}
 
@@ -181,73 +484,117 @@ func BuildMsgOfFlow(data []byte, channelId uint32) *msg.Msg {
 	return msg
 }
 
-func BuildMsgOfPing() *msg.Msg {
+func BuildMsgOfGoAway() *msg.Msg {
 	msg := &msg.Msg{}
 
-	msg.Id = 1
-	msg.Cmd = uint32(PingMsgCmd)
+	msg.Cmd = uint32(GoAwayMsgCmd)
 
 	return msg
 }
 
-func BuildMsgOfPong() *msg.Msg {
+func BuildMsgOfChannelClose(channelId uint32) *msg.Msg {
 	msg := &msg.Msg{}
 
-	msg.Id = 1
-	msg.Cmd = uint32(PongMsgCmd)
+	msg.Id = channelId
+	msg.Cmd = uint32(CloseMsgCmd)
 
 	return msg
 }
 
-func LoginReqCallback(conn *Connection, message *msg.RpcMsg) {
-	res := &msg.CommonRes{}
+func BuildMsgOfChannelReset(channelId uint32) *msg.Msg {
+	msg := &msg.Msg{}
 
-	err := proto.Unmarshal(message.Data, res)
-	if err != nil {
-		log.Println("Invalid callback")
-		return
-	}
+	msg.Id = channelId
+	msg.Cmd = uint32(RstMsgCmd)
 
-	log.Printf("Receive a login response: %v \n", res)
+	return msg
 }
 
-func BuildCommonRes(req *msg.RpcMsg) *msg.RpcMsg {
-	res := BuildResponseHeader(req)
+// BuildMsgOfChannelReject 拒绝对端打开的通道，RST 中携带响应码
+func BuildMsgOfChannelReject(channelId uint32, code int32) *msg.Msg {
+	msg := &msg.Msg{}
+
+	msg.Id = channelId
+	msg.Cmd = uint32(RstMsgCmd)
 
-	commonRes := &msg.CommonRes{}
-	commonRes.Code = 1
-	commonRes.Msg = "success"
+	msg.Data = binary.BigEndian.AppendUint32(nil, uint32(code))
 
-	bCommonRes, err := proto.Marshal(commonRes)
+	return msg
+}
+
+func BuildMsgOfOpen(channelId uint32, channelReq *msg.NewChannelReq) *msg.Msg {
+	msg := &msg.Msg{}
+
+	msg.Id = channelId
+	msg.Cmd = uint32(OpenMsgCmd)
+
+	data, err := proto.Marshal(channelReq)
 	if err != nil {
-		log.Println("Invlid Message")
-		return nil
+		util.Errorln("Invlid open message!")
+		panic(err)
 	}
 
-	res.Data = bCommonRes
+	msg.Data = data
 
-	return res
+	return msg
+}
+
+func BuildMsgOfFin(channelId uint32) *msg.Msg {
+	msg := &msg.Msg{}
+
+	msg.Id = channelId
+	msg.Cmd = uint32(FinMsgCmd)
 
+	return msg
 }
 
-func BuildNewChannelRes(req *msg.RpcMsg, channelId uint32, code int32, resString string) *msg.RpcMsg {
-	res := BuildResponseHeader(req)
+func BuildMsgOfWindowUpdate(channelId uint32, increment uint32) *msg.Msg {
+	msg := &msg.Msg{}
 
-	channelRes := &msg.NewChannelRes{}
-	channelRes.Code = code
-	channelRes.Msg = resString
-	channelRes.ChannelId = channelId
+	msg.Id = channelId
+	msg.Cmd = uint32(WindowUpdateMsgCmd)
 
-	bChannelRes, err := proto.Marshal(channelRes)
-	if err != nil {
-		log.Println("Invlid Message")
-		return nil
-	}
+	msg.Data = binary.BigEndian.AppendUint32(nil, increment)
+
+	return msg
+}
+
+func BuildMsgOfDatagram(data []byte, datagramId uint32) *msg.Msg {
+	msg := &msg.Msg{}
 
-	res.Data = bChannelRes
+	msg.Id = datagramId
+	msg.Cmd = uint32(DatagramMsgCmd)
 
-	return res
+	msg.Data = data
+
+	return msg
+}
+
+func BuildMsgOfDatagramClose(datagramId uint32) *msg.Msg {
+	msg := &msg.Msg{}
+
+	msg.Id = datagramId
+	msg.Cmd = uint32(DatagramCloseMsgCmd)
+
+	return msg
+}
+
+func BuildMsgOfPing() *msg.Msg {
+	msg := &msg.Msg{}
+
+	msg.Id = 1
+	msg.Cmd = uint32(PingMsgCmd)
+
+	return msg
+}
 
+func BuildMsgOfPong() *msg.Msg {
+	msg := &msg.Msg{}
+
+	msg.Id = 1
+	msg.Cmd = uint32(PongMsgCmd)
+
+	return msg
 }
 
 func BuildResponseHeader(req *msg.RpcMsg) *msg.RpcMsg {
@@ -1,8 +1,15 @@
 package network
 
-var Processors map[RpcCmd]RpcProcessor = make(map[RpcCmd]RpcProcessor)
+// DefaultService 返回注册了内置命令（登录、建立通道、UDP 关联、BIND、远程端口转发）的服务
+func DefaultService() *Service {
+	service := NewService()
 
-func init() {
-	Processors[LoginCmd] = Login
-	Processors[BuildChannelCmd] = BuildNewChannel
+	Register(service, LoginCmd, Login)
+	Register(service, BuildChannelCmd, BuildNewChannel)
+	Register(service, BuildAssociateCmd, BuildNewAssociate)
+	Register(service, BindCmd, BuildBindChannel)
+	Register(service, BindAcceptCmd, AcceptBindChannel)
+	Register(service, ReverseListenCmd, ReverseListen)
+
+	return service
 }
@@ -0,0 +1,159 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"sync"
+
+	"github.com/ssp/msg"
+	"github.com/ssp/util"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// rpcHandler 已注册的请求处理函数，返回的响应消息不为空
+type rpcHandler struct {
+	newRes func() proto.Message
+	handle func(ctx context.Context, rpcContext *Context, data []byte) (proto.Message, error)
+}
+
+// Service RPC 命令注册表，绑定到服务端实例，由连接在收到请求时分发
+type Service struct {
+	handlers map[RpcCmd]*rpcHandler
+
+	// 读写锁，控制对 handlers 字段的并发读写
+	mutex sync.RWMutex
+}
+
+func NewService() *Service {
+	return &Service{handlers: map[RpcCmd]*rpcHandler{}}
+}
+
+// Register 注册 cmd 的处理函数，已存在时替换。
+// 请求的解析、响应的序列化及响应码由框架处理：handler 返回 nil 错误时响应码为 SuccessCode，
+// 返回 *RemoteError 时使用其中的响应码，其它错误为 FailCode，响应消息需包含 code、msg 字段
+func Register[Req, Res any, PReq interface {
+	*Req
+	proto.Message
+}, PRes interface {
+	*Res
+	proto.Message
+}](s *Service, cmd RpcCmd, handler func(ctx context.Context, rpcContext *Context, req PReq) (PRes, error)) {
+
+	newRes := func() proto.Message { return PRes(new(Res)) }
+
+	handle := func(ctx context.Context, rpcContext *Context, data []byte) (proto.Message, error) {
+		req := PReq(new(Req))
+		if err := proto.Unmarshal(data, req); err != nil {
+			return newRes(), err
+		}
+
+		res, err := handler(ctx, rpcContext, req)
+		if res == nil || err != nil {
+			return newRes(), err
+		}
+
+		return res, nil
+	}
+
+	s.mutex.Lock()
+	s.handlers[cmd] = &rpcHandler{newRes: newRes, handle: handle}
+	s.mutex.Unlock()
+}
+
+// Unregister 移除 cmd 的处理函数
+func (s *Service) Unregister(cmd RpcCmd) {
+	s.mutex.Lock()
+	delete(s.handlers, cmd)
+	s.mutex.Unlock()
+}
+
+func (s *Service) handler(cmd RpcCmd) (*rpcHandler, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	handler, ok := s.handlers[cmd]
+	return handler, ok
+}
+
+// serve 处理一个请求并发送响应，成功响应发送后执行处理函数注册的 AfterReply
+func (s *Service) serve(ctx context.Context, rpcContext *Context, req *msg.RpcMsg) {
+	cmd := RpcCmd(req.Cmd)
+
+	handler, ok := s.handler(cmd)
+	if !ok {
+		util.Errorf("Unsupported rpc cmd:%d,%s \n", cmd, rpcContext.conn.conn.RemoteAddr())
+
+		// 所有响应消息的 code、msg 字段编号相同，对端可以按任意响应类型解析
+		rpcContext.reply(req, &msg.CommonRes{Code: UnsupportedCode, Msg: "unsupported rpc cmd"})
+		return
+	}
+
+	res, err := handler.call(ctx, rpcContext, req.Data)
+
+	if err != nil {
+		var remote *RemoteError
+		if errors.As(err, &remote) {
+			setResult(res, remote.Code, remote.Msg)
+		} else {
+			setResult(res, FailCode, err.Error())
+		}
+
+		rpcContext.reply(req, res)
+		return
+	}
+
+	if !hasResult(res.ProtoReflect()) {
+		setResult(res, SuccessCode, "success")
+	}
+
+	rpcContext.reply(req, res)
+
+	// 处理函数自行设置了失败的响应码时同样不是成功响应
+	if coded, ok := res.(codedRes); ok && coded.GetCode() != SuccessCode {
+		return
+	}
+
+	for _, f := range rpcContext.afterReply {
+		f()
+	}
+}
+
+// call 调用处理函数，处理函数 panic 时返回 FailCode
+func (h *rpcHandler) call(ctx context.Context, rpcContext *Context, data []byte) (res proto.Message, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			util.Errorf("Rpc handler panic:%v\n%s", r, debug.Stack())
+
+			res, err = h.newRes(), errors.New("internal error")
+		}
+	}()
+
+	return h.handle(ctx, rpcContext, data)
+}
+
+// hasResult 响应消息是否已由处理函数设置响应码
+func hasResult(res protoreflect.Message) bool {
+	field := res.Descriptor().Fields().ByName("code")
+
+	return field != nil && res.Has(field)
+}
+
+// setResult 设置响应消息的 code、msg 字段，msg 已设置时保留，没有对应字段时忽略
+func setResult(res proto.Message, code int32, text string) {
+	message := res.ProtoReflect()
+	fields := message.Descriptor().Fields()
+
+	if field := fields.ByName("code"); field != nil && field.Kind() == protoreflect.Int32Kind {
+		message.Set(field, protoreflect.ValueOfInt32(code))
+	}
+
+	if field := fields.ByName("msg"); field != nil && field.Kind() == protoreflect.StringKind && !message.Has(field) {
+		message.Set(field, protoreflect.ValueOfString(text))
+	}
+}
@@ -0,0 +1,239 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ssp/msg"
+)
+
+// servicePair 通过 net.Pipe 连接的客户端、服务端 Connection，服务端使用 service 处理请求
+func servicePair(t *testing.T, service *Service) (*Connection, *Connection) {
+	t.Helper()
+
+	a, b := net.Pipe()
+	client, server := NewConnection(a), NewConnection(b)
+	server.SetService(service)
+
+	for _, conn := range []*Connection{client, server} {
+		go conn.Read()
+		go conn.Write()
+	}
+
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	return client, server
+}
+
+func TestServiceServe(t *testing.T) {
+	const testCmd RpcCmd = 100
+
+	tests := []struct {
+		name    string
+		handler func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error)
+		cmd     RpcCmd
+
+		wantCode       int32
+		wantMsg        string
+		wantAfterReply bool
+	}{
+		{
+			name: "success",
+			handler: func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+				return &msg.CommonRes{}, nil
+			},
+			wantCode:       SuccessCode,
+			wantMsg:        "success",
+			wantAfterReply: true,
+		},
+		{
+			name: "nil response",
+			handler: func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+				return nil, nil
+			},
+			wantCode:       SuccessCode,
+			wantMsg:        "success",
+			wantAfterReply: true,
+		},
+		{
+			name: "request data",
+			handler: func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+				return &msg.CommonRes{Msg: "hello " + req.Name}, nil
+			},
+			wantCode:       SuccessCode,
+			wantMsg:        "hello allen",
+			wantAfterReply: true,
+		},
+		{
+			name: "code set by handler",
+			handler: func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+				return &msg.CommonRes{Code: AuthFailCode, Msg: "bad password"}, nil
+			},
+			wantCode: AuthFailCode,
+			wantMsg:  "bad password",
+		},
+		{
+			name: "remote error",
+			handler: func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+				return nil, &RemoteError{Code: ForbiddenCode, Msg: "forbidden"}
+			},
+			wantCode: ForbiddenCode,
+			wantMsg:  "forbidden",
+		},
+		{
+			name: "wrapped remote error",
+			handler: func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+				return nil, fmt.Errorf("dial: %w", &RemoteError{Code: ConnRefusedCode, Msg: "connection refused"})
+			},
+			wantCode: ConnRefusedCode,
+			wantMsg:  "connection refused",
+		},
+		{
+			name: "plain error",
+			handler: func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+				return nil, errors.New("boom")
+			},
+			wantCode: FailCode,
+			wantMsg:  "boom",
+		},
+		{
+			name: "response discarded on error",
+			handler: func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+				return &msg.CommonRes{Code: SuccessCode, Msg: "partial"}, errors.New("boom")
+			},
+			wantCode: FailCode,
+			wantMsg:  "boom",
+		},
+		{
+			name: "panic",
+			handler: func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+				panic("handler bug")
+			},
+			wantCode: FailCode,
+			wantMsg:  "internal error",
+		},
+		{
+			name:     "unregistered cmd",
+			cmd:      testCmd + 1,
+			wantCode: UnsupportedCode,
+			wantMsg:  "unsupported rpc cmd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			afterReply := make(chan struct{})
+
+			service := NewService()
+			if tt.handler != nil {
+				Register(service, testCmd, func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+					rpcContext.AfterReply(func() { close(afterReply) })
+					return tt.handler(ctx, rpcContext, req)
+				})
+			}
+
+			client, _ := servicePair(t, service)
+
+			cmd := tt.cmd
+			if cmd == 0 {
+				cmd = testCmd
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			res, err := Call[msg.CommonRes](ctx, client, cmd, &msg.LoginReq{Name: "allen"})
+			if res == nil {
+				t.Fatalf("Call() error = %v", err)
+			}
+			if res.Code != tt.wantCode || res.Msg != tt.wantMsg {
+				t.Errorf("Call() = %d %q, want %d %q", res.Code, res.Msg, tt.wantCode, tt.wantMsg)
+			}
+
+			var remote *RemoteError
+			if tt.wantCode == SuccessCode {
+				if err != nil {
+					t.Errorf("Call() error = %v, want nil", err)
+				}
+			} else if !errors.As(err, &remote) || remote.Code != tt.wantCode {
+				t.Errorf("Call() error = %v, want *RemoteError with code %d", err, tt.wantCode)
+			}
+
+			// AfterReply 只在成功响应发送后由处理请求的协程执行
+			select {
+			case <-afterReply:
+				if !tt.wantAfterReply {
+					t.Error("AfterReply ran")
+				}
+			case <-time.After(100 * time.Millisecond):
+				if tt.wantAfterReply {
+					t.Error("AfterReply did not run")
+				}
+			}
+		})
+	}
+}
+
+func TestServiceUnregister(t *testing.T) {
+	const testCmd RpcCmd = 100
+
+	service := NewService()
+	Register(service, testCmd, func(ctx context.Context, rpcContext *Context, req *msg.LoginReq) (*msg.CommonRes, error) {
+		return &msg.CommonRes{}, nil
+	})
+	service.Unregister(testCmd)
+
+	client, _ := servicePair(t, service)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := Call[msg.CommonRes](ctx, client, testCmd, &msg.LoginReq{})
+
+	var remote *RemoteError
+	if !errors.As(err, &remote) || remote.Code != UnsupportedCode {
+		t.Errorf("Call() error = %v, want UnsupportedCode", err)
+	}
+}
+
+func TestSetResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		res      *msg.CommonRes
+		code     int32
+		text     string
+		wantCode int32
+		wantMsg  string
+	}{
+		{"empty", &msg.CommonRes{}, FailCode, "boom", FailCode, "boom"},
+		{"msg kept", &msg.CommonRes{Msg: "custom"}, SuccessCode, "success", SuccessCode, "custom"},
+		{"code replaced", &msg.CommonRes{Code: SuccessCode}, ForbiddenCode, "forbidden", ForbiddenCode, "forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setResult(tt.res, tt.code, tt.text)
+			if tt.res.Code != tt.wantCode || tt.res.Msg != tt.wantMsg {
+				t.Errorf("setResult() = %d %q, want %d %q", tt.res.Code, tt.res.Msg, tt.wantCode, tt.wantMsg)
+			}
+		})
+	}
+
+	// 没有 code、msg 字段的消息不修改
+	req := &msg.LoginReq{Name: "allen"}
+	setResult(req, FailCode, "boom")
+	if req.Name != "allen" || req.Pwd != "" {
+		t.Errorf("setResult() modified %v", req)
+	}
+
+	if hasResult(req.ProtoReflect()) {
+		t.Error("hasResult() = true for a message without code")
+	}
+}
@@ -0,0 +1,175 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ssp/util"
+)
+
+// ParseUdpHeader 解析 SOCKS5 UDP 请求头，返回目标地址及数据
+func ParseUdpHeader(packet []byte) (string, []byte, error) {
+	// RSV(2) FRAG(1) ATYP(1)
+	if len(packet) < 4 {
+		return "", nil, errors.New("short udp packet")
+	}
+
+	if packet[2] != 0 {
+		return "", nil, errors.New("udp fragment not supported")
+	}
+
+	var host string
+	pos := 4
+
+	switch packet[3] {
+	case 1:
+		if len(packet) < pos+4+2 {
+			return "", nil, errors.New("invalid IPv4")
+		}
+		host = net.IP(packet[pos : pos+4]).String()
+		pos += 4
+
+	case 3:
+		if len(packet) < pos+1 {
+			return "", nil, errors.New("invalid hostname")
+		}
+		addrLen := int(packet[pos])
+		pos++
+		if len(packet) < pos+addrLen+2 {
+			return "", nil, errors.New("invalid hostname")
+		}
+		host = string(packet[pos : pos+addrLen])
+		pos += addrLen
+
+	case 4:
+		if len(packet) < pos+16+2 {
+			return "", nil, errors.New("invalid IPv6")
+		}
+		host = net.IP(packet[pos : pos+16]).String()
+		pos += 16
+
+	default:
+		return "", nil, errors.New("invalid atyp")
+	}
+
+	port := binary.BigEndian.Uint16(packet[pos : pos+2])
+	pos += 2
+
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), packet[pos:], nil
+}
+
+// BuildUdpHeader 构造带 SOCKS5 UDP 头的数据包
+func BuildUdpHeader(addr *net.UDPAddr, data []byte) []byte {
+	packet := make([]byte, 0, 4+16+2+len(data))
+	packet = append(packet, 0x00, 0x00, 0x00)
+
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		packet = append(packet, 0x01)
+		packet = append(packet, ip4...)
+	} else {
+		packet = append(packet, 0x04)
+		packet = append(packet, addr.IP.To16()...)
+	}
+
+	packet = binary.BigEndian.AppendUint16(packet, uint16(addr.Port))
+
+	return append(packet, data...)
+}
+
+// UdpForward 在关联与 UDP socket 之间转发数据包，空闲超时或任意一端关闭时释放资源
+func UdpForward(ctx context.Context, datagram *Datagram, target *net.UDPConn, idleTimeout time.Duration) {
+
+	traceId, _ := ctx.Value("traceId").(string)
+
+	var once sync.Once
+	var lastActive time.Time
+	var activeMutex sync.Mutex
+
+	touch := func() {
+		activeMutex.Lock()
+		lastActive = time.Now()
+		activeMutex.Unlock()
+	}
+
+	idle := func() bool {
+		activeMutex.Lock()
+		defer activeMutex.Unlock()
+
+		return time.Since(lastActive) >= idleTimeout
+	}
+
+	release := func() {
+		once.Do(func() {
+			target.Close()
+			datagram.Close()
+		})
+	}
+
+	touch()
+
+	dg2connForward := func() {
+		defer util.Trace(traceId, "dg2connForward")()
+		defer release()
+
+		for packet := range datagram.ReadBuff {
+			addr, data, err := ParseUdpHeader(packet)
+			if err != nil {
+				util.Errorf("%s,Drop udp packet:%s\n", traceId, err.Error())
+				continue
+			}
+
+			destAddr, err := datagram.UnderlyingConn.resolveUDPAddr(ctx, addr)
+			if err != nil {
+				util.Errorf("%s,Resolve udp addr %s error:%s\n", traceId, addr, err.Error())
+				continue
+			}
+
+			if host, _, _ := net.SplitHostPort(addr); !datagram.UnderlyingConn.allow(host, destAddr.IP, destAddr.Port) {
+				util.Warnf("%s,Drop udp packet to %s by ruleset\n", traceId, addr)
+				continue
+			}
+
+			touch()
+			if _, err := target.WriteToUDP(data, destAddr); err != nil {
+				util.Errorf("%s,Write udp packet to %s error:%s\n", traceId, addr, err.Error())
+			}
+		}
+	}
+
+	conn2dgForward := func() {
+		defer util.Trace(traceId, "conn2dgForward")()
+		defer release()
+
+		buf := make([]byte, 64*1024)
+		for {
+			target.SetReadDeadline(time.Now().Add(idleTimeout))
+
+			n, srcAddr, err := target.ReadFromUDP(buf)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					if idle() {
+						util.Errorf("%s,Udp associate %d idle timeout\n", traceId, datagram.Id)
+						return
+					}
+					continue
+				}
+
+				util.Errorf("%s,Read udp packet error:%s\n", traceId, err.Error())
+				return
+			}
+
+			touch()
+			if _, err := datagram.Write(BuildUdpHeader(srcAddr, buf[:n])); err != nil {
+				return
+			}
+		}
+	}
+
+	go dg2connForward()
+	go conn2dgForward()
+}
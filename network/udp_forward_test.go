@@ -0,0 +1,84 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestParseUdpHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		packet   []byte
+		wantAddr string
+		wantData []byte
+		wantErr  bool
+	}{
+		{"ipv4", []byte{0, 0, 0, 1, 192, 0, 2, 1, 0x00, 0x35, 'h', 'i'}, "192.0.2.1:53", []byte("hi"), false},
+		{"ipv6", append([]byte{0, 0, 0, 4}, append(net.ParseIP("2001:db8::1"), 0x01, 0xbb, 'h', 'i')...), "[2001:db8::1]:443", []byte("hi"), false},
+		{"domain", append([]byte{0, 0, 0, 3, 11}, append([]byte("example.com"), 0x00, 0x50, 'h', 'i')...), "example.com:80", []byte("hi"), false},
+		{"empty data", []byte{0, 0, 0, 1, 192, 0, 2, 1, 0x00, 0x35}, "192.0.2.1:53", []byte{}, false},
+		{"reserved bytes ignored", []byte{0xff, 0xff, 0, 1, 192, 0, 2, 1, 0x00, 0x35}, "192.0.2.1:53", []byte{}, false},
+
+		{"empty", nil, "", nil, true},
+		{"short header", []byte{0, 0, 0}, "", nil, true},
+		{"fragment", []byte{0, 0, 1, 1, 192, 0, 2, 1, 0x00, 0x35, 'h', 'i'}, "", nil, true},
+		{"unknown atyp", []byte{0, 0, 0, 2, 192, 0, 2, 1, 0x00, 0x35}, "", nil, true},
+		{"truncated ipv4", []byte{0, 0, 0, 1, 192, 0, 2, 1, 0x00}, "", nil, true},
+		{"truncated ipv6", append([]byte{0, 0, 0, 4}, net.ParseIP("2001:db8::1")[:15]...), "", nil, true},
+		{"missing domain length", []byte{0, 0, 0, 3}, "", nil, true},
+		{"truncated domain", append([]byte{0, 0, 0, 3, 11}, "example"...), "", nil, true},
+		{"domain without port", append([]byte{0, 0, 0, 3, 11}, "example.com"...), "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, data, err := ParseUdpHeader(tt.packet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseUdpHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if addr != tt.wantAddr {
+				t.Errorf("ParseUdpHeader() addr = %q, want %q", addr, tt.wantAddr)
+			}
+			if !bytes.Equal(data, tt.wantData) {
+				t.Errorf("ParseUdpHeader() data = %q, want %q", data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestBuildUdpHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		addr *net.UDPAddr
+		want []byte
+	}{
+		{"ipv4", &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53}, []byte{0, 0, 0, 1, 192, 0, 2, 1, 0x00, 0x35}},
+		{"ipv4 in 4 bytes", &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1).To4(), Port: 53}, []byte{0, 0, 0, 1, 192, 0, 2, 1, 0x00, 0x35}},
+		{"ipv4-mapped ipv6", &net.UDPAddr{IP: net.ParseIP("::ffff:192.0.2.1"), Port: 53}, []byte{0, 0, 0, 1, 192, 0, 2, 1, 0x00, 0x35}},
+		{"ipv6", &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}, append(append([]byte{0, 0, 0, 4}, net.ParseIP("2001:db8::1")...), 0x01, 0xbb)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte("payload")
+
+			packet := BuildUdpHeader(tt.addr, data)
+			if want := append(tt.want, data...); !bytes.Equal(packet, want) {
+				t.Fatalf("BuildUdpHeader() = %x, want %x", packet, want)
+			}
+
+			// 构造的数据包可以解析回原地址及数据
+			addr, got, err := ParseUdpHeader(packet)
+			if err != nil {
+				t.Fatalf("ParseUdpHeader() error = %v", err)
+			}
+			if want := (&net.UDPAddr{IP: tt.addr.IP, Port: tt.addr.Port}).String(); addr != want {
+				t.Errorf("ParseUdpHeader() addr = %q, want %q", addr, want)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("ParseUdpHeader() data = %q, want %q", got, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Action 规则匹配时的动作
+type Action int
+
+const (
+	Deny  Action = 0
+	Allow Action = 1
+)
+
+func ParseAction(s string) (Action, error) {
+	switch s {
+	case "allow":
+		return Allow, nil
+	case "deny":
+		return Deny, nil
+	}
+
+	return Deny, fmt.Errorf("unknown action %q", s)
+}
+
+// PortRange 端口范围，包含两端
+type PortRange struct {
+	From int
+	To   int
+}
+
+// ParsePortRange 解析 443 或 8000-8100 格式的端口范围
+func ParsePortRange(s string) (PortRange, error) {
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		to = from
+	}
+
+	start, err := strconv.Atoi(from)
+	if err != nil || start < 0 || start > 65535 {
+		return PortRange{}, fmt.Errorf("invalid port %q", s)
+	}
+
+	end, err := strconv.Atoi(to)
+	if err != nil || end < start || end > 65535 {
+		return PortRange{}, fmt.Errorf("invalid port %q", s)
+	}
+
+	return PortRange{From: start, To: end}, nil
+}
+
+// Rule 目标访问控制规则，用户、目标、端口条件同时满足时匹配；
+// CIDRs 与 Domains 任一匹配即满足目标条件，条件为空时匹配所有
+type Rule struct {
+	Action Action
+
+	Users []string
+
+	CIDRs []*net.IPNet
+
+	// 域名通配，如 *.example.com，只匹配请求中的域名
+	Domains []string
+
+	Ports []PortRange
+}
+
+// ParseRule 由配置中的字符串构造规则
+func ParseRule(action string, users []string, cidrs []string, domains []string, ports []string) (Rule, error) {
+	var errs []error
+
+	rule := Rule{Users: users}
+
+	var err error
+	if rule.Action, err = ParseAction(action); err != nil {
+		errs = append(errs, fmt.Errorf("action: %w", err))
+	}
+
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cidrs: %w", err))
+			continue
+		}
+		rule.CIDRs = append(rule.CIDRs, ipNet)
+	}
+
+	for _, domain := range domains {
+		domain = normalizeDomain(domain)
+		if _, err := path.Match(domain, ""); err != nil {
+			errs = append(errs, fmt.Errorf("domains: invalid pattern %q", domain))
+			continue
+		}
+		rule.Domains = append(rule.Domains, domain)
+	}
+
+	for _, port := range ports {
+		portRange, err := ParsePortRange(port)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ports: %w", err))
+			continue
+		}
+		rule.Ports = append(rule.Ports, portRange)
+	}
+
+	return rule, errors.Join(errs...)
+}
+
+func (r *Rule) match(user string, host string, ip net.IP, port int) bool {
+	return r.matchUser(user) && r.matchTarget(host, ip) && r.matchPort(port)
+}
+
+func (r *Rule) matchUser(user string) bool {
+	if len(r.Users) == 0 {
+		return true
+	}
+
+	for _, u := range r.Users {
+		if u == user {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Rule) matchTarget(host string, ip net.IP) bool {
+	if len(r.CIDRs) == 0 && len(r.Domains) == 0 {
+		return true
+	}
+
+	for _, ipNet := range r.CIDRs {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	// 请求中为 IP 时不匹配域名规则
+	if net.ParseIP(host) != nil {
+		return false
+	}
+
+	host = normalizeDomain(host)
+	for _, domain := range r.Domains {
+		if ok, _ := path.Match(domain, host); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Rule) matchPort(port int) bool {
+	if len(r.Ports) == 0 {
+		return true
+	}
+
+	for _, portRange := range r.Ports {
+		if port >= portRange.From && port <= portRange.To {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(domain), ".")
+}
+
+// 运营商级 NAT 地址
+var _, sharedAddressSpace, _ = net.ParseCIDR("100.64.0.0/10")
+
+// internalIP 回环、私有、链路本地（含云元数据地址 169.254.169.254）及未指定地址
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// ACL 按顺序匹配规则，第一条匹配的规则决定是否允许，没有规则匹配时由 fallback 决定
+type ACL struct {
+	sync.RWMutex
+
+	rules []Rule
+
+	fallback func(ip net.IP, port int) bool
+}
+
+// NewACL 目标访问控制，没有规则匹配时拒绝内部地址，允许其它地址
+func NewACL(rules []Rule) *ACL {
+	return &ACL{rules: rules, fallback: func(ip net.IP, port int) bool { return !internalIP(ip) }}
+}
+
+// NewListenACL 远程端口转发监听地址的访问控制，没有规则匹配时只允许在回环地址上监听非特权端口，
+// 监听所有地址需要规则允许 0.0.0.0
+func NewListenACL(rules []Rule) *ACL {
+	return &ACL{rules: rules, fallback: func(ip net.IP, port int) bool {
+		return ip.IsLoopback() && (port == 0 || port >= 1024)
+	}}
+}
+
+// SetRules 替换规则，对之后的请求生效
+func (a *ACL) SetRules(rules []Rule) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.rules = rules
+}
+
+func (a *ACL) Allow(user string, host string, ip net.IP, port int) bool {
+	a.RLock()
+	defer a.RUnlock()
+
+	for i := range a.rules {
+		if a.rules[i].match(user, host, ip, port) {
+			return a.rules[i].Action == Allow
+		}
+	}
+
+	return a.fallback(ip, port)
+}
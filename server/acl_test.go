@@ -0,0 +1,166 @@
+package server
+
+import (
+	"net"
+	"testing"
+)
+
+func mustRule(t *testing.T, action string, users []string, cidrs []string, domains []string, ports []string) Rule {
+	t.Helper()
+
+	rule, err := ParseRule(action, users, cidrs, domains, ports)
+	if err != nil {
+		t.Fatalf("ParseRule() error = %v", err)
+	}
+	return rule
+}
+
+func TestListenACL(t *testing.T) {
+	rules := []Rule{
+		mustRule(t, "allow", []string{"allen"}, []string{"0.0.0.0/32"}, nil, []string{"8000-8100"}),
+		mustRule(t, "deny", nil, []string{"127.0.0.0/8"}, nil, []string{"9000"}),
+	}
+
+	tests := []struct {
+		name  string
+		rules []Rule
+		user  string
+		ip    string
+		port  int
+		want  bool
+	}{
+		{"loopback by default", nil, "allen", "127.0.0.1", 8080, true},
+		{"ipv6 loopback by default", nil, "allen", "::1", 8080, true},
+		{"random port on loopback by default", nil, "allen", "127.0.0.1", 0, true},
+		{"privileged port denied by default", nil, "allen", "127.0.0.1", 80, false},
+		{"all interfaces denied by default", nil, "allen", "0.0.0.0", 8080, false},
+		{"ipv6 all interfaces denied by default", nil, "allen", "::", 8080, false},
+		{"public address denied by default", nil, "allen", "203.0.113.7", 8080, false},
+		{"all interfaces allowed by rule", rules, "allen", "0.0.0.0", 8080, true},
+		{"all interfaces for other user", rules, "bob", "0.0.0.0", 8080, false},
+		{"port outside rule", rules, "allen", "0.0.0.0", 8200, false},
+		{"loopback port denied by rule", rules, "allen", "127.0.0.1", 9000, false},
+		{"loopback falls back to default", rules, "bob", "127.0.0.1", 9001, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acl := NewListenACL(tt.rules)
+			if got := acl.Allow(tt.user, "", net.ParseIP(tt.ip), tt.port); got != tt.want {
+				t.Errorf("Allow(%q, %s, %d) = %v, want %v", tt.user, tt.ip, tt.port, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACLDefaultDeny(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want bool
+	}{
+		{"public ipv4", "203.0.113.7", true},
+		{"public ipv6", "2001:db8::1", true},
+		{"loopback", "127.0.0.1", false},
+		{"loopback range", "127.1.2.3", false},
+		{"ipv6 loopback", "::1", false},
+		{"private 10/8", "10.1.2.3", false},
+		{"private 172.16/12", "172.31.255.255", false},
+		{"outside 172.16/12", "172.32.0.1", true},
+		{"private 192.168/16", "192.168.1.1", false},
+		{"ipv6 unique local", "fd00::1", false},
+		{"cloud metadata", "169.254.169.254", false},
+		{"ipv6 link local", "fe80::1", false},
+		{"carrier grade nat", "100.64.0.1", false},
+		{"outside carrier grade nat", "100.128.0.1", true},
+		{"unspecified", "0.0.0.0", false},
+		{"ipv6 unspecified", "::", false},
+		{"ipv4-mapped loopback", "::ffff:127.0.0.1", false},
+	}
+
+	acl := NewACL(nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acl.Allow("allen", "", net.ParseIP(tt.ip), 443); got != tt.want {
+				t.Errorf("Allow(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACLRules(t *testing.T) {
+	rules := []Rule{
+		mustRule(t, "deny", nil, nil, []string{"*.internal"}, nil),
+		mustRule(t, "allow", []string{"allen"}, []string{"10.0.0.0/8"}, nil, []string{"5432", "8000-8100"}),
+		mustRule(t, "allow", nil, nil, []string{"Intranet.Example.com."}, nil),
+		mustRule(t, "deny", nil, nil, nil, []string{"25"}),
+	}
+
+	tests := []struct {
+		name string
+		user string
+		host string
+		ip   string
+		port int
+		want bool
+	}{
+		{"allowed user and cidr", "allen", "10.1.2.3", "10.1.2.3", 5432, true},
+		{"allowed port range", "allen", "10.1.2.3", "10.1.2.3", 8100, true},
+		{"port outside rule", "allen", "10.1.2.3", "10.1.2.3", 22, false},
+		{"other user", "bob", "10.1.2.3", "10.1.2.3", 5432, false},
+		{"domain resolved to allowed cidr", "allen", "db.corp", "10.1.2.3", 5432, true},
+		{"denied domain before allowed cidr", "allen", "db.internal", "10.1.2.3", 5432, false},
+		{"denied domain to public ip", "allen", "api.internal", "203.0.113.7", 443, false},
+		{"domain rule is case insensitive", "bob", "INTRANET.example.com", "192.168.1.1", 80, true},
+		{"domain rule does not match ip host", "bob", "192.168.1.1", "192.168.1.1", 80, false},
+		{"denied port", "bob", "203.0.113.7", "203.0.113.7", 25, false},
+		{"public ip falls back to allow", "bob", "203.0.113.7", "203.0.113.7", 443, true},
+		{"domain to internal ip falls back to deny", "bob", "rebind.example.org", "127.0.0.1", 443, false},
+	}
+
+	acl := NewACL(rules)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acl.Allow(tt.user, tt.host, net.ParseIP(tt.ip), tt.port); got != tt.want {
+				t.Errorf("Allow(%q, %s, %s, %d) = %v, want %v", tt.user, tt.host, tt.ip, tt.port, got, tt.want)
+			}
+		})
+	}
+
+	acl.SetRules(nil)
+	if acl.Allow("allen", "10.1.2.3", net.ParseIP("10.1.2.3"), 5432) {
+		t.Error("Allow() kept the rules replaced by SetRules")
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		cidrs   []string
+		domains []string
+		ports   []string
+		wantErr bool
+	}{
+		{"valid", "allow", []string{"10.0.0.0/8", "::1/128"}, []string{"*.example.com"}, []string{"443", "8000-8100"}, false},
+		{"empty conditions", "deny", nil, nil, nil, false},
+		{"unknown action", "reject", nil, nil, nil, true},
+		{"invalid cidr", "allow", []string{"10.0.0.0"}, nil, nil, true},
+		{"invalid domain pattern", "allow", nil, []string{"[example.com"}, nil, true},
+		{"invalid port", "allow", nil, nil, []string{"https"}, true},
+		{"port out of range", "allow", nil, nil, []string{"65536"}, true},
+		{"reversed port range", "allow", nil, nil, []string{"8100-8000"}, true},
+		{"negative port", "allow", nil, nil, []string{"-1"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRule(tt.action, nil, tt.cidrs, tt.domains, tt.ports)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DNS 记录类型
+const (
+	dnsTypeA    uint16 = 1
+	dnsTypeSOA  uint16 = 6
+	dnsTypeAAAA uint16 = 28
+	dnsTypeOPT  uint16 = 41
+
+	dnsClassIN uint16 = 1
+)
+
+// DNS 响应码
+const (
+	dnsRcodeSuccess  = 0
+	dnsRcodeNXDomain = 3
+)
+
+// EDNS0 声明的 UDP 报文大小，避免 IP 分片
+const dnsUdpSize = 1232
+
+var (
+	errDnsMessage   = errors.New("invalid dns message")
+	errDnsTruncated = errors.New("dns message truncated")
+	errDnsQuestion  = errors.New("dns question mismatch")
+)
+
+// buildDNSQuery 构造递归查询报文，附带 EDNS0 OPT 记录
+func buildDNSQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	query := make([]byte, 12, 64)
+	binary.BigEndian.PutUint16(query[0:], id)
+	binary.BigEndian.PutUint16(query[2:], 0x0100)
+	binary.BigEndian.PutUint16(query[4:], 1)
+	binary.BigEndian.PutUint16(query[10:], 1)
+
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return nil, fmt.Errorf("invalid domain name %q", name)
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("invalid domain name %q", name)
+		}
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	query = append(query, 0)
+
+	query = binary.BigEndian.AppendUint16(query, qtype)
+	query = binary.BigEndian.AppendUint16(query, dnsClassIN)
+
+	// OPT 记录：根域名、类型、UDP 报文大小、扩展响应码及标志、数据长度
+	query = append(query, 0)
+	query = binary.BigEndian.AppendUint16(query, dnsTypeOPT)
+	query = binary.BigEndian.AppendUint16(query, dnsUdpSize)
+	query = binary.BigEndian.AppendUint32(query, 0)
+	query = binary.BigEndian.AppendUint16(query, 0)
+
+	return query, nil
+}
+
+// dnsTruncated 响应是否被截断，需要改用 TCP 查询
+func dnsTruncated(res []byte) bool {
+	return len(res) >= 3 && res[2]&0x02 != 0
+}
+
+// parseDNSResponse 解析响应中 qtype 类型的地址，返回地址、缓存时间及响应码；
+// 响应中的问题必须与查询的 name、qtype 一致，防止其他域名的应答进入缓存；
+// 没有地址时缓存时间取自授权段的 SOA 记录
+func parseDNSResponse(res []byte, id uint16, name string, qtype uint16) ([]net.IP, uint32, int, error) {
+	if len(res) < 12 || binary.BigEndian.Uint16(res[0:]) != id || res[2]&0x80 == 0 {
+		return nil, 0, 0, errDnsMessage
+	}
+
+	if dnsTruncated(res) {
+		return nil, 0, 0, errDnsTruncated
+	}
+
+	rcode := int(res[3] & 0x0f)
+	qdCount := int(binary.BigEndian.Uint16(res[4:]))
+	anCount := int(binary.BigEndian.Uint16(res[6:]))
+	nsCount := int(binary.BigEndian.Uint16(res[8:]))
+
+	if qdCount != 1 {
+		return nil, 0, 0, errDnsQuestion
+	}
+
+	offset, err := matchDNSQuestion(res, 12, name, qtype)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	var ips []net.IP
+	var ttl uint32
+	var negativeTtl uint32
+
+	for i := 0; i < anCount+nsCount; i++ {
+		if offset, err = skipDNSName(res, offset); err != nil {
+			return nil, 0, 0, err
+		}
+
+		if offset+10 > len(res) {
+			return nil, 0, 0, errDnsMessage
+		}
+
+		rrType := binary.BigEndian.Uint16(res[offset:])
+		rrClass := binary.BigEndian.Uint16(res[offset+2:])
+		rrTtl := binary.BigEndian.Uint32(res[offset+4:])
+		rdLen := int(binary.BigEndian.Uint16(res[offset+8:]))
+		offset += 10
+
+		if offset+rdLen > len(res) {
+			return nil, 0, 0, errDnsMessage
+		}
+		rdata := res[offset : offset+rdLen]
+
+		switch {
+		case i < anCount && rrClass == dnsClassIN && rrType == qtype:
+			if (qtype == dnsTypeA && rdLen != net.IPv4len) || (qtype == dnsTypeAAAA && rdLen != net.IPv6len) {
+				return nil, 0, 0, errDnsMessage
+			}
+
+			ips = append(ips, net.IP(append([]byte(nil), rdata...)))
+			if len(ips) == 1 || rrTtl < ttl {
+				ttl = rrTtl
+			}
+
+		case i >= anCount && rrType == dnsTypeSOA:
+			// 否定应答的缓存时间为 SOA 记录 TTL 与 MINIMUM 字段中较小的值
+			if minimum, ok := soaMinimum(res, offset); ok {
+				negativeTtl = rrTtl
+				if minimum < negativeTtl {
+					negativeTtl = minimum
+				}
+			}
+		}
+
+		offset += rdLen
+	}
+
+	if len(ips) == 0 {
+		ttl = negativeTtl
+	}
+
+	return ips, ttl, rcode, nil
+}
+
+// matchDNSQuestion 校验 offset 处的问题与查询一致，返回问题之后的偏移；
+// 问题是报文中的第一个域名，不会使用压缩指针，域名不区分大小写
+func matchDNSQuestion(res []byte, offset int, name string, qtype uint16) (int, error) {
+	var labels []string
+
+	for {
+		if offset >= len(res) {
+			return 0, errDnsMessage
+		}
+
+		length := int(res[offset])
+		offset++
+
+		if length == 0 {
+			break
+		}
+		if length&0xc0 != 0 {
+			return 0, errDnsQuestion
+		}
+		if offset+length > len(res) {
+			return 0, errDnsMessage
+		}
+
+		labels = append(labels, string(res[offset:offset+length]))
+		offset += length
+	}
+
+	if offset+4 > len(res) {
+		return 0, errDnsMessage
+	}
+
+	if !strings.EqualFold(strings.Join(labels, "."), strings.TrimSuffix(name, ".")) ||
+		binary.BigEndian.Uint16(res[offset:]) != qtype || binary.BigEndian.Uint16(res[offset+2:]) != dnsClassIN {
+		return 0, errDnsQuestion
+	}
+
+	return offset + 4, nil
+}
+
+// soaMinimum 读取 SOA 记录最后的 MINIMUM 字段
+func soaMinimum(res []byte, offset int) (uint32, bool) {
+	var err error
+
+	// MNAME、RNAME 之后依次为 SERIAL、REFRESH、RETRY、EXPIRE、MINIMUM
+	for i := 0; i < 2; i++ {
+		if offset, err = skipDNSName(res, offset); err != nil {
+			return 0, false
+		}
+	}
+
+	if offset+20 > len(res) {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint32(res[offset+16:]), true
+}
+
+// skipDNSName 跳过 offset 处的域名，返回之后的偏移，压缩指针之后的内容不需要读取
+func skipDNSName(res []byte, offset int) (int, error) {
+	for offset < len(res) {
+		length := int(res[offset])
+
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xc0 == 0xc0:
+			if offset+2 > len(res) {
+				return 0, errDnsMessage
+			}
+			return offset + 2, nil
+		case length&0xc0 != 0:
+			return 0, errDnsMessage
+		}
+
+		offset += 1 + length
+	}
+
+	return 0, errDnsMessage
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// dnsRR 测试响应中的资源记录，名称为指向问题的压缩指针
+type dnsRR struct {
+	typ   uint16
+	ttl   uint32
+	rdata []byte
+}
+
+// dnsResponse 构造 example.com 的响应报文，flags 为响应标志及响应码
+func dnsResponse(id uint16, flags uint16, qtype uint16, answers []dnsRR, authority []dnsRR) []byte {
+	res := binary.BigEndian.AppendUint16(nil, id)
+	res = binary.BigEndian.AppendUint16(res, flags)
+	res = binary.BigEndian.AppendUint16(res, 1)
+	res = binary.BigEndian.AppendUint16(res, uint16(len(answers)))
+	res = binary.BigEndian.AppendUint16(res, uint16(len(authority)))
+	res = binary.BigEndian.AppendUint16(res, 0)
+
+	res = append(res, "\x07example\x03com\x00"...)
+	res = binary.BigEndian.AppendUint16(res, qtype)
+	res = binary.BigEndian.AppendUint16(res, dnsClassIN)
+
+	for _, rr := range append(answers, authority...) {
+		res = append(res, 0xc0, 0x0c)
+		res = binary.BigEndian.AppendUint16(res, rr.typ)
+		res = binary.BigEndian.AppendUint16(res, dnsClassIN)
+		res = binary.BigEndian.AppendUint32(res, rr.ttl)
+		res = binary.BigEndian.AppendUint16(res, uint16(len(rr.rdata)))
+		res = append(res, rr.rdata...)
+	}
+
+	return res
+}
+
+// soaRdata SOA 记录数据，MNAME 使用压缩指针，RNAME 为根域名
+func soaRdata(minimum uint32) []byte {
+	rdata := []byte{2, 'n', 's', 0xc0, 0x0c, 0}
+	for _, v := range []uint32{2024010101, 7200, 3600, 1209600, minimum} {
+		rdata = binary.BigEndian.AppendUint32(rdata, v)
+	}
+	return rdata
+}
+
+const (
+	dnsFlagsSuccess  = 0x8180
+	dnsFlagsNXDomain = 0x8183
+)
+
+func TestParseDNSResponse(t *testing.T) {
+	ipv4 := dnsRR{dnsTypeA, 300, net.ParseIP("192.0.2.1").To4()}
+	ipv4Short := dnsRR{dnsTypeA, 60, net.ParseIP("192.0.2.2").To4()}
+	ipv6 := dnsRR{dnsTypeAAAA, 120, net.ParseIP("2001:db8::1")}
+	cname := dnsRR{5, 30, []byte{3, 'w', 'w', 'w', 0xc0, 0x0c}}
+
+	tests := []struct {
+		name      string
+		res       []byte
+		qtype     uint16
+		wantIPs   []string
+		wantTtl   uint32
+		wantRcode int
+		wantErr   error
+	}{
+		{"a records use min ttl", dnsResponse(1, dnsFlagsSuccess, dnsTypeA, []dnsRR{ipv4, ipv4Short}, nil), dnsTypeA, []string{"192.0.2.1", "192.0.2.2"}, 60, 0, nil},
+		{"aaaa record", dnsResponse(1, dnsFlagsSuccess, dnsTypeAAAA, []dnsRR{ipv6}, nil), dnsTypeAAAA, []string{"2001:db8::1"}, 120, 0, nil},
+		{"cname ignored", dnsResponse(1, dnsFlagsSuccess, dnsTypeA, []dnsRR{cname, ipv4}, nil), dnsTypeA, []string{"192.0.2.1"}, 300, 0, nil},
+		{"other type ignored", dnsResponse(1, dnsFlagsSuccess, dnsTypeA, []dnsRR{ipv6}, nil), dnsTypeA, nil, 0, 0, nil},
+		{"nxdomain uses soa minimum", dnsResponse(1, dnsFlagsNXDomain, dnsTypeA, nil, []dnsRR{{dnsTypeSOA, 3600, soaRdata(300)}}), dnsTypeA, nil, 300, dnsRcodeNXDomain, nil},
+		{"nodata uses soa ttl", dnsResponse(1, dnsFlagsSuccess, dnsTypeAAAA, nil, []dnsRR{{dnsTypeSOA, 60, soaRdata(300)}}), dnsTypeAAAA, nil, 60, 0, nil},
+		{"soa ignored with answers", dnsResponse(1, dnsFlagsSuccess, dnsTypeA, []dnsRR{ipv4}, []dnsRR{{dnsTypeSOA, 10, soaRdata(10)}}), dnsTypeA, []string{"192.0.2.1"}, 300, 0, nil},
+		{"truncated soa not cached", dnsResponse(1, dnsFlagsNXDomain, dnsTypeA, nil, []dnsRR{{dnsTypeSOA, 3600, soaRdata(300)[:20]}}), dnsTypeA, nil, 0, dnsRcodeNXDomain, nil},
+		{"server failure", dnsResponse(1, 0x8182, dnsTypeA, nil, nil), dnsTypeA, nil, 0, 2, nil},
+
+		{"wrong id", dnsResponse(2, dnsFlagsSuccess, dnsTypeA, []dnsRR{ipv4}, nil), dnsTypeA, nil, 0, 0, errDnsMessage},
+		{"not a response", dnsResponse(1, 0x0100, dnsTypeA, []dnsRR{ipv4}, nil), dnsTypeA, nil, 0, 0, errDnsMessage},
+		{"short header", []byte{0, 1, 0x81, 0x80}, dnsTypeA, nil, 0, 0, errDnsMessage},
+		{"truncated", dnsResponse(1, 0x8380, dnsTypeA, nil, nil), dnsTypeA, nil, 0, 0, errDnsTruncated},
+		{"a record size", dnsResponse(1, dnsFlagsSuccess, dnsTypeA, []dnsRR{{dnsTypeA, 300, net.ParseIP("192.0.2.1")}}, nil), dnsTypeA, nil, 0, 0, errDnsMessage},
+		{"aaaa record size", dnsResponse(1, dnsFlagsSuccess, dnsTypeAAAA, []dnsRR{{dnsTypeAAAA, 300, net.ParseIP("192.0.2.1").To4()}}, nil), dnsTypeAAAA, nil, 0, 0, errDnsMessage},
+		{"rdata beyond end", dnsResponse(1, dnsFlagsSuccess, dnsTypeA, []dnsRR{ipv4}, nil)[:43], dnsTypeA, nil, 0, 0, errDnsMessage},
+		{"record header beyond end", dnsResponse(1, dnsFlagsSuccess, dnsTypeA, []dnsRR{ipv4}, nil)[:35], dnsTypeA, nil, 0, 0, errDnsMessage},
+		{"question beyond end", dnsResponse(1, dnsFlagsSuccess, dnsTypeA, nil, nil)[:20], dnsTypeA, nil, 0, 0, errDnsMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, ttl, rcode, err := parseDNSResponse(tt.res, 1, "example.com", tt.qtype)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseDNSResponse() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			var got []string
+			for _, ip := range ips {
+				got = append(got, ip.String())
+			}
+			if !reflect.DeepEqual(got, tt.wantIPs) {
+				t.Errorf("parseDNSResponse() ips = %v, want %v", got, tt.wantIPs)
+			}
+			if ttl != tt.wantTtl {
+				t.Errorf("parseDNSResponse() ttl = %d, want %d", ttl, tt.wantTtl)
+			}
+			if rcode != tt.wantRcode {
+				t.Errorf("parseDNSResponse() rcode = %d, want %d", rcode, tt.wantRcode)
+			}
+		})
+	}
+}
+
+func TestParseDNSResponseQuestion(t *testing.T) {
+	ipv4 := dnsRR{dnsTypeA, 300, net.ParseIP("192.0.2.1").To4()}
+	res := dnsResponse(1, dnsFlagsSuccess, dnsTypeA, []dnsRR{ipv4}, nil)
+
+	// modify 复制响应并修改其中的字节
+	modify := func(offset int, b ...byte) []byte {
+		m := append([]byte(nil), res...)
+		copy(m[offset:], b)
+		return m
+	}
+
+	compressed := append(append([]byte(nil), res[:12]...), 0xc0, 0x0c, 0, byte(dnsTypeA), 0, byte(dnsClassIN))
+
+	tests := []struct {
+		name    string
+		res     []byte
+		qname   string
+		qtype   uint16
+		wantErr error
+	}{
+		{"same name", res, "example.com", dnsTypeA, nil},
+		{"case insensitive", res, "EXAMPLE.com.", dnsTypeA, nil},
+
+		{"other name", res, "example.org", dnsTypeA, errDnsQuestion},
+		{"subdomain", res, "www.example.com", dnsTypeA, errDnsQuestion},
+		{"parent domain", res, "com", dnsTypeA, errDnsQuestion},
+		{"other qtype", res, "example.com", dnsTypeAAAA, errDnsQuestion},
+		{"other class", modify(27, 0, 3), "example.com", dnsTypeA, errDnsQuestion},
+		{"no question", modify(4, 0, 0), "example.com", dnsTypeA, errDnsQuestion},
+		{"two questions", modify(4, 0, 2), "example.com", dnsTypeA, errDnsQuestion},
+		{"compressed question", compressed, "example.com", dnsTypeA, errDnsQuestion},
+		{"question type beyond end", res[:27], "example.com", dnsTypeA, errDnsMessage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, _, _, err := parseDNSResponse(tt.res, 1, tt.qname, tt.qtype)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseDNSResponse() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && len(ips) != 1 {
+				t.Errorf("parseDNSResponse() ips = %v", ips)
+			}
+		})
+	}
+}
+
+func TestSkipDNSName(t *testing.T) {
+	tests := []struct {
+		name    string
+		buf     []byte
+		offset  int
+		want    int
+		wantErr bool
+	}{
+		{"root", []byte{0}, 0, 1, false},
+		{"labels", []byte("\x03www\x07example\x03com\x00"), 0, 17, false},
+		{"pointer", []byte{0xc0, 0x0c}, 0, 2, false},
+		{"labels then pointer", []byte{1, 'a', 0xc0, 0x0c, 0xff}, 0, 4, false},
+		{"at offset", []byte{0xff, 0xff, 1, 'a', 0}, 2, 5, false},
+
+		{"empty", nil, 0, 0, true},
+		{"truncated pointer", []byte{1, 'a', 0xc0}, 0, 0, true},
+		{"reserved label type 0x40", []byte{0x40, 0}, 0, 0, true},
+		{"reserved label type 0x80", []byte{0x80, 0}, 0, 0, true},
+		{"unterminated", []byte("\x03abc"), 0, 0, true},
+		{"label beyond end", []byte{5, 'a'}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := skipDNSName(tt.buf, tt.offset)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("skipDNSName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("skipDNSName() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildDNSQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		domain  string
+		wantErr bool
+	}{
+		{"domain", "example.com", false},
+		{"trailing dot", "example.com.", false},
+		{"empty", "", true},
+		{"root", ".", true},
+		{"empty label", "example..com", true},
+		{"label too long", string(make([]byte, 64)) + ".com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := buildDNSQuery(0x1234, tt.domain, dnsTypeAAAA)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildDNSQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want := []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 1}
+			want = append(want, "\x07example\x03com\x00\x00\x1c\x00\x01"...)
+			want = append(want, 0, 0, 41, 0x04, 0xd0, 0, 0, 0, 0, 0, 0)
+			if !reflect.DeepEqual(query, want) {
+				t.Errorf("buildDNSQuery() = %x, want %x", query, want)
+			}
+		})
+	}
+}
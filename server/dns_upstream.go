@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dnsUpstream DNS 上游服务器，exchange 发送查询报文并返回响应报文
+type dnsUpstream interface {
+	exchange(ctx context.Context, query []byte) ([]byte, error)
+	String() string
+}
+
+// parseDNSUpstream 解析上游服务器地址：
+// 8.8.8.8、udp://8.8.8.8:53、tcp://8.8.8.8:53、tls://1.1.1.1:853（DNS over TLS）、
+// https://dns.google/dns-query（DNS over HTTPS），未指定端口时使用协议的默认端口
+func parseDNSUpstream(s string) (dnsUpstream, error) {
+	if !strings.Contains(s, "://") {
+		s = "udp://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid dns upstream %q", s)
+	}
+
+	withPort := func(port string) string {
+		if u.Port() != "" {
+			return u.Host
+		}
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+
+	switch u.Scheme {
+	case "udp":
+		return &udpUpstream{addr: withPort("53")}, nil
+	case "tcp":
+		return &tcpUpstream{addr: withPort("53")}, nil
+	case "tls":
+		return &tlsUpstream{addr: withPort("853"), config: &tls.Config{ServerName: u.Hostname()}}, nil
+	case "https":
+		return &httpsUpstream{url: u.String(), client: &http.Client{}}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported dns upstream scheme %q", u.Scheme)
+}
+
+type udpUpstream struct {
+	addr string
+}
+
+func (u *udpUpstream) String() string {
+	return "udp://" + u.addr
+}
+
+// exchange 响应被截断时改用 TCP 重新查询
+func (u *udpUpstream) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", u.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	setDeadline(ctx, conn)
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		// 忽略 ID 不匹配的报文
+		if n < 2 || !bytes.Equal(buf[:2], query[:2]) {
+			continue
+		}
+
+		if dnsTruncated(buf[:n]) {
+			return (&tcpUpstream{addr: u.addr}).exchange(ctx, query)
+		}
+
+		return buf[:n], nil
+	}
+}
+
+type tcpUpstream struct {
+	addr string
+}
+
+func (u *tcpUpstream) String() string {
+	return "tcp://" + u.addr
+}
+
+func (u *tcpUpstream) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", u.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return exchangeStream(ctx, conn, query)
+}
+
+type tlsUpstream struct {
+	addr   string
+	config *tls.Config
+}
+
+func (u *tlsUpstream) String() string {
+	return "tls://" + u.addr
+}
+
+func (u *tlsUpstream) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	dialer := tls.Dialer{Config: u.config}
+	conn, err := dialer.DialContext(ctx, "tcp", u.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return exchangeStream(ctx, conn, query)
+}
+
+// exchangeStream TCP 及 TLS 上的报文前有两字节的长度
+func exchangeStream(ctx context.Context, conn net.Conn, query []byte) ([]byte, error) {
+	setDeadline(ctx, conn)
+
+	req := binary.BigEndian.AppendUint16(make([]byte, 0, len(query)+2), uint16(len(query)))
+	if _, err := conn.Write(append(req, query...)); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+
+	res := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type httpsUpstream struct {
+	url    string
+	client *http.Client
+}
+
+func (u *httpsUpstream) String() string {
+	return u.url
+}
+
+// exchange 按 RFC 8484 以 POST 发送查询报文
+func (u *httpsUpstream) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("dns over https: " + res.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, 64*1024))
+}
+
+func setDeadline(ctx context.Context, conn net.Conn) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(defaultDnsTimeout))
+	}
+}
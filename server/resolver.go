@@ -0,0 +1,398 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ssp/util"
+)
+
+// IPPreference 解析结果的地址族偏好
+type IPPreference int
+
+const (
+	// IPv4 地址在前
+	PreferIPv4 IPPreference = 0
+
+	// IPv6 地址在前
+	PreferIPv6 IPPreference = 1
+
+	// 只使用 IPv4 地址
+	IPv4Only IPPreference = 2
+
+	// 只使用 IPv6 地址
+	IPv6Only IPPreference = 3
+)
+
+func ParseIPPreference(s string) (IPPreference, error) {
+	switch s {
+	case "ipv4":
+		return PreferIPv4, nil
+	case "ipv6":
+		return PreferIPv6, nil
+	case "ipv4-only":
+		return IPv4Only, nil
+	case "ipv6-only":
+		return IPv6Only, nil
+	}
+
+	return PreferIPv4, fmt.Errorf("unknown ip preference %q", s)
+}
+
+const (
+	defaultDnsTimeout   = 5 * time.Second
+	defaultDnsCacheSize = 4096
+
+	// 缓存时间上限（秒），限制错误或伪造的应答在缓存中停留的时间
+	dnsMaxCacheTtl = 3600
+)
+
+type dnsCacheKey struct {
+	name  string
+	qtype uint16
+}
+
+type dnsCacheEntry struct {
+	ips    []net.IP
+	expire time.Time
+}
+
+// Resolver 目标域名解析：优先使用 hosts 文件，其次依次查询上游服务器，
+// 没有上游服务器时使用系统解析；上游的应答按 TTL 缓存，不存在的域名按 SOA 记录缓存，
+// 缓存时间不超过 dnsMaxCacheTtl
+type Resolver struct {
+	// hosts 文件，格式同 /etc/hosts，为空时不使用
+	HostsPath string
+
+	Prefer IPPreference
+
+	// 每个上游服务器的查询超时时间
+	Timeout time.Duration
+
+	// 缓存的最大条目数，为 0 时不缓存
+	CacheSize int
+
+	upstreams []dnsUpstream
+
+	// 域名 -> 地址
+	hosts map[string][]net.IP
+
+	// 保护 hosts
+	hostsMutex sync.RWMutex
+
+	cache map[dnsCacheKey]dnsCacheEntry
+
+	// 保护 cache
+	cacheMutex sync.Mutex
+}
+
+// NewResolver upstreams 格式见 parseDNSUpstream
+func NewResolver(upstreams []string, hostsPath string) (*Resolver, error) {
+	r := &Resolver{
+		HostsPath: hostsPath,
+		Timeout:   defaultDnsTimeout,
+		CacheSize: defaultDnsCacheSize,
+		cache:     map[dnsCacheKey]dnsCacheEntry{},
+	}
+
+	var errs []error
+	for i, s := range upstreams {
+		upstream, err := parseDNSUpstream(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("upstreams[%d]: %w", i, err))
+			continue
+		}
+		r.upstreams = append(r.upstreams, upstream)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := r.ReloadHosts(); err != nil {
+		return nil, fmt.Errorf("hosts: %w", err)
+	}
+
+	return r, nil
+}
+
+// ReloadHosts 重新读取 hosts 文件，读取失败时保留原有记录
+func (r *Resolver) ReloadHosts() error {
+	hosts := map[string][]net.IP{}
+
+	if r.HostsPath != "" {
+		file, err := os.Open(r.HostsPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		lineNo := 0
+		for scanner.Scan() {
+			lineNo++
+
+			line, _, _ := strings.Cut(scanner.Text(), "#")
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+
+			ip := net.ParseIP(fields[0])
+			if ip == nil || len(fields) < 2 {
+				return fmt.Errorf("%s:%d: expected ip name...", r.HostsPath, lineNo)
+			}
+
+			for _, name := range fields[1:] {
+				name = normalizeDomain(name)
+				hosts[name] = append(hosts[name], ip)
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+
+		util.Infof("Load %d hosts from %s\n", len(hosts), r.HostsPath)
+	}
+
+	r.hostsMutex.Lock()
+	r.hosts = hosts
+	r.hostsMutex.Unlock()
+
+	return nil
+}
+
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	name := normalizeDomain(host)
+
+	r.hostsMutex.RLock()
+	ips, ok := r.hosts[name]
+	r.hostsMutex.RUnlock()
+
+	if ok {
+		return r.notEmpty(r.order(ips), host)
+	}
+
+	// 系统解析不返回 TTL，不缓存其结果；系统通常已有缓存（nscd、systemd-resolved 等），
+	// 按固定时间缓存会使记录在 TTL 过期后仍被使用
+	if len(r.upstreams) == 0 {
+		network := "ip"
+		switch r.Prefer {
+		case IPv4Only:
+			network = "ip4"
+		case IPv6Only:
+			network = "ip6"
+		}
+
+		ips, err := net.DefaultResolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+
+		return r.notEmpty(r.order(ips), host)
+	}
+
+	// 按偏好并发查询 A、AAAA 记录，偏好的记录在前
+	var qtypes []uint16
+	switch r.Prefer {
+	case PreferIPv4:
+		qtypes = []uint16{dnsTypeA, dnsTypeAAAA}
+	case PreferIPv6:
+		qtypes = []uint16{dnsTypeAAAA, dnsTypeA}
+	case IPv4Only:
+		qtypes = []uint16{dnsTypeA}
+	case IPv6Only:
+		qtypes = []uint16{dnsTypeAAAA}
+	}
+
+	type result struct {
+		ips []net.IP
+		err error
+	}
+
+	results := make([]result, len(qtypes))
+
+	var wg sync.WaitGroup
+	for i, qtype := range qtypes {
+		wg.Add(1)
+		go func(i int, qtype uint16) {
+			defer wg.Done()
+
+			ips, err := r.query(ctx, name, qtype)
+			results[i] = result{ips, err}
+		}(i, qtype)
+	}
+	wg.Wait()
+
+	var all []net.IP
+	var lastErr error
+	for _, result := range results {
+		all = append(all, result.ips...)
+		if result.err != nil {
+			lastErr = result.err
+		}
+	}
+
+	// 一种记录查询失败时使用另一种记录的结果
+	if len(all) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	return r.notEmpty(all, host)
+}
+
+// order 按偏好排列地址，只使用一种地址族时过滤另一种
+func (r *Resolver) order(ips []net.IP) []net.IP {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+
+	switch r.Prefer {
+	case PreferIPv6:
+		return append(v6, v4...)
+	case IPv4Only:
+		return v4
+	case IPv6Only:
+		return v6
+	}
+
+	return append(v4, v6...)
+}
+
+func (r *Resolver) notEmpty(ips []net.IP, host string) ([]net.IP, error) {
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return ips, nil
+}
+
+// query 查询一种记录，优先使用缓存，依次尝试各上游服务器；域名不存在时返回空
+func (r *Resolver) query(ctx context.Context, name string, qtype uint16) ([]net.IP, error) {
+	key := dnsCacheKey{name: name, qtype: qtype}
+
+	if ips, ok := r.cached(key); ok {
+		return ips, nil
+	}
+
+	var lastErr error
+	for _, upstream := range r.upstreams {
+		ips, ttl, err := r.exchange(ctx, upstream, name, qtype)
+		if err != nil {
+			util.Warnf("Dns query %s type %d from %s error:%s\n", name, qtype, upstream, err.Error())
+
+			var netErr net.Error
+			lastErr = &net.DNSError{
+				Err:       err.Error(),
+				Name:      name,
+				Server:    upstream.String(),
+				IsTimeout: (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, context.DeadlineExceeded),
+			}
+			continue
+		}
+
+		r.store(key, ips, ttl)
+
+		return ips, nil
+	}
+
+	return nil, lastErr
+}
+
+func (r *Resolver) exchange(ctx context.Context, upstream dnsUpstream, name string, qtype uint16) ([]net.IP, uint32, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	// 随机的报文 ID，防止伪造的应答
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(b[:])
+
+	query, err := buildDNSQuery(id, name, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res, err := upstream.exchange(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ips, ttl, rcode, err := parseDNSResponse(res, id, name, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if rcode != dnsRcodeSuccess && rcode != dnsRcodeNXDomain {
+		return nil, 0, fmt.Errorf("dns response code %d", rcode)
+	}
+
+	return ips, ttl, nil
+}
+
+func (r *Resolver) cached(key dnsCacheKey) ([]net.IP, bool) {
+	r.cacheMutex.Lock()
+	defer r.cacheMutex.Unlock()
+
+	entry, ok := r.cache[key]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expire) {
+		delete(r.cache, key)
+		return nil, false
+	}
+
+	return entry.ips, true
+}
+
+// store 缓存应答，TTL 为 0 时不缓存，超过 dnsMaxCacheTtl 时按上限缓存；
+// 缓存已满时先清理过期条目，仍然已满时随机淘汰一条
+func (r *Resolver) store(key dnsCacheKey, ips []net.IP, ttl uint32) {
+	if ttl == 0 || r.CacheSize <= 0 {
+		return
+	}
+
+	if ttl > dnsMaxCacheTtl {
+		ttl = dnsMaxCacheTtl
+	}
+
+	r.cacheMutex.Lock()
+	defer r.cacheMutex.Unlock()
+
+	if len(r.cache) >= r.CacheSize {
+		now := time.Now()
+		for k, entry := range r.cache {
+			if now.After(entry.expire) {
+				delete(r.cache, k)
+			}
+		}
+	}
+
+	if len(r.cache) >= r.CacheSize {
+		for k := range r.cache {
+			delete(r.cache, k)
+			break
+		}
+	}
+
+	r.cache[key] = dnsCacheEntry{ips: ips, expire: time.Now().Add(time.Duration(ttl) * time.Second)}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeUpstream 按查询类型返回固定的应答，记录每种类型的查询次数
+type fakeUpstream struct {
+	sync.Mutex
+
+	// 查询类型 -> 应答，响应报文中的 ID 由 exchange 替换为查询 ID
+	responses map[uint16][]byte
+
+	err error
+
+	queries map[uint16]int
+}
+
+func (u *fakeUpstream) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	offset, err := skipDNSName(query, 12)
+	if err != nil {
+		return nil, err
+	}
+	qtype := binary.BigEndian.Uint16(query[offset:])
+
+	u.Lock()
+	defer u.Unlock()
+
+	if u.queries == nil {
+		u.queries = map[uint16]int{}
+	}
+	u.queries[qtype]++
+
+	if u.err != nil {
+		return nil, u.err
+	}
+
+	res := append([]byte(nil), u.responses[qtype]...)
+	copy(res, query[:2])
+	return res, nil
+}
+
+func (u *fakeUpstream) String() string {
+	return "fake"
+}
+
+func (u *fakeUpstream) count(qtype uint16) int {
+	u.Lock()
+	defer u.Unlock()
+
+	return u.queries[qtype]
+}
+
+func TestResolverCache(t *testing.T) {
+	a := func(ttl uint32) []byte {
+		return dnsResponse(0, dnsFlagsSuccess, dnsTypeA, []dnsRR{{dnsTypeA, ttl, net.ParseIP("192.0.2.1").To4()}}, nil)
+	}
+	nxdomain := func(minimum uint32) []byte {
+		return dnsResponse(0, dnsFlagsNXDomain, dnsTypeA, nil, []dnsRR{{dnsTypeSOA, 3600, soaRdata(minimum)}})
+	}
+
+	tests := []struct {
+		name        string
+		response    []byte
+		err         error
+		cacheSize   int
+		wantQueries int
+		wantTtl     time.Duration
+		wantErr     bool
+	}{
+		{"cached by ttl", a(300), nil, 16, 1, 300 * time.Second, false},
+		{"zero ttl not cached", a(0), nil, 16, 2, 0, false},
+		{"ttl capped", a(7 * 24 * 3600), nil, 16, 1, dnsMaxCacheTtl * time.Second, false},
+		{"cache disabled", a(300), nil, 0, 2, 0, false},
+		{"nxdomain cached by soa", nxdomain(60), nil, 16, 1, 60 * time.Second, true},
+		{"nxdomain without soa minimum", nxdomain(0), nil, 16, 2, 0, true},
+		{"nxdomain ttl capped", nxdomain(7 * 24 * 3600), nil, 16, 1, dnsMaxCacheTtl * time.Second, true},
+		{"upstream error not cached", nil, errors.New("refused"), 16, 2, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &fakeUpstream{responses: map[uint16][]byte{dnsTypeA: tt.response}, err: tt.err}
+
+			r := &Resolver{
+				Prefer:    IPv4Only,
+				Timeout:   time.Second,
+				CacheSize: tt.cacheSize,
+				upstreams: []dnsUpstream{upstream},
+				cache:     map[dnsCacheKey]dnsCacheEntry{},
+			}
+
+			for i := 0; i < 2; i++ {
+				ips, err := r.LookupIP(context.Background(), "Example.COM.")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("LookupIP() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && (len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1"))) {
+					t.Fatalf("LookupIP() = %v", ips)
+				}
+			}
+
+			if got := upstream.count(dnsTypeA); got != tt.wantQueries {
+				t.Errorf("upstream queries = %d, want %d", got, tt.wantQueries)
+			}
+
+			entry, ok := r.cache[dnsCacheKey{name: "example.com", qtype: dnsTypeA}]
+			if ok != (tt.wantTtl > 0) {
+				t.Fatalf("cached = %v, want %v", ok, tt.wantTtl > 0)
+			}
+			if ok {
+				if remaining := time.Until(entry.expire); remaining > tt.wantTtl || remaining < tt.wantTtl-5*time.Second {
+					t.Errorf("cache expires in %s, want %s", remaining, tt.wantTtl)
+				}
+			}
+		})
+	}
+}
+
+// 应答的问题与查询不一致时不使用、不缓存
+func TestResolverQuestionMismatch(t *testing.T) {
+	upstream := &fakeUpstream{responses: map[uint16][]byte{
+		dnsTypeA: dnsResponse(0, dnsFlagsSuccess, dnsTypeA, []dnsRR{{dnsTypeA, 300, net.ParseIP("192.0.2.1").To4()}}, nil),
+	}}
+
+	r := &Resolver{
+		Prefer:    IPv4Only,
+		Timeout:   time.Second,
+		CacheSize: 16,
+		upstreams: []dnsUpstream{upstream},
+		cache:     map[dnsCacheKey]dnsCacheEntry{},
+	}
+
+	if ips, err := r.LookupIP(context.Background(), "www.example.com"); err == nil {
+		t.Fatalf("LookupIP() = %v, want error", ips)
+	}
+	if len(r.cache) != 0 {
+		t.Errorf("cache = %v, want empty", r.cache)
+	}
+}
+
+func TestResolverCacheExpire(t *testing.T) {
+	upstream := &fakeUpstream{responses: map[uint16][]byte{
+		dnsTypeA: dnsResponse(0, dnsFlagsSuccess, dnsTypeA, []dnsRR{{dnsTypeA, 300, net.ParseIP("192.0.2.1").To4()}}, nil),
+	}}
+
+	r := &Resolver{
+		Prefer:    IPv4Only,
+		Timeout:   time.Second,
+		CacheSize: 16,
+		upstreams: []dnsUpstream{upstream},
+		cache:     map[dnsCacheKey]dnsCacheEntry{},
+	}
+
+	key := dnsCacheKey{name: "example.com", qtype: dnsTypeA}
+	r.cache[key] = dnsCacheEntry{ips: []net.IP{net.ParseIP("192.0.2.9")}, expire: time.Now().Add(-time.Second)}
+
+	ips, err := r.LookupIP(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("LookupIP() error = %v", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("LookupIP() = %v, want the upstream answer after expiry", ips)
+	}
+	if got := upstream.count(dnsTypeA); got != 1 {
+		t.Errorf("upstream queries = %d, want 1", got)
+	}
+}
+
+func TestResolverStore(t *testing.T) {
+	key := func(name string) dnsCacheKey {
+		return dnsCacheKey{name: name, qtype: dnsTypeA}
+	}
+	ips := []net.IP{net.ParseIP("192.0.2.1")}
+
+	tests := []struct {
+		name      string
+		expired   []string
+		valid     []string
+		store     string
+		wantKept  []string
+		wantCount int
+	}{
+		{"room left", nil, []string{"a"}, "b", []string{"a", "b"}, 2},
+		{"expired entries removed first", []string{"a"}, []string{"b"}, "c", []string{"b", "c"}, 2},
+		{"evict one when full", nil, []string{"a", "b"}, "c", []string{"c"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Resolver{CacheSize: 2, cache: map[dnsCacheKey]dnsCacheEntry{}}
+
+			for _, name := range tt.expired {
+				r.cache[key(name)] = dnsCacheEntry{ips: ips, expire: time.Now().Add(-time.Second)}
+			}
+			for _, name := range tt.valid {
+				r.cache[key(name)] = dnsCacheEntry{ips: ips, expire: time.Now().Add(time.Minute)}
+			}
+
+			r.store(key(tt.store), ips, 60)
+
+			if len(r.cache) != tt.wantCount {
+				t.Errorf("cache size = %d, want %d", len(r.cache), tt.wantCount)
+			}
+			for _, name := range tt.wantKept {
+				if _, ok := r.cached(key(name)); !ok {
+					t.Errorf("%s not cached", name)
+				}
+			}
+		})
+	}
+}
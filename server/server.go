@@ -1,11 +1,13 @@
 package server
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"crypto/tls"
 	"net"
+	"sync"
 
 	"github.com/ssp/network"
+	"github.com/ssp/util"
 )
 
 type ServerFlag int
@@ -18,24 +20,71 @@ const (
 
 type Server struct {
 	Flag ServerFlag
-	Port int
+
+	// 监听地址
+	Addr string
+
+	// 与客户端连接的参数
+	ConnConfig network.ConnectionConfig
+
+	// 登录校验，为空时拒绝所有登录
+	Authenticator network.Authenticator
+
+	// 不为空时使用 TLS
+	TLS *tls.Config
+
+	// 不为空时使用预共享密钥加密与客户端之间的数据
+	Cipher *network.Cipher
+
+	// 目标访问控制，默认拒绝内部地址，为空时允许所有目标
+	AccessPolicy network.AccessPolicy
+
+	// 远程端口转发监听地址的访问控制，默认只允许在回环地址上监听非特权端口
+	ListenPolicy network.AccessPolicy
+
+	// 目标域名解析，为空时使用系统解析
+	Resolver network.Resolver
+
+	// 处理客户端请求的 RPC 命令，默认为内置命令，可通过 network.Register 添加自定义命令
+	Service *network.Service
+
+	listener net.Listener
+
+	// 保护 listener、connections 及 Flag
+	mutex sync.Mutex
+
+	// 当前所有客户端连接
+	connections map[*network.Connection]struct{}
 }
 
-func New(port int) *Server {
-	return &Server{Init, port}
+func New(addr string) *Server {
+	return &Server{
+		Flag:         Init,
+		Addr:         addr,
+		ConnConfig:   network.DefaultConnectionConfig(),
+		Service:      network.DefaultService(),
+		AccessPolicy: NewACL(nil),
+		ListenPolicy: NewListenACL(nil),
+		connections:  map[*network.Connection]struct{}{},
+	}
 }
 
 func (s *Server) Start() {
 
-	addr := fmt.Sprintf("%s:%d", "localhost", s.Port)
+	addr := s.Addr
 
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
-		log.Printf("Server %s start fail...\n", addr)
+		util.Errorf("Server %s start fail...\n", addr)
 		panic(err)
 	}
 
-	log.Printf("Server %s start successfuly...\n", addr)
+	util.Infof("Server %s start successfuly...\n", addr)
+
+	s.mutex.Lock()
+	s.listener = listener
+	s.Flag = Ready
+	s.mutex.Unlock()
 
 	go s.Accept(listener)
 
@@ -45,14 +94,124 @@ func (s *Server) Accept(listener net.Listener) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if s.shuttingDown() {
+				return
+			}
 			continue
 		}
-		log.Printf("New conn:%s \n", conn.RemoteAddr())
+		util.Infof("New conn:%s \n", conn.RemoteAddr())
+
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	if s.TLS != nil {
+		tlsConn := tls.Server(conn, s.TLS)
+
+		// 握手失败（证书不被允许等）时直接关闭连接
+		ctx, cancel := context.WithTimeout(context.Background(), s.ConnConfig.HeartbeatTimeout)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+
+		if err != nil {
+			util.Errorf("Tls handshake with %s fail:%s \n", conn.RemoteAddr(), err.Error())
+			conn.Close()
+			return
+		}
+
+		conn = tlsConn
+	}
+
+	if s.Cipher != nil {
+		conn = s.Cipher.Wrap(conn)
+	}
+
+	connection := network.NewConnectionWithConfig(conn, s.ConnConfig)
+	connection.SetAuthenticator(s.Authenticator)
+	connection.SetService(s.Service)
+	connection.SetAccessPolicy(s.AccessPolicy)
+	connection.SetListenPolicy(s.ListenPolicy)
+	connection.SetResolver(s.Resolver)
+
+	if !s.track(connection) {
+		connection.Close()
+		return
+	}
+
+	go connection.Read()
+	go connection.Write()
+	go connection.Timeout()
+}
+
+// track 记录连接，连接关闭后自动移除，关闭中时返回 false
+func (s *Server) track(connection *network.Connection) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.Flag == UnReady {
+		return false
+	}
+
+	s.connections[connection] = struct{}{}
+
+	go func() {
+		<-connection.Done()
+
+		s.mutex.Lock()
+		delete(s.connections, connection)
+		s.mutex.Unlock()
+	}()
+
+	return true
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.Flag == UnReady
+}
 
-		connection := network.NewConnection(conn)
+// Shutdown 停止接收新连接，向所有客户端发送 GOAWAY，
+// 等待通道结束或 ctx 结束后关闭所有连接
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
 
-		go connection.Read()
-		go connection.Write()
-		go connection.Timeout()
+	s.Flag = UnReady
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	connections := make([]*network.Connection, 0, len(s.connections))
+	for connection := range s.connections {
+		connections = append(connections, connection)
+	}
+
+	s.mutex.Unlock()
+
+	util.Infof("Server %s shutting down,connections:%d\n", s.Addr, len(connections))
+
+	return drain(ctx, connections)
+}
+
+// drain 并发排空所有连接，返回第一个错误
+func drain(ctx context.Context, connections []*network.Connection) error {
+	errs := make(chan error, len(connections))
+
+	for _, connection := range connections {
+		go func(connection *network.Connection) {
+			errs <- connection.Drain(ctx)
+		}(connection)
 	}
+
+	var err error
+	for range connections {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
 }
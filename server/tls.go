@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+)
+
+// TLSOptions 服务端 TLS 参数
+type TLSOptions struct {
+	// 服务端证书及私钥
+	Cert string
+	Key  string
+
+	// 校验客户端证书的 CA 文件，为空时不校验客户端证书
+	ClientCA string
+
+	// 是否要求客户端提供证书
+	RequireClientCert bool
+
+	// 允许的客户端，证书 CN 或证书 SHA-256 指纹（十六进制），为空时不限制；
+	// CN 只在配置了 ClientCA 时生效
+	AllowedClients []string
+}
+
+// NewTLSConfig 根据参数构造服务端 TLS 配置
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(opts.Cert, opts.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if opts.ClientCA != "" {
+		data, err := os.ReadFile(opts.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New(opts.ClientCA + ": no certificates found")
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if opts.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if opts.RequireClientCert {
+		return nil, errors.New("tls: clientCA is required to verify client certificates")
+	}
+
+	if len(opts.AllowedClients) > 0 {
+		// 未配置 CA 时客户端证书链未经校验，CN 可以伪造，只按指纹匹配
+		if config.ClientCAs == nil {
+			config.ClientAuth = tls.RequireAnyClientCert
+		}
+
+		fingerprints := map[string]bool{}
+		names := map[string]bool{}
+		for _, client := range opts.AllowedClients {
+			if fingerprint, ok := ParseCertFingerprint(client); ok {
+				fingerprints[fingerprint] = true
+			} else {
+				names[client] = true
+			}
+		}
+
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return errors.New("tls: client certificate required")
+			}
+
+			leaf := state.PeerCertificates[0]
+			sum := sha256.Sum256(leaf.Raw)
+
+			if fingerprints[hex.EncodeToString(sum[:])] {
+				return nil
+			}
+
+			// 只有证书链经 ClientCAs 校验后才按 CN 匹配
+			if len(state.VerifiedChains) > 0 && names[leaf.Subject.CommonName] {
+				return nil
+			}
+
+			return errors.New("tls: client certificate not allowed: " + leaf.Subject.CommonName)
+		}
+	}
+
+	return config, nil
+}
+
+// ParseCertFingerprint 解析十六进制的证书 SHA-256 指纹，可以包含冒号、不区分大小写，
+// 返回小写无冒号的形式
+func ParseCertFingerprint(s string) (string, bool) {
+	fingerprint := strings.ToLower(strings.ReplaceAll(s, ":", ""))
+
+	if b, err := hex.DecodeString(fingerprint); err != nil || len(b) != sha256.Size {
+		return "", false
+	}
+
+	return fingerprint, true
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ssp/internal/testcert"
+)
+
+func TestNewTLSConfigClientAuth(t *testing.T) {
+	serverCert := testcert.New(t, "server")
+	ca := testcert.New(t, "allowed-client")
+	other := testcert.New(t, "other-client")
+	forged := testcert.New(t, "allowed-client")
+
+	caSum := sha256.Sum256(ca.Cert.Raw)
+
+	tests := []struct {
+		name       string
+		opts       TLSOptions
+		clientCert *testcert.Cert
+		wantErr    bool
+	}{
+		{"no client auth", TLSOptions{}, nil, false},
+		{"optional client cert absent", TLSOptions{ClientCA: ca.CertPath}, nil, false},
+		{"optional client cert from ca", TLSOptions{ClientCA: ca.CertPath}, ca, false},
+		// 客户端不会发送不被服务端 CA 接受的证书
+		{"optional client cert from other ca", TLSOptions{ClientCA: ca.CertPath}, other, false},
+		{"required client cert absent", TLSOptions{ClientCA: ca.CertPath, RequireClientCert: true}, nil, true},
+		{"required client cert from ca", TLSOptions{ClientCA: ca.CertPath, RequireClientCert: true}, ca, false},
+		{"required client cert from other ca", TLSOptions{ClientCA: ca.CertPath, RequireClientCert: true}, other, true},
+		// 未配置 CA 时自签名证书可以伪造任意 CN
+		{"cn without ca", TLSOptions{AllowedClients: []string{"allowed-client"}}, ca, true},
+		{"allowed by fingerprint", TLSOptions{AllowedClients: []string{hex.EncodeToString(caSum[:])}}, ca, false},
+		{"allowed by colon fingerprint", TLSOptions{AllowedClients: []string{colonHex(caSum[:])}}, ca, false},
+		{"fingerprint not allowed", TLSOptions{AllowedClients: []string{hex.EncodeToString(caSum[:])}}, other, true},
+		{"allowed list without client cert", TLSOptions{AllowedClients: []string{hex.EncodeToString(caSum[:])}}, nil, true},
+		{"allowed by cn with ca", TLSOptions{ClientCA: ca.CertPath, AllowedClients: []string{"allowed-client"}}, ca, false},
+		{"cn not allowed with ca", TLSOptions{ClientCA: ca.CertPath, AllowedClients: []string{"other-client"}}, ca, true},
+		{"forged cn with ca", TLSOptions{ClientCA: ca.CertPath, RequireClientCert: true, AllowedClients: []string{"allowed-client"}}, forged, true},
+		{"fingerprint with ca", TLSOptions{ClientCA: ca.CertPath, AllowedClients: []string{hex.EncodeToString(caSum[:])}}, ca, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Cert, tt.opts.Key = serverCert.CertPath, serverCert.KeyPath
+
+			serverConfig, err := NewTLSConfig(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clientConfig := &tls.Config{InsecureSkipVerify: true}
+			if tt.clientCert != nil {
+				clientConfig.Certificates = []tls.Certificate{tt.clientCert.TLSCert}
+			}
+
+			if err := testcert.Handshake(t, serverConfig, clientConfig); (err != nil) != tt.wantErr {
+				t.Errorf("handshake error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// colonHex 大写、冒号分隔的十六进制，如 openssl 输出的指纹
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = strings.ToUpper(hex.EncodeToString([]byte{v}))
+	}
+	return strings.Join(parts, ":")
+}
+
+func TestParseCertFingerprint(t *testing.T) {
+	sum := sha256.Sum256([]byte("cert"))
+	want := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name   string
+		s      string
+		want   string
+		wantOk bool
+	}{
+		{"lower hex", want, want, true},
+		{"colon upper hex", colonHex(sum[:]), want, true},
+		{"common name", "allowed-client", "", false},
+		{"short hex", want[:62], "", false},
+		{"long hex", want + "00", "", false},
+		{"hex common name", "deadbeef", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseCertFingerprint(tt.s)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("ParseCertFingerprint(%q) = %q, %v, want %q, %v", tt.s, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfigInvalid(t *testing.T) {
+	serverCert := testcert.New(t, "server")
+
+	tests := []struct {
+		name string
+		opts TLSOptions
+	}{
+		{"missing cert", TLSOptions{Cert: filepath.Join(t.TempDir(), "missing.pem"), Key: serverCert.KeyPath}},
+		{"require client cert without ca", TLSOptions{Cert: serverCert.CertPath, Key: serverCert.KeyPath, RequireClientCert: true}},
+		{"ca without certificates", TLSOptions{Cert: serverCert.CertPath, Key: serverCert.KeyPath, ClientCA: serverCert.KeyPath}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTLSConfig(tt.opts); err == nil {
+				t.Error("NewTLSConfig() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ssp/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 用户不存在时参与比较的哈希，使耗时与用户存在时一致
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("ssp-dummy-password"), bcrypt.DefaultCost)
+
+// FileUserStore 基于文件的用户存储，每行一个用户，格式为 name:bcrypt-hash，
+// 与 htpasswd -B 生成的文件兼容，# 开头的行为注释
+type FileUserStore struct {
+	sync.RWMutex
+
+	// 文件路径
+	Path string
+
+	// 用户名 -> 密码哈希
+	users map[string][]byte
+}
+
+func NewFileUserStore(path string) (*FileUserStore, error) {
+	store := &FileUserStore{Path: path}
+
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Reload 重新读取用户文件，读取失败时保留原有用户
+func (s *FileUserStore) Reload() error {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	users := map[string][]byte{}
+
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, hash, ok := strings.Cut(line, ":")
+		if !ok || name == "" {
+			return fmt.Errorf("%s:%d: expected name:hash", s.Path, lineNo)
+		}
+
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("%s:%d: invalid bcrypt hash for %s: %w", s.Path, lineNo, name, err)
+		}
+
+		users[name] = []byte(hash)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(users) == 0 {
+		return errors.New(s.Path + ": no users")
+	}
+
+	s.Lock()
+	s.users = users
+	s.Unlock()
+
+	util.Infof("Load %d users from %s\n", len(users), s.Path)
+
+	return nil
+}
+
+func (s *FileUserStore) Authenticate(name string, pwd string) bool {
+	s.RLock()
+	hash, ok := s.users[name]
+	s.RUnlock()
+
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(pwd))
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword(hash, []byte(pwd)) == nil
+}
+
+// HashPassword 生成用于用户文件的密码哈希
+func HashPassword(pwd string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
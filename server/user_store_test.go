@@ -0,0 +1,148 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func writeUsersFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "users.txt")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func mustHash(t *testing.T, pwd string, cost int) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), cost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(hash)
+}
+
+func TestFileUserStoreReload(t *testing.T) {
+	hash := mustHash(t, "secret", bcrypt.MinCost)
+
+	tests := []struct {
+		name      string
+		content   string
+		wantErr   bool
+		wantUsers int
+	}{
+		{"single user", "allen:" + hash + "\n", false, 1},
+		{"comments and blank lines", "# users\n\nallen:" + hash + "\n  \nbob:" + hash + "\n", false, 2},
+		{"crlf line endings", "allen:" + hash + "\r\n", false, 1},
+		{"missing separator", "allen\n", true, 0},
+		{"empty name", ":" + hash + "\n", true, 0},
+		{"invalid hash", "allen:plaintext\n", true, 0},
+		{"no users", "# nobody\n", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewFileUserStore(writeUsersFile(t, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewFileUserStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(store.users) != tt.wantUsers {
+				t.Errorf("users = %d, want %d", len(store.users), tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestFileUserStoreReloadKeepsUsersOnError(t *testing.T) {
+	path := writeUsersFile(t, "allen:"+mustHash(t, "secret", bcrypt.MinCost)+"\n")
+
+	store, err := NewFileUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("broken\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Reload(); err == nil {
+		t.Fatal("Reload() of broken file succeeded")
+	}
+
+	if !store.Authenticate("allen", "secret") {
+		t.Error("users lost after failed reload")
+	}
+}
+
+func TestFileUserStoreAuthenticate(t *testing.T) {
+	hash := mustHash(t, "pass with spaces", bcrypt.MinCost)
+
+	store, err := NewFileUserStore(writeUsersFile(t, "allen:"+hash+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		user string
+		pwd  string
+		want bool
+	}{
+		{"valid", "allen", "pass with spaces", true},
+		{"truncated password", "allen", "pass", false},
+		{"wrong password", "allen", "wrong", false},
+		{"empty password", "allen", "", false},
+		{"unknown user", "bob", "pass with spaces", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := store.Authenticate(tt.user, tt.pwd); got != tt.want {
+				t.Errorf("Authenticate(%q, %q) = %v, want %v", tt.user, tt.pwd, got, tt.want)
+			}
+		})
+	}
+}
+
+// 用户不存在时同样进行一次 bcrypt 比较，耗时与用户存在时相当
+func TestFileUserStoreUnknownUserTiming(t *testing.T) {
+	if testing.Short() {
+		t.Skip("bcrypt timing")
+	}
+
+	store, err := NewFileUserStore(writeUsersFile(t, "allen:"+mustHash(t, "secret", bcrypt.DefaultCost)+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	measure := func(user string) time.Duration {
+		start := time.Now()
+		store.Authenticate(user, "wrong")
+		return time.Since(start)
+	}
+
+	known, unknown := measure("allen"), measure("bob")
+	if unknown < known/4 {
+		t.Errorf("unknown user took %s, known user %s", unknown, known)
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("pass with spaces")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("pass with spaces")); err != nil {
+		t.Errorf("hash does not match password: %v", err)
+	}
+}
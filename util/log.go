@@ -0,0 +1,84 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+type LogLevel int32
+
+const (
+	DebugLevel LogLevel = 0
+	InfoLevel  LogLevel = 1
+	WarnLevel  LogLevel = 2
+	ErrorLevel LogLevel = 3
+)
+
+var logLevel atomic.Int32
+
+func init() {
+	logLevel.Store(int32(InfoLevel))
+}
+
+// ParseLogLevel 解析日志级别：debug、info、warn、error
+func ParseLogLevel(level string) (LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return DebugLevel, nil
+	case "info", "":
+		return InfoLevel, nil
+	case "warn":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	default:
+		return InfoLevel, errors.New("invalid log level: " + level)
+	}
+}
+
+func SetLogLevel(level LogLevel) {
+	logLevel.Store(int32(level))
+}
+
+func enabled(level LogLevel) bool {
+	return LogLevel(logLevel.Load()) <= level
+}
+
+func Debugf(format string, v ...any) {
+	if enabled(DebugLevel) {
+		log.Output(2, fmt.Sprintf(format, v...))
+	}
+}
+
+func Infof(format string, v ...any) {
+	if enabled(InfoLevel) {
+		log.Output(2, fmt.Sprintf(format, v...))
+	}
+}
+
+func Warnf(format string, v ...any) {
+	if enabled(WarnLevel) {
+		log.Output(2, fmt.Sprintf(format, v...))
+	}
+}
+
+func Errorf(format string, v ...any) {
+	if enabled(ErrorLevel) {
+		log.Output(2, fmt.Sprintf(format, v...))
+	}
+}
+
+func Infoln(v ...any) {
+	if enabled(InfoLevel) {
+		log.Output(2, fmt.Sprintln(v...))
+	}
+}
+
+func Errorln(v ...any) {
+	if enabled(ErrorLevel) {
+		log.Output(2, fmt.Sprintln(v...))
+	}
+}
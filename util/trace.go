@@ -2,7 +2,6 @@ package util
 
 import (
 	"bytes"
-	"log"
 	"runtime"
 	"strconv"
 	"time"
@@ -11,10 +10,10 @@ import (
 func Trace(traceId string, msg string) func() {
 
 	start := time.Now()
-	log.Printf("%s,Enter %s.\n", traceId, msg)
+	Debugf("%s,Enter %s.\n", traceId, msg)
 
 	return func() {
-		log.Printf("%s,Exit %s (%s)\n", traceId, msg, time.Since(start))
+		Debugf("%s,Exit %s (%s)\n", traceId, msg, time.Since(start))
 	}
 }
 